@@ -1,24 +1,129 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 	"garbage_trucks/backend/internal/config"
 	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/geocoding"
+	"garbage_trucks/backend/internal/logging"
+	"garbage_trucks/backend/internal/middleware"
+	"garbage_trucks/backend/internal/models"
+	"garbage_trucks/backend/internal/openapi"
+	"garbage_trucks/backend/internal/planning"
 	"garbage_trucks/backend/internal/router"
+	"garbage_trucks/backend/internal/routing"
+	"garbage_trucks/backend/internal/routesheet"
 )
 
 func main() {
-	// Загружаем .env
-	cfg := config.Load()
+	// Загружаем .env и проверяем настройки; в production недостающие
+	// настройки — ошибка запуска
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Журнал: уровень, формат и секреты, которые в него не попадут
+	logging.Init(cfg)
+
+	// Контекст отменяется по SIGINT/SIGTERM: начинается остановка сервера
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Подключаемся к базе данных
-	database.Init(cfg)
+	if err := database.Init(ctx, cfg); err != nil {
+		logging.Fatal("Ошибка подключения к базе данных", "err", err)
+	}
+	defer database.Close()
+
+	// Применяем миграции схемы
+	if err := database.Migrate(ctx); err != nil {
+		logging.Fatal("Ошибка применения миграций", "err", err)
+	}
+
+	// Шрифт для PDF маршрутных листов
+	routesheet.Init(cfg)
+
+	// Геокодер и зона обслуживания
+	geocoding.Init(cfg)
+
+	// База и время на планирование маршрутов города
+	planning.Init(cfg)
+
+	// Дорожный граф для расстояний и времени в пути по улицам
+	routing.Init(cfg)
 
-	// Создаём роутер
+	// Пространственный индекс точек для поиска по области и радиусу
+	if err := models.LoadPointIndex(ctx); err != nil {
+		logging.Fatal("Ошибка загрузки индекса точек", "err", err)
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := models.LoadPointIndex(ctx); err != nil && ctx.Err() == nil {
+					slog.Error("Ошибка обновления индекса точек", "err", err)
+				}
+			}
+		}
+	}()
+
+	// Токен администратора, дедлайн запросов и источники CORS
+	middleware.Init(cfg)
+
+	// Спецификация API для проверки запросов
+	if err := openapi.Load(); err != nil {
+		logging.Fatal("Ошибка загрузки спецификации API", "err", err)
+	}
+
+	// Создаём роутер; каждый маршрут должен быть описан в openapi.json
 	r := router.NewRouter()
+	if err := openapi.CheckRoutes(r); err != nil {
+		logging.Fatal("Маршруты не совпадают со спецификацией API", "err", err)
+	}
+
+	srv := &http.Server{
+		Addr:              cfg.Port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
 
 	// Запускаем сервер
-	log.Printf("Сервер запущен на http://localhost%s", cfg.Port)
-	log.Fatal(http.ListenAndServe(cfg.Port, r))
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Сервер запущен", "addr", cfg.Port)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("Ошибка сервера", "err", err)
+		}
+	case <-ctx.Done():
+		stop()
+		slog.Info("Получен сигнал остановки, завершаем активные запросы")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Не все запросы завершились вовремя", "timeout", cfg.ShutdownTimeout, "err", err)
+			srv.Close()
+		}
+		slog.Info("Сервер остановлен")
+	}
 }
\ No newline at end of file
@@ -0,0 +1,46 @@
+// Package actor хранит в контексте имя того, кто выполняет запрос, и его
+// адрес для журнала изменений. Своей аутентификации у API нет, поэтому
+// имя приходит в заголовке X-Actor от фронтенда или прокси и ничем не
+// подтверждено; адрес соединения клиент подменить не может.
+package actor
+
+import "context"
+
+// Header — заголовок с именем пользователя или системы.
+const Header = "X-Actor"
+
+// Anonymous — имя, если заголовок не передан.
+const Anonymous = "anonymous"
+
+// System — имя для изменений, сделанных самим сервером вне запроса.
+const System = "system"
+
+type contextKey struct{}
+
+type remoteAddrKey struct{}
+
+// WithName возвращает контекст с именем исполнителя.
+func WithName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, contextKey{}, name)
+}
+
+// FromContext возвращает имя исполнителя или System, если контекст не
+// относится к запросу.
+func FromContext(ctx context.Context) string {
+	if name, ok := ctx.Value(contextKey{}).(string); ok && name != "" {
+		return name
+	}
+	return System
+}
+
+// WithRemoteAddr возвращает контекст с адресом, с которого пришёл запрос.
+func WithRemoteAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, remoteAddrKey{}, addr)
+}
+
+// RemoteAddr возвращает адрес клиента или пустую строку, если контекст не
+// относится к запросу.
+func RemoteAddr(ctx context.Context) string {
+	addr, _ := ctx.Value(remoteAddrKey{}).(string)
+	return addr
+}
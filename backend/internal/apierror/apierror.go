@@ -0,0 +1,230 @@
+// Package apierror — единый формат ошибок API:
+// {"code": ..., "message": ..., "details": ..., "request_id": ...}.
+// Сообщение задаётся ключом каталога i18n и переводится на язык запроса
+// при отдаче; код ошибки от языка не зависит.
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"garbage_trucks/backend/internal/i18n"
+	"garbage_trucks/backend/internal/requestid"
+	"log/slog"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Коды ошибок. Клиенты опираются на них, а не на текст сообщения.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodePrecondition     = "precondition_failed"
+	CodeTooLarge         = "payload_too_large"
+	CodeUnprocessable    = "unprocessable"
+	CodeBadGateway       = "bad_gateway"
+	CodeTimeout          = "timeout"
+	CodeInternal         = "internal"
+)
+
+// Коды ошибок полей. Как и коды ошибок API, не зависят от языка.
+const (
+	FieldRequired           = "required"
+	FieldTooLong            = "too_long"
+	FieldOutOfRange         = "out_of_range"
+	FieldInvalid            = "invalid"
+	FieldDuplicate          = "duplicate"
+	FieldNotFound           = "not_found"
+	FieldOutsideServiceArea = "outside_service_area"
+	FieldGeocodingFailed    = "geocoding_failed"
+	FieldUnknown            = "unknown" // поле или параметр не описаны в API
+)
+
+// FieldError — ошибка одного поля или параметра; в ответе передаётся
+// списком в details. In — где находится поле: body, query, path.
+type FieldError struct {
+	Field   string `json:"field"`
+	In      string `json:"in,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error — ошибка с HTTP-статусом и кодом для клиента. Key и Args — сообщение
+// из каталога i18n. Err — исходная ошибка, которая пишется в лог, но не
+// отдаётся клиенту.
+type Error struct {
+	Status  int
+	Code    string
+	Key     string
+	Args    []interface{}
+	Details interface{}
+	Err     error
+}
+
+// Message возвращает текст ошибки на указанном языке.
+func (e *Error) Message(lang i18n.Lang) string {
+	return i18n.T(lang, e.Key, e.Args...)
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message(i18n.Default) + ": " + e.Err.Error()
+	}
+	return e.Message(i18n.Default)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails добавляет к ошибке подробности (например, ошибки полей).
+func (e *Error) WithDetails(details interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+func New(status int, code, key string, args ...interface{}) *Error {
+	return &Error{Status: status, Code: code, Key: key, Args: args}
+}
+
+func BadRequest(key string, args ...interface{}) *Error {
+	return New(http.StatusBadRequest, CodeBadRequest, key, args...)
+}
+
+func Validation(key string, args ...interface{}) *Error {
+	return New(http.StatusUnprocessableEntity, CodeValidation, key, args...)
+}
+
+func Unauthorized(key string, args ...interface{}) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, key, args...)
+}
+
+func Forbidden(key string, args ...interface{}) *Error {
+	return New(http.StatusForbidden, CodeForbidden, key, args...)
+}
+
+func NotFound(key string, args ...interface{}) *Error {
+	return New(http.StatusNotFound, CodeNotFound, key, args...)
+}
+
+func Conflict(key string, args ...interface{}) *Error {
+	return New(http.StatusConflict, CodeConflict, key, args...)
+}
+
+func PreconditionFailed(key string, args ...interface{}) *Error {
+	return New(http.StatusPreconditionFailed, CodePrecondition, key, args...)
+}
+
+func TooLarge(key string, args ...interface{}) *Error {
+	return New(http.StatusRequestEntityTooLarge, CodeTooLarge, key, args...)
+}
+
+func MethodNotAllowed() *Error {
+	return New(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "error.method_not_allowed")
+}
+
+func Internal(key string, err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Key: key, Err: err}
+}
+
+// FromError строит ошибку клиента из ошибки разбора параметров. Если err
+// содержит сообщение каталога (i18n.Errorf), оно будет переведено,
+// иначе клиент получит текст ошибки как есть.
+func FromError(status int, code string, err error) *Error {
+	var m *i18n.Message
+	if errors.As(err, &m) {
+		return &Error{Status: status, Code: code, Key: m.Key, Args: m.Args, Err: err}
+	}
+	return &Error{Status: status, Code: code, Key: err.Error(), Err: err}
+}
+
+// Коды ошибок PostgreSQL, которые отдаются клиенту как его ошибки
+const (
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+	pgCheckViolation      = "23514"
+	pgNotNullViolation    = "23502"
+	pgStringTooLong       = "22001"
+)
+
+// FromDB переводит ошибку базы данных в ошибку API: отсутствие строки —
+// 404 с сообщением notFound, нарушение внешнего ключа или уникальности —
+// 409, нарушение CHECK/NOT NULL/длины — 422, остальное — 500 с fallback.
+// notFound и fallback — ключи каталога i18n.
+func FromDB(err error, notFound, fallback string) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Key: notFound, Err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		details := map[string]string{}
+		if pgErr.ConstraintName != "" {
+			details["constraint"] = pgErr.ConstraintName
+		}
+		if pgErr.ColumnName != "" {
+			details["column"] = pgErr.ColumnName
+		}
+
+		switch pgErr.Code {
+		case pgForeignKeyViolation:
+			return &Error{Status: http.StatusConflict, Code: CodeConflict, Key: "error.db_foreign_key", Details: details, Err: err}
+		case pgUniqueViolation:
+			return &Error{Status: http.StatusConflict, Code: CodeConflict, Key: "error.db_unique", Details: details, Err: err}
+		case pgCheckViolation, pgNotNullViolation, pgStringTooLong:
+			return &Error{Status: http.StatusUnprocessableEntity, Code: CodeUnprocessable, Key: "error.db_check", Details: details, Err: err}
+		}
+	}
+
+	return Internal(fallback, err)
+}
+
+type response struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// Write отправляет ошибку клиенту в едином формате на языке запроса.
+// Ошибки, не являющиеся *Error, считаются внутренними. Внутренние ошибки
+// пишутся в лог. Внутренняя ошибка из-за истёкшего дедлайна запроса
+// отдаётся как 504; если клиент сам разорвал соединение, в лог она
+// не пишется.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = Internal("error.internal", err)
+	}
+	if apiErr.Code == CodeInternal && errors.Is(apiErr.Err, context.DeadlineExceeded) {
+		apiErr = &Error{Status: http.StatusGatewayTimeout, Code: CodeTimeout, Key: "error.timeout", Err: apiErr.Err}
+	}
+
+	reqID := requestid.FromContext(r.Context())
+	if apiErr.Status >= http.StatusInternalServerError && !errors.Is(apiErr.Err, context.Canceled) {
+		slog.ErrorContext(r.Context(), "Ошибка обработки запроса",
+			"method", r.Method, "path", r.URL.Path, "status", apiErr.Status, "err", apiErr)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(response{
+		Code:      apiErr.Code,
+		Message:   apiErr.Message(i18n.FromContext(r.Context())),
+		Details:   apiErr.Details,
+		RequestID: reqID,
+	})
+}
@@ -2,9 +2,10 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"strings"
+	"log/slog"
+	"time"
 	"garbage_trucks/backend/internal/config"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -12,40 +13,111 @@ import (
 
 var Pool *pgxpool.Pool
 
-func Init(cfg *config.Config) {
-	var connStr string
-	
-	// Приоритет: DATABASE_URL > отдельные параметры
-	if cfg.DatabaseURL != "" {
-		connStr = cfg.DatabaseURL
-		// Добавляем sslmode=require если его нет (нужно для Neon.tech)
-		if !strings.Contains(connStr, "sslmode=") {
-			if strings.Contains(connStr, "?") {
-				connStr += "&sslmode=require"
-			} else {
-				connStr += "?sslmode=require"
+// ReplicaPool — пул реплики для отчётов; nil, если реплика не настроена
+// или была недоступна при запуске.
+var ReplicaPool *pgxpool.Pool
+
+// Задержки между попытками подключения при запуске
+const (
+	startupBaseDelay = 500 * time.Millisecond
+	startupMaxDelay  = 10 * time.Second
+)
+
+// Init подключается к базе по разобранной конфигурации. Если база ещё
+// не принимает подключения (контейнер Postgres стартует дольше сервера),
+// попытки повторяются с растущей задержкой до DB_STARTUP_TIMEOUT.
+// Недоступная реплика не мешает запуску: отчёты пойдут в основную базу.
+func Init(ctx context.Context, cfg *config.Config) error {
+	retryAttempts = cfg.DBRetryAttempts
+
+	var err error
+	Pool, err = connect(ctx, "primary", cfg.DB, cfg.DBStartupTimeout)
+	if err != nil {
+		return err
+	}
+
+	if cfg.DBReplica != nil {
+		ReplicaPool, err = connect(ctx, "replica", cfg.DBReplica, cfg.DBStartupTimeout)
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
 			}
+			slog.Warn("Реплика недоступна, отчёты читают основную базу", "err", err)
 		}
-	} else {
-		// Используем отдельные параметры (для разработки)
-		connStr = fmt.Sprintf(
-			"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-			cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName,
-		)
 	}
+	return nil
+}
 
-	log.Printf("Подключаемся к БД: %s", strings.Replace(connStr, cfg.DBPassword, "***", 1))
+// connect создаёт пул и ждёт, пока база ответит на ping.
+func connect(ctx context.Context, name string, pc *pgxpool.Config, timeout time.Duration) (*pgxpool.Pool, error) {
+	conn := pc.ConnConfig
+	slog.Info("Подключаемся к БД", "pool", name, "host", conn.Host, "port", conn.Port,
+		"database", conn.Database, "user", conn.User, "max_conns", pc.MaxConns)
 
-	var err error
-	Pool, err = pgxpool.New(context.Background(), connStr)
+	pool, err := pgxpool.NewWithConfig(ctx, pc)
 	if err != nil {
-		log.Fatal("Ошибка подключения к базе данных:", err)
+		return nil, fmt.Errorf("пул %s: %w", name, err)
 	}
 
-	// Проверяем подключение
-	if err = Pool.Ping(context.Background()); err != nil {
-		log.Fatal("Пинг базы не прошёл:", err)
+	deadline := time.Now().Add(timeout)
+	delay := startupBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := pool.Ping(ctx)
+		if err == nil {
+			slog.Info("Подключение к PostgreSQL установлено", "pool", name, "attempt", attempt)
+			return pool, nil
+		}
+		if ctx.Err() != nil || time.Now().Add(delay).After(deadline) {
+			pool.Close()
+			return nil, fmt.Errorf("база %s недоступна после %d попыток: %w", name, attempt, err)
+		}
+
+		slog.Warn("База недоступна, повторим подключение", "pool", name, "attempt", attempt, "retry_in", delay, "err", err)
+		select {
+		case <-ctx.Done():
+			pool.Close()
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, startupMaxDelay)
+	}
+}
+
+// Reporting возвращает пул для тяжёлых запросов на чтение (отчёты):
+// реплику, если она есть, иначе основную базу. Данные реплики могут
+// немного отставать.
+func Reporting() Querier {
+	if ReplicaPool != nil {
+		return ReplicaPool
+	}
+	return Pool
+}
+
+// Close закрывает пул соединений; вызывается при остановке сервера, когда
+// активных запросов уже нет.
+func Close() {
+	if ReplicaPool != nil {
+		ReplicaPool.Close()
+	}
+	if Pool != nil {
+		Pool.Close()
+		slog.Info("Соединения с базой закрыты")
 	}
+}
 
-	log.Println("✅ Подключение к PostgreSQL установлено успешно!")
-}
\ No newline at end of file
+// Ping проверяет, что база доступна: берёт соединение из пула и выполняет
+// пустой запрос.
+func Ping(ctx context.Context) error {
+	if Pool == nil {
+		return errors.New("пул соединений не создан")
+	}
+	return Pool.Ping(ctx)
+}
+
+// PingReplica проверяет реплику; false, если реплика не используется.
+func PingReplica(ctx context.Context) (bool, error) {
+	if ReplicaPool == nil {
+		return false, nil
+	}
+	return true, ReplicaPool.Ping(ctx)
+}
@@ -0,0 +1,47 @@
+-- Исходные таблицы до первой миграции (как в БД.txt, без тестовых данных)
+CREATE TABLE drivers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE collection_points (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    address VARCHAR(300),
+    latitude FLOAT NOT NULL,
+    longitude FLOAT NOT NULL,
+    city VARCHAR(100) DEFAULT 'Рязань'
+);
+
+CREATE TABLE routes (
+    id SERIAL PRIMARY KEY,
+    driver_id INTEGER NOT NULL REFERENCES drivers(id) ON DELETE CASCADE,
+    point_id INTEGER NOT NULL REFERENCES collection_points(id) ON DELETE CASCADE,
+    order_number INTEGER NOT NULL,
+    scheduled_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    visited_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    comment TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_status CHECK (status IN ('pending', 'in_progress', 'completed', 'skipped', 'problem'))
+);
+
+CREATE OR REPLACE FUNCTION update_updated_at()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.updated_at = CURRENT_TIMESTAMP;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER update_routes_updated_at
+BEFORE UPDATE ON routes
+FOR EACH ROW
+EXECUTE FUNCTION update_updated_at();
+
+CREATE INDEX idx_routes_driver_id ON routes(driver_id);
+CREATE INDEX idx_routes_status ON routes(status);
+CREATE INDEX idx_routes_point_id ON routes(point_id);
@@ -0,0 +1,77 @@
+// Package dbtest — база для тестов, которым нужен настоящий PostgreSQL.
+// Адрес берётся из TEST_DATABASE_URL; без него такие тесты пропускаются.
+package dbtest
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"garbage_trucks/backend/internal/database"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed base.sql
+var baseSchema string
+
+// Open создаёт в тестовой базе отдельную схему с исходными таблицами и
+// всеми миграциями и направляет в неё database.Pool. После теста схема
+// удаляется.
+func Open(t *testing.T) context.Context {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL не задан")
+	}
+	ctx := context.Background()
+
+	admin, err := pgx.Connect(ctx, url)
+	if err != nil {
+		t.Fatalf("подключение к тестовой базе: %v", err)
+	}
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		admin.Close(ctx)
+		t.Fatalf("создание схемы: %v", err)
+	}
+
+	pc, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		t.Fatalf("TEST_DATABASE_URL: %v", err)
+	}
+	pc.ConnConfig.RuntimeParams["search_path"] = schema
+	pool, err := pgxpool.NewWithConfig(ctx, pc)
+	if err != nil {
+		t.Fatalf("пул тестовой базы: %v", err)
+	}
+	database.Pool = pool
+
+	t.Cleanup(func() {
+		database.Pool = nil
+		pool.Close()
+		admin.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE")
+		admin.Close(ctx)
+	})
+
+	if _, err := pool.Exec(ctx, baseSchema); err != nil {
+		t.Fatalf("исходные таблицы: %v", err)
+	}
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatalf("миграции: %v", err)
+	}
+	return ctx
+}
+
+// Exec выполняет запрос подготовки данных и возвращает id из RETURNING.
+func Exec(t *testing.T, ctx context.Context, query string, args ...interface{}) int {
+	t.Helper()
+	var id int
+	if err := database.Pool.QueryRow(ctx, query, args...).Scan(&id); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return id
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Migrate применяет SQL-миграции из каталога migrations, которые ещё не были
+// применены. Каждая миграция выполняется в отдельной транзакции, номер версии
+// — имя файла без расширения.
+func Migrate(ctx context.Context) error {
+	_, err := Pool.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version VARCHAR(100) PRIMARY KEY,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("создание schema_migrations: %w", err)
+	}
+
+	versions, err := migrationVersions()
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		var applied bool
+		err := Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		body, err := migrationsFS.ReadFile("migrations/" + version + ".sql")
+		if err != nil {
+			return err
+		}
+
+		tx, err := Pool.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, string(body)); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("миграция %s: %w", version, err)
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+			tx.Rollback(ctx)
+			return fmt.Errorf("миграция %s: %w", version, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("миграция %s: %w", version, err)
+		}
+
+		slog.Info("Применена миграция", "version", version)
+	}
+
+	return nil
+}
+
+// migrationVersions возвращает версии встроенных миграций по порядку.
+func migrationVersions() ([]string, error) {
+	files, err := migrationsFS.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, f := range files {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), ".sql") {
+			versions = append(versions, strings.TrimSuffix(f.Name(), ".sql"))
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// PendingMigrations возвращает версии встроенных миграций, которые ещё не
+// применены к базе. Пустой список — схема актуальна.
+func PendingMigrations(ctx context.Context) ([]string, error) {
+	versions, err := migrationVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []string
+	err = Pool.QueryRow(ctx, `
+		SELECT COALESCE(array_agg(version), '{}') FROM schema_migrations
+	`).Scan(&applied)
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool, len(applied))
+	for _, v := range applied {
+		done[v] = true
+	}
+
+	pending := []string{}
+	for _, v := range versions {
+		if !done[v] {
+			pending = append(pending, v)
+		}
+	}
+	return pending, nil
+}
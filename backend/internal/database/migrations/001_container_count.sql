@@ -0,0 +1,9 @@
+-- Количество контейнеров на площадке (нужно для маршрутного листа)
+ALTER TABLE collection_points
+    ADD COLUMN IF NOT EXISTS container_count INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE collection_points
+    DROP CONSTRAINT IF EXISTS valid_container_count;
+
+ALTER TABLE collection_points
+    ADD CONSTRAINT valid_container_count CHECK (container_count > 0);
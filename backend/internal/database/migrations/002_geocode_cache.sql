@@ -0,0 +1,13 @@
+-- Кэш результатов геокодирования (прямого и обратного)
+CREATE TABLE IF NOT EXISTS geocode_cache (
+    id SERIAL PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    kind VARCHAR(10) NOT NULL,
+    query VARCHAR(300) NOT NULL,
+    address VARCHAR(300) NOT NULL,
+    latitude FLOAT NOT NULL,
+    longitude FLOAT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_geocode_kind CHECK (kind IN ('forward', 'reverse')),
+    CONSTRAINT geocode_cache_unique UNIQUE (provider, kind, query)
+);
@@ -0,0 +1,19 @@
+-- Районы города и зона обслуживания (полигоны из GeoJSON)
+CREATE TABLE IF NOT EXISTS districts (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'district',
+    geometry JSONB NOT NULL,
+    min_lon FLOAT NOT NULL,
+    min_lat FLOAT NOT NULL,
+    max_lon FLOAT NOT NULL,
+    max_lat FLOAT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_district_kind CHECK (kind IN ('district', 'service_area')),
+    CONSTRAINT districts_kind_name_unique UNIQUE (kind, name)
+);
+
+ALTER TABLE collection_points
+    ADD COLUMN IF NOT EXISTS district_id INTEGER REFERENCES districts(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_collection_points_district_id ON collection_points(district_id);
@@ -0,0 +1,81 @@
+-- Журнал изменений данных: кто, когда и что изменил.
+-- Записи создают триггеры, поэтому в журнал попадают и каскадные
+-- удаления (например, маршруты удалённого водителя).
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(100) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    entity VARCHAR(30) NOT NULL,
+    entity_id INTEGER,
+    before JSONB,
+    after JSONB,
+    request_id VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT valid_audit_action CHECK (action IN ('create', 'update', 'delete', 'status_change'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+
+-- Аргументы триггера: имя сущности, затем колонки, которые не нужно
+-- сохранять (например, объёмная геометрия районов).
+-- Исполнитель и идентификатор запроса берутся из настроек сессии,
+-- которые выставляет database.InTx; без них исполнитель — system.
+CREATE OR REPLACE FUNCTION audit_row_change() RETURNS trigger AS $$
+DECLARE
+    excluded TEXT[] := TG_ARGV[1:];
+    old_row JSONB;
+    new_row JSONB;
+    act TEXT;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_row := to_jsonb(OLD) - excluded;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_row := to_jsonb(NEW) - excluded;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        act := 'create';
+    ELSIF TG_OP = 'DELETE' THEN
+        act := 'delete';
+    ELSE
+        IF old_row = new_row THEN
+            RETURN NULL;
+        END IF;
+        IF old_row ? 'status' AND (old_row->>'status') IS DISTINCT FROM (new_row->>'status') THEN
+            act := 'status_change';
+        ELSE
+            act := 'update';
+        END IF;
+    END IF;
+
+    INSERT INTO audit_log (actor, action, entity, entity_id, before, after, request_id)
+    VALUES (
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), 'system'),
+        act,
+        TG_ARGV[0],
+        (COALESCE(new_row, old_row)->>'id')::INTEGER,
+        old_row,
+        new_row,
+        NULLIF(current_setting('app.request_id', true), '')
+    );
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_drivers ON drivers;
+CREATE TRIGGER audit_drivers AFTER INSERT OR UPDATE OR DELETE ON drivers
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('driver');
+
+DROP TRIGGER IF EXISTS audit_collection_points ON collection_points;
+CREATE TRIGGER audit_collection_points AFTER INSERT OR UPDATE OR DELETE ON collection_points
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('point');
+
+DROP TRIGGER IF EXISTS audit_routes ON routes;
+CREATE TRIGGER audit_routes AFTER INSERT OR UPDATE OR DELETE ON routes
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('route');
+
+DROP TRIGGER IF EXISTS audit_districts ON districts;
+CREATE TRIGGER audit_districts AFTER INSERT OR UPDATE OR DELETE ON districts
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('district', 'geometry');
@@ -0,0 +1,33 @@
+-- Версии строк для оптимистичной блокировки: клиент получает версию
+-- в ETag и передаёт её в If-Match при изменении. Версию увеличивает
+-- триггер, поэтому её меняет любое изменение строки, в том числе
+-- сделанное не через API.
+ALTER TABLE drivers
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE collection_points
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE routes
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION bump_row_version() RETURNS trigger AS $$
+BEGIN
+    IF NEW IS DISTINCT FROM OLD THEN
+        NEW.version := OLD.version + 1;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS bump_drivers_version ON drivers;
+CREATE TRIGGER bump_drivers_version BEFORE UPDATE ON drivers
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+
+DROP TRIGGER IF EXISTS bump_collection_points_version ON collection_points;
+CREATE TRIGGER bump_collection_points_version BEFORE UPDATE ON collection_points
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+
+DROP TRIGGER IF EXISTS bump_routes_version ON routes;
+CREATE TRIGGER bump_routes_version BEFORE UPDATE ON routes
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
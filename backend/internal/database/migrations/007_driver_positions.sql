@@ -0,0 +1,19 @@
+-- GPS-отметки водителей: трекер или мобильное приложение присылают
+-- координаты во время смены. Обзор диспетчера берёт последнюю отметку
+-- за день. Журнал изменений для них не ведётся — это поток телеметрии,
+-- а не правки данных.
+CREATE TABLE IF NOT EXISTS driver_positions (
+    id BIGSERIAL PRIMARY KEY,
+    driver_id INTEGER NOT NULL REFERENCES drivers(id) ON DELETE CASCADE,
+    latitude DOUBLE PRECISION NOT NULL,
+    longitude DOUBLE PRECISION NOT NULL,
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_driver_positions_driver_recorded
+    ON driver_positions (driver_id, recorded_at DESC);
+
+-- Остановки дня водителя по порядку: обзор диспетчера и маршрутный лист
+CREATE INDEX IF NOT EXISTS idx_routes_driver_scheduled
+    ON routes (driver_id, scheduled_at);
@@ -0,0 +1,24 @@
+-- Ограничения обслуживания точки: окно времени (школы и рынки — только
+-- до 07:00), дни недели без вывоза (ISO: 1 — понедельник, 7 —
+-- воскресенье), заметки о подъезде и код ворот для закрытых дворов.
+ALTER TABLE collection_points
+    ADD COLUMN IF NOT EXISTS window_start TIME,
+    ADD COLUMN IF NOT EXISTS window_end TIME,
+    ADD COLUMN IF NOT EXISTS excluded_weekdays SMALLINT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS access_notes VARCHAR(500),
+    ADD COLUMN IF NOT EXISTS gate_code VARCHAR(50);
+
+ALTER TABLE collection_points
+    DROP CONSTRAINT IF EXISTS valid_service_window;
+
+ALTER TABLE collection_points
+    ADD CONSTRAINT valid_service_window CHECK (
+        (window_start IS NULL AND window_end IS NULL)
+        OR (window_start IS NOT NULL AND window_end IS NOT NULL AND window_start < window_end)
+    );
+
+ALTER TABLE collection_points
+    DROP CONSTRAINT IF EXISTS valid_excluded_weekdays;
+
+ALTER TABLE collection_points
+    ADD CONSTRAINT valid_excluded_weekdays CHECK (excluded_weekdays <@ ARRAY[1, 2, 3, 4, 5, 6, 7]::SMALLINT[]);
@@ -0,0 +1,10 @@
+-- Код ворот виден только водителю (маршрутный лист) и в карточке точки,
+-- поэтому в журнал изменений он не пишется. Уже записанные значения
+-- удаляются.
+DROP TRIGGER IF EXISTS audit_collection_points ON collection_points;
+CREATE TRIGGER audit_collection_points AFTER INSERT OR UPDATE OR DELETE ON collection_points
+    FOR EACH ROW EXECUTE FUNCTION audit_row_change('point', 'gate_code');
+
+UPDATE audit_log
+SET before = before - 'gate_code', after = after - 'gate_code'
+WHERE entity = 'point' AND (before ? 'gate_code' OR after ? 'gate_code');
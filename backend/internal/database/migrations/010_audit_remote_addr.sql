@@ -0,0 +1,51 @@
+-- Исполнитель берётся из заголовка X-Actor и ничем не подтверждён,
+-- поэтому рядом с ним записывается адрес, с которого пришёл запрос
+-- (его выставляет database.InTx из адреса соединения).
+ALTER TABLE audit_log
+    ADD COLUMN IF NOT EXISTS remote_addr VARCHAR(64);
+
+CREATE OR REPLACE FUNCTION audit_row_change() RETURNS trigger AS $$
+DECLARE
+    excluded TEXT[] := TG_ARGV[1:];
+    old_row JSONB;
+    new_row JSONB;
+    act TEXT;
+BEGIN
+    IF TG_OP <> 'INSERT' THEN
+        old_row := to_jsonb(OLD) - excluded;
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        new_row := to_jsonb(NEW) - excluded;
+    END IF;
+
+    IF TG_OP = 'INSERT' THEN
+        act := 'create';
+    ELSIF TG_OP = 'DELETE' THEN
+        act := 'delete';
+    ELSE
+        IF old_row = new_row THEN
+            RETURN NULL;
+        END IF;
+        IF old_row ? 'archived_at' AND (old_row->>'archived_at') IS DISTINCT FROM (new_row->>'archived_at') THEN
+            act := CASE WHEN new_row->>'archived_at' IS NULL THEN 'restore' ELSE 'archive' END;
+        ELSIF old_row ? 'status' AND (old_row->>'status') IS DISTINCT FROM (new_row->>'status') THEN
+            act := 'status_change';
+        ELSE
+            act := 'update';
+        END IF;
+    END IF;
+
+    INSERT INTO audit_log (actor, action, entity, entity_id, before, after, request_id, remote_addr)
+    VALUES (
+        COALESCE(NULLIF(current_setting('app.actor', true), ''), 'system'),
+        act,
+        TG_ARGV[0],
+        (COALESCE(new_row, old_row)->>'id')::INTEGER,
+        old_row,
+        new_row,
+        NULLIF(current_setting('app.request_id', true), ''),
+        NULLIF(current_setting('app.remote_addr', true), '')
+    );
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
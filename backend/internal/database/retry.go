@@ -0,0 +1,95 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Задержки между повторами запросов: растут вдвое от retryBaseDelay
+// до retryMaxDelay, со случайным разбросом, чтобы конкурирующие
+// транзакции не повторялись одновременно
+const (
+	retryBaseDelay = 50 * time.Millisecond
+	retryMaxDelay  = time.Second
+)
+
+// retryAttempts — сколько раз выполнять запрос при временных ошибках (DB_RETRY_ATTEMPTS).
+var retryAttempts = 3
+
+// Коды PostgreSQL, после которых запрос можно просто повторить
+var transientCodes = map[string]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"08000": true, // connection_exception
+	"08001": true, // sqlclient_unable_to_establish_sqlconnection
+	"08003": true, // connection_does_not_exist
+	"08004": true, // sqlserver_rejected_establishment_of_sqlconnection
+	"08006": true, // connection_failure
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// commitUnknownError — соединение оборвалось во время COMMIT: неизвестно,
+// зафиксирована ли транзакция, поэтому повторять её нельзя.
+type commitUnknownError struct {
+	err error
+}
+
+func (e *commitUnknownError) Error() string { return e.err.Error() }
+func (e *commitUnknownError) Unwrap() error { return e.err }
+
+// IsTransient сообщает, что ошибка временная и запрос можно повторить:
+// конфликт сериализации, взаимоблокировка, перезапуск сервера или обрыв
+// соединения.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var commitErr *commitUnknownError
+	if errors.As(err, &commitErr) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return transientCodes[pgErr.Code]
+	}
+	if pgconn.SafeToRetry(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
+
+// Retry выполняет fn и повторяет её при временных ошибках, пока не
+// кончатся попытки или контекст. fn должна быть безопасна для повтора:
+// транзакция целиком или запрос только на чтение.
+func Retry(ctx context.Context, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retryAttempts || !IsTransient(err) {
+			return err
+		}
+
+		wait := delay/2 + rand.N(delay/2+1)
+		slog.WarnContext(ctx, "Временная ошибка базы, повторяем запрос",
+			"attempt", attempt, "retry_in", wait, "err", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
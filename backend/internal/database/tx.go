@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"garbage_trucks/backend/internal/actor"
+	"garbage_trucks/backend/internal/requestid"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Querier — общее у пула и транзакции, чтобы функции моделей могли
+// работать и самостоятельно, и внутри чужой транзакции.
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// InTx выполняет fn в транзакции. В начале транзакции в настройки сессии
+// записываются исполнитель, его адрес и идентификатор запроса: их читают триггеры
+// журнала изменений (audit_log). Все изменения данных должны идти через InTx.
+//
+// При временной ошибке (конфликт сериализации, обрыв соединения)
+// транзакция повторяется целиком, поэтому fn не должна иметь побочных
+// эффектов вне базы, кроме присваивания результатов.
+func InTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	return Retry(ctx, func() error { return inTx(ctx, fn) })
+}
+
+func inTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`SELECT set_config('app.actor', $1, true), set_config('app.request_id', $2, true), set_config('app.remote_addr', $3, true)`,
+		actor.FromContext(ctx), requestid.FromContext(ctx), actor.RemoteAddr(ctx),
+	); err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) {
+			return &commitUnknownError{err}
+		}
+		return err
+	}
+	return nil
+}
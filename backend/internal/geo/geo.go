@@ -0,0 +1,73 @@
+// Package geo содержит базовые географические типы и расчёты,
+// общие для геокодирования, районов и маршрутизации.
+package geo
+
+import (
+	"garbage_trucks/backend/internal/i18n"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusMeters = 6371000.0
+
+// BBox — прямоугольная область в градусах WGS84.
+type BBox struct {
+	MinLon float64 `json:"min_lon"`
+	MinLat float64 `json:"min_lat"`
+	MaxLon float64 `json:"max_lon"`
+	MaxLat float64 `json:"max_lat"`
+}
+
+// ParseBBox разбирает строку вида "minLon,minLat,maxLon,maxLat".
+func ParseBBox(s string) (BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BBox{}, i18n.Errorf("bbox.format")
+	}
+
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return BBox{}, i18n.Errorf("bbox.not_number", p)
+		}
+		v[i] = f
+	}
+
+	b := BBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	if b.MinLon > b.MaxLon || b.MinLat > b.MaxLat {
+		return BBox{}, i18n.Errorf("bbox.min_gt_max")
+	}
+	if !ValidCoordinates(b.MinLat, b.MinLon) || !ValidCoordinates(b.MaxLat, b.MaxLon) {
+		return BBox{}, i18n.Errorf("bbox.out_of_range")
+	}
+	return b, nil
+}
+
+// Contains проверяет, попадает ли точка в область (границы включительно).
+func (b BBox) Contains(lat, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// IsZero сообщает, что область не задана.
+func (b BBox) IsZero() bool {
+	return b == BBox{}
+}
+
+// ValidCoordinates проверяет диапазоны широты и долготы.
+func ValidCoordinates(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 &&
+		!math.IsNaN(lat) && !math.IsNaN(lon)
+}
+
+// Distance возвращает расстояние по дуге большого круга в метрах.
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	rLat1 := lat1 * math.Pi / 180
+	rLat2 := lat2 * math.Pi / 180
+	dLat := (lat2 - lat1) * math.Pi / 180
+	dLon := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(rLat1)*math.Cos(rLat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,134 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"garbage_trucks/backend/internal/i18n"
+	"math"
+)
+
+// Ring — замкнутый контур из пар [долгота, широта], как в GeoJSON.
+type Ring [][2]float64
+
+// Polygon — внешний контур и, возможно, вырезы (дыры).
+type Polygon []Ring
+
+// MultiPolygon — набор полигонов. Любой Polygon из GeoJSON приводится к нему.
+type MultiPolygon []Polygon
+
+// Geometry — геометрия GeoJSON.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// Feature — объект GeoJSON с произвольными свойствами.
+type Feature struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+	Geometry   *Geometry              `json:"geometry"`
+}
+
+// FeatureCollection — коллекция объектов GeoJSON.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// ParseMultiPolygon разбирает геометрию Polygon или MultiPolygon.
+func ParseMultiPolygon(g *Geometry) (MultiPolygon, error) {
+	if g == nil {
+		return nil, i18n.Errorf("geometry.missing")
+	}
+
+	var mp MultiPolygon
+	switch g.Type {
+	case "Polygon":
+		var p Polygon
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return nil, fmt.Errorf("Polygon: %w", err)
+		}
+		mp = MultiPolygon{p}
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &mp); err != nil {
+			return nil, fmt.Errorf("MultiPolygon: %w", err)
+		}
+	default:
+		return nil, i18n.Errorf("geometry.unsupported", g.Type)
+	}
+
+	for _, p := range mp {
+		if len(p) == 0 {
+			return nil, i18n.Errorf("geometry.no_rings")
+		}
+		for _, ring := range p {
+			if len(ring) < 4 {
+				return nil, i18n.Errorf("geometry.ring_too_short")
+			}
+			for _, c := range ring {
+				if !ValidCoordinates(c[1], c[0]) {
+					return nil, i18n.Errorf("geometry.out_of_range", c)
+				}
+			}
+		}
+	}
+	return mp, nil
+}
+
+// GeoJSON возвращает геометрию MultiPolygon для хранения и выдачи клиенту.
+func (mp MultiPolygon) GeoJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type        string       `json:"type"`
+		Coordinates MultiPolygon `json:"coordinates"`
+	}{"MultiPolygon", mp})
+}
+
+// Bounds возвращает описывающий прямоугольник.
+func (mp MultiPolygon) Bounds() BBox {
+	b := BBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)}
+	for _, p := range mp {
+		for _, c := range p[0] {
+			b.MinLon = math.Min(b.MinLon, c[0])
+			b.MaxLon = math.Max(b.MaxLon, c[0])
+			b.MinLat = math.Min(b.MinLat, c[1])
+			b.MaxLat = math.Max(b.MaxLat, c[1])
+		}
+	}
+	return b
+}
+
+// Contains проверяет попадание точки в мультиполигон с учётом вырезов.
+func (mp MultiPolygon) Contains(lat, lon float64) bool {
+	for _, p := range mp {
+		if p.Contains(lat, lon) {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains проверяет попадание точки во внешний контур и непопадание в вырезы.
+func (p Polygon) Contains(lat, lon float64) bool {
+	if len(p) == 0 || !p[0].contains(lat, lon) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if hole.contains(lat, lon) {
+			return false
+		}
+	}
+	return true
+}
+
+// contains — классический алгоритм трассировки луча (even-odd).
+func (r Ring) contains(lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		xi, yi := r[i][0], r[i][1]
+		xj, yj := r[j][0], r[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,83 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"garbage_trucks/backend/internal/database"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CachedProvider хранит результаты провайдера в таблице geocode_cache,
+// чтобы не обращаться к внешнему сервису за одним и тем же адресом.
+type CachedProvider struct {
+	next Provider
+}
+
+func NewCachedProvider(next Provider) *CachedProvider {
+	return &CachedProvider{next: next}
+}
+
+func (p *CachedProvider) Name() string {
+	return p.next.Name()
+}
+
+func (p *CachedProvider) Geocode(ctx context.Context, address string) (*Result, error) {
+	key := normalizeAddress(address)
+	if r, ok := p.lookup(ctx, "forward", key); ok {
+		return r, nil
+	}
+
+	r, err := p.next.Geocode(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	p.store(ctx, "forward", key, r)
+	return r, nil
+}
+
+func (p *CachedProvider) Reverse(ctx context.Context, lat, lon float64) (*Result, error) {
+	// ~1 м точности достаточно, чтобы соседние запросы попадали в кэш
+	key := fmt.Sprintf("%.5f,%.5f", lat, lon)
+	if r, ok := p.lookup(ctx, "reverse", key); ok {
+		return r, nil
+	}
+
+	r, err := p.next.Reverse(ctx, lat, lon)
+	if err != nil {
+		return nil, err
+	}
+	p.store(ctx, "reverse", key, r)
+	return r, nil
+}
+
+func (p *CachedProvider) lookup(ctx context.Context, kind, key string) (*Result, bool) {
+	var r Result
+	err := database.Pool.QueryRow(ctx, `
+		SELECT address, latitude, longitude
+		FROM geocode_cache
+		WHERE provider = $1 AND kind = $2 AND query = $3
+	`, p.next.Name(), kind, key).Scan(&r.Address, &r.Latitude, &r.Longitude)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			slog.WarnContext(ctx, "Ошибка чтения кэша геокодера", "kind", kind, "err", err)
+		}
+		return nil, false
+	}
+	return &r, true
+}
+
+func (p *CachedProvider) store(ctx context.Context, kind, key string, r *Result) {
+	_, err := database.Pool.Exec(ctx, `
+		INSERT INTO geocode_cache (provider, kind, query, address, latitude, longitude)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (provider, kind, query) DO UPDATE
+		SET address = EXCLUDED.address, latitude = EXCLUDED.latitude,
+			longitude = EXCLUDED.longitude, created_at = CURRENT_TIMESTAMP
+	`, p.next.Name(), kind, key, r.Address, r.Latitude, r.Longitude)
+	if err != nil {
+		slog.WarnContext(ctx, "Ошибка записи в кэш геокодера", "kind", kind, "err", err)
+	}
+}
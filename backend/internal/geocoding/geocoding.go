@@ -0,0 +1,104 @@
+// Package geocoding переводит адреса в координаты и обратно через
+// подключаемых провайдеров с кэшем результатов в базе данных.
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"garbage_trucks/backend/internal/config"
+	"garbage_trucks/backend/internal/geo"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ErrNotFound — провайдер не нашёл ни одного результата.
+var ErrNotFound = errors.New("адрес не найден")
+
+// ErrDisabled — геокодер не настроен.
+var ErrDisabled = errors.New("геокодер не настроен")
+
+// Result — найденный адрес с координатами.
+type Result struct {
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Provider — внешний или локальный источник геокодирования.
+type Provider interface {
+	// Name используется как ключ кэша, чтобы результаты разных
+	// провайдеров не смешивались.
+	Name() string
+	Geocode(ctx context.Context, address string) (*Result, error)
+	Reverse(ctx context.Context, lat, lon float64) (*Result, error)
+}
+
+var (
+	provider    Provider
+	serviceArea geo.BBox
+)
+
+// httpClient общий для HTTP-провайдеров
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Init выбирает провайдера по конфигурации и оборачивает его кэшем.
+// Пустой GEOCODER_PROVIDER означает yandex при наличии ключа, иначе
+// геокодирование отключено.
+func Init(cfg *config.Config) {
+	serviceArea = cfg.ServiceArea
+
+	name := cfg.GeocoderProvider
+	if name == "" && cfg.YandexGeocoderKey != "" {
+		name = "yandex"
+	}
+
+	var p Provider
+	switch name {
+	case "yandex":
+		p = NewYandexProvider(cfg.YandexGeocoderKey)
+	case "nominatim":
+		p = NewNominatimProvider(cfg.GeocoderURL)
+	case "stub":
+		p = NewStubProvider()
+	default:
+		slog.Info("Геокодер отключён")
+		return
+	}
+
+	provider = NewCachedProvider(p)
+	slog.Info("Геокодер включён", "provider", p.Name())
+}
+
+// Enabled сообщает, настроен ли геокодер.
+func Enabled() bool {
+	return provider != nil
+}
+
+// Geocode ищет координаты по адресу через текущего провайдера.
+func Geocode(ctx context.Context, address string) (*Result, error) {
+	if provider == nil {
+		return nil, ErrDisabled
+	}
+	return provider.Geocode(ctx, address)
+}
+
+// Reverse ищет адрес по координатам через текущего провайдера.
+func Reverse(ctx context.Context, lat, lon float64) (*Result, error) {
+	if provider == nil {
+		return nil, ErrDisabled
+	}
+	return provider.Reverse(ctx, lat, lon)
+}
+
+// InServiceArea проверяет, что точка внутри зоны обслуживания.
+// Если зона не задана, подходит любая точка с корректными координатами.
+func InServiceArea(lat, lon float64) bool {
+	if !geo.ValidCoordinates(lat, lon) {
+		return false
+	}
+	if serviceArea.IsZero() {
+		return true
+	}
+	return serviceArea.Contains(lat, lon)
+}
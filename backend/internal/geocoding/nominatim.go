@@ -0,0 +1,104 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const defaultNominatimURL = "https://nominatim.openstreetmap.org"
+
+// NominatimProvider — геокодер OpenStreetMap Nominatim (публичный или свой).
+type NominatimProvider struct {
+	baseURL string
+}
+
+func NewNominatimProvider(baseURL string) *NominatimProvider {
+	if baseURL == "" {
+		baseURL = defaultNominatimURL
+	}
+	return &NominatimProvider{baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (p *NominatimProvider) Name() string {
+	return "nominatim"
+}
+
+type nominatimPlace struct {
+	Lat         string `json:"lat"`
+	Lon         string `json:"lon"`
+	DisplayName string `json:"display_name"`
+	Error       string `json:"error"`
+}
+
+func (p *NominatimProvider) Geocode(ctx context.Context, address string) (*Result, error) {
+	params := url.Values{}
+	params.Set("q", address)
+	params.Set("format", "jsonv2")
+	params.Set("limit", "1")
+
+	var places []nominatimPlace
+	if err := p.get(ctx, "/search?"+params.Encode(), &places); err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, ErrNotFound
+	}
+	return places[0].result()
+}
+
+func (p *NominatimProvider) Reverse(ctx context.Context, lat, lon float64) (*Result, error) {
+	params := url.Values{}
+	params.Set("lat", strconv.FormatFloat(lat, 'f', -1, 64))
+	params.Set("lon", strconv.FormatFloat(lon, 'f', -1, 64))
+	params.Set("format", "jsonv2")
+
+	var place nominatimPlace
+	if err := p.get(ctx, "/reverse?"+params.Encode(), &place); err != nil {
+		return nil, err
+	}
+	if place.Error != "" {
+		return nil, ErrNotFound
+	}
+	return place.result()
+}
+
+func (p *NominatimProvider) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	// Политика Nominatim требует осмысленный User-Agent
+	req.Header.Set("User-Agent", "garbage-trucks-backend")
+	req.Header.Set("Accept-Language", "ru")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("nominatim: HTTP %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("nominatim: %w", err)
+	}
+	return nil
+}
+
+func (pl nominatimPlace) result() (*Result, error) {
+	lat, err := strconv.ParseFloat(pl.Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("nominatim: %w", err)
+	}
+	lon, err := strconv.ParseFloat(pl.Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("nominatim: %w", err)
+	}
+	return &Result{Address: pl.DisplayName, Latitude: lat, Longitude: lon}, nil
+}
@@ -0,0 +1,75 @@
+package geocoding
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+)
+
+// StubProvider — локальный геокодер без сети для тестов и разработки.
+// Прямое геокодирование ищет адрес по точному (без учёта регистра)
+// совпадению, обратное — ближайший известный адрес.
+type StubProvider struct {
+	mu      sync.RWMutex
+	entries []Result
+}
+
+// NewStubProvider создаёт заглушку с несколькими адресами Рязани.
+func NewStubProvider(entries ...Result) *StubProvider {
+	if len(entries) == 0 {
+		entries = []Result{
+			{Address: "Рязань, ул. Свободы, 1", Latitude: 54.6269, Longitude: 39.7464},
+			{Address: "Рязань, ул. Почтовая, 10", Latitude: 54.6150, Longitude: 39.7300},
+			{Address: "Рязань, Московское шоссе, 50", Latitude: 54.6500, Longitude: 39.7200},
+		}
+	}
+	return &StubProvider{entries: entries}
+}
+
+// Add добавляет известный адрес.
+func (p *StubProvider) Add(r Result) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = append(p.entries, r)
+}
+
+func (p *StubProvider) Name() string {
+	return "stub"
+}
+
+func (p *StubProvider) Geocode(ctx context.Context, address string) (*Result, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	key := normalizeAddress(address)
+	for _, e := range p.entries {
+		if normalizeAddress(e.Address) == key {
+			r := e
+			return &r, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (p *StubProvider) Reverse(ctx context.Context, lat, lon float64) (*Result, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	var best *Result
+	bestDist := math.Inf(1)
+	for i, e := range p.entries {
+		d := (e.Latitude-lat)*(e.Latitude-lat) + (e.Longitude-lon)*(e.Longitude-lon)
+		if d < bestDist {
+			best, bestDist = &p.entries[i], d
+		}
+	}
+	if best == nil {
+		return nil, ErrNotFound
+	}
+	return &Result{Address: best.Address, Latitude: lat, Longitude: lon}, nil
+}
+
+func normalizeAddress(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
@@ -0,0 +1,108 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const yandexGeocoderURL = "https://geocode-maps.yandex.ru/1.x/"
+
+// YandexProvider — HTTP Геокодер Яндекс.Карт.
+type YandexProvider struct {
+	apiKey string
+}
+
+func NewYandexProvider(apiKey string) *YandexProvider {
+	return &YandexProvider{apiKey: apiKey}
+}
+
+func (p *YandexProvider) Name() string {
+	return "yandex"
+}
+
+func (p *YandexProvider) Geocode(ctx context.Context, address string) (*Result, error) {
+	return p.query(ctx, address)
+}
+
+func (p *YandexProvider) Reverse(ctx context.Context, lat, lon float64) (*Result, error) {
+	// Яндекс принимает координаты в порядке "долгота,широта"
+	return p.query(ctx, fmt.Sprintf("%f,%f", lon, lat))
+}
+
+type yandexResponse struct {
+	Response struct {
+		GeoObjectCollection struct {
+			FeatureMember []struct {
+				GeoObject struct {
+					MetaDataProperty struct {
+						GeocoderMetaData struct {
+							Text string `json:"text"`
+						} `json:"GeocoderMetaData"`
+					} `json:"metaDataProperty"`
+					Point struct {
+						Pos string `json:"pos"`
+					} `json:"Point"`
+				} `json:"GeoObject"`
+			} `json:"featureMember"`
+		} `json:"GeoObjectCollection"`
+	} `json:"response"`
+}
+
+func (p *YandexProvider) query(ctx context.Context, geocode string) (*Result, error) {
+	params := url.Values{}
+	params.Set("apikey", p.apiKey)
+	params.Set("geocode", geocode)
+	params.Set("format", "json")
+	params.Set("lang", "ru_RU")
+	params.Set("results", "1")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, yandexGeocoderURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("yandex: HTTP %d", resp.StatusCode)
+	}
+
+	var data yandexResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("yandex: %w", err)
+	}
+
+	members := data.Response.GeoObjectCollection.FeatureMember
+	if len(members) == 0 {
+		return nil, ErrNotFound
+	}
+
+	obj := members[0].GeoObject
+	pos := strings.Fields(obj.Point.Pos)
+	if len(pos) != 2 {
+		return nil, fmt.Errorf("yandex: неожиданный формат координат %q", obj.Point.Pos)
+	}
+	lon, err := strconv.ParseFloat(pos[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("yandex: %w", err)
+	}
+	lat, err := strconv.ParseFloat(pos[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("yandex: %w", err)
+	}
+
+	return &Result{
+		Address:   obj.MetaDataProperty.GeocoderMetaData.Text,
+		Latitude:  lat,
+		Longitude: lon,
+	}, nil
+}
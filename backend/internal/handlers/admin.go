@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/models"
+	"net/http"
+)
+
+// PurgeDriverHandler окончательно удаляет архивного водителя вместе с его
+// маршрутами и историей выполнения.
+func PurgeDriverHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if err := models.PurgeDriver(r.Context(), id); err != nil {
+		apierror.Write(w, r, archiveError(err, "driver.not_found", "driver.not_archived", "driver.delete_failed"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// PurgePointHandler окончательно удаляет архивную точку вместе с её
+// маршрутами и историей выполнения.
+func PurgePointHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if err := models.PurgePoint(r.Context(), id); err != nil {
+		apierror.Write(w, r, archiveError(err, "point.not_found", "point.not_archived", "point.delete_failed"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// archiveError — как apierror.FromDB, но запись не в архиве — 409
+// с сообщением notArchived.
+func archiveError(err error, notFound, notArchived, fallback string) *apierror.Error {
+	if errors.Is(err, models.ErrNotArchived) {
+		return apierror.Conflict(notArchived)
+	}
+	return apierror.FromDB(err, notFound, fallback)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/listquery"
+	"garbage_trucks/backend/internal/models"
+	"net/http"
+	"strings"
+)
+
+// GetAuditHandler возвращает страницу журнала изменений.
+// Фильтры: entity, entity_id, actor, action (через запятую), from, to
+// (RFC 3339 или YYYY-MM-DD); постраничность: limit, cursor, sort.
+func GetAuditHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	params, err := listquery.Parse(q, models.AuditListSpec)
+	if err != nil {
+		apierror.Write(w, r, apierror.FromError(http.StatusBadRequest, apierror.CodeBadRequest, err))
+		return
+	}
+
+	filter := models.AuditFilter{Entity: q.Get("entity"), Actor: q.Get("actor")}
+	if filter.EntityID, err = optionalIntParam(q, "entity_id"); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if filter.From, err = optionalTimeParam(q, "from", false); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if filter.To, err = optionalTimeParam(q, "to", true); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if actions := q.Get("action"); actions != "" {
+		for _, a := range strings.Split(actions, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				filter.Actions = append(filter.Actions, a)
+			}
+		}
+	}
+
+	page, err := models.ListAudit(r.Context(), filter, params)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("audit.list_failed", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
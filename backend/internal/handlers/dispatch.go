@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"errors"
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/models"
+	"net/http"
+	"time"
+)
+
+// GetDispatchOverviewHandler возвращает обзор диспетчера за день: по
+// каждому водителю счётчики остановок, текущую и следующую остановку с
+// ожидаемым временем прибытия, последнее положение и отставание от плана.
+//
+// Параметры: date (YYYY-MM-DD; по умолчанию — сегодня).
+func GetDispatchOverviewHandler(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	day, err := dayParam(r.URL.Query(), "date", now)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	overview, err := models.GetDispatchOverview(r.Context(), day, now)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("dispatch.overview_failed", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, overview)
+}
+
+// RecordDriverPositionHandler сохраняет GPS-отметку водителя от трекера
+// или мобильного приложения: POST /api/drivers/{id}/position.
+func RecordDriverPositionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	var req positionInput
+	if err := decodeJSON(r, &req); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("error.invalid_body"))
+		return
+	}
+
+	v := newValidator(r)
+	req.validate(v)
+	if err := v.err(); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	position, err := models.RecordDriverPosition(r.Context(), id, *req.Latitude, *req.Longitude, req.RecordedAt)
+	if err != nil {
+		apierror.Write(w, r, apierror.FromDB(err, "driver.not_found", "driver.position_failed"))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, position)
+}
+
+// ProposeRerouteHandler предлагает, кому передать оставшиеся остановки
+// водителя, сошедшего с линии: GET /api/drivers/{id}/reroute?date=.
+// Ничего не меняет; предложение применяется через POST по тому же пути.
+func ProposeRerouteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	now := time.Now()
+	day, err := dayParam(r.URL.Query(), "date", now)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if _, err := models.GetDriverByID(r.Context(), id); err != nil {
+		apierror.Write(w, r, apierror.FromDB(err, "driver.not_found", "reroute.failed"))
+		return
+	}
+
+	plan, err := models.ProposeReroute(r.Context(), id, day, now)
+	if err != nil {
+		apierror.Write(w, r, rerouteError(err, "reroute.failed"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// ApplyRerouteHandler передаёт остановки водителя другим водителям одной
+// транзакцией: POST /api/drivers/{id}/reroute. В теле — назначения из
+// предложения (диспетчер может их поправить) с версиями остановок; если
+// остановку успели начать или изменить, ничего не применяется.
+func ApplyRerouteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	var req rerouteInput
+	if err := decodeJSON(r, &req); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("error.invalid_body"))
+		return
+	}
+
+	now := time.Now()
+	v := newValidator(r)
+	day := req.validate(v, id, now)
+	if err := v.err(); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	moves := make([]models.RerouteMove, len(req.Assignments))
+	for i, a := range req.Assignments {
+		moves[i] = models.RerouteMove{RouteID: a.RouteID, Version: a.Version, DriverID: a.DriverID}
+	}
+
+	plan, err := models.ApplyReroute(r.Context(), id, day, now, moves)
+	if err != nil {
+		apierror.Write(w, r, rerouteError(err, "reroute.apply_failed"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// rerouteError переводит ошибки перераспределения в ответы API.
+func rerouteError(err error, fallback string) *apierror.Error {
+	switch {
+	case errors.Is(err, models.ErrRerouteNoDrivers):
+		return apierror.Conflict("reroute.no_drivers")
+	case errors.Is(err, models.ErrRerouteStale):
+		return apierror.Conflict("reroute.stale")
+	case errors.Is(err, models.ErrRerouteTarget):
+		return apierror.Conflict("reroute.driver_not_on_shift")
+	}
+	return versionError(err, "driver.not_found", fallback)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/geo"
+	"garbage_trucks/backend/internal/listquery"
+	"garbage_trucks/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// MaxGeoJSONSize — ограничение на размер загружаемого GeoJSON; действует
+// через middleware.BodyLimit, до проверки тела по openapi.json
+const MaxGeoJSONSize = 20 << 20
+
+func GetDistrictsHandler(w http.ResponseWriter, r *http.Request) {
+	withGeometry := r.URL.Query().Get("geometry") == "true"
+
+	districts, err := models.GetAllDistricts(r.Context(), withGeometry)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("district.list_failed", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, districts)
+}
+
+// ImportDistrictsHandler загружает полигоны из GeoJSON (FeatureCollection
+// или одиночный Feature). Имя берётся из свойства name, вид — из параметра
+// kind (district или service_area) либо из свойства kind. Полигоны
+// сохраняются и районы всех точек пересчитываются одной транзакцией.
+func ImportDistrictsHandler(w http.ResponseWriter, r *http.Request) {
+	var raw struct {
+		geo.FeatureCollection
+		Properties map[string]interface{} `json:"properties"`
+		Geometry   *geo.Geometry          `json:"geometry"`
+	}
+	if err := decodeJSON(r, &raw); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("district.invalid_geojson"))
+		return
+	}
+
+	var features []geo.Feature
+	switch raw.Type {
+	case "FeatureCollection":
+		features = raw.Features
+	case "Feature":
+		features = []geo.Feature{{Type: raw.Type, Properties: raw.Properties, Geometry: raw.Geometry}}
+	default:
+		apierror.Write(w, r, apierror.BadRequest("district.expected_feature"))
+		return
+	}
+	if len(features) == 0 {
+		apierror.Write(w, r, apierror.Validation("district.empty"))
+		return
+	}
+
+	defaultKind := r.URL.Query().Get("kind")
+	if defaultKind == "" {
+		defaultKind = models.DistrictKindDistrict
+	}
+
+	v := newValidator(r)
+	seen := map[string]bool{}
+	var parsed []models.DistrictImport
+	for i, f := range features {
+		field := "features[" + strconv.Itoa(i) + "]"
+
+		name, _ := f.Properties["name"].(string)
+		name = strings.TrimSpace(name)
+		if name == "" {
+			v.add(field+".properties.name", apierror.FieldRequired, "district.name_missing", i+1)
+		}
+		v.maxLen(field+".properties.name", name, models.DistrictNameMaxLen)
+
+		kind, _ := f.Properties["kind"].(string)
+		if kind == "" {
+			kind = defaultKind
+		}
+		if kind != models.DistrictKindDistrict && kind != models.DistrictKindServiceArea {
+			v.add(field+".properties.kind", apierror.FieldInvalid, "district.invalid_kind")
+		}
+
+		// Повтор имени в одном файле перезаписал бы первый объект
+		if name != "" {
+			if seen[kind+"/"+name] {
+				v.add(field+".properties.name", apierror.FieldDuplicate, "district.duplicate_name", name)
+			}
+			seen[kind+"/"+name] = true
+		}
+
+		shape, err := geo.ParseMultiPolygon(f.Geometry)
+		if err != nil {
+			v.add(field+".geometry", apierror.FieldInvalid, "district.invalid_geometry", name, err)
+		}
+		parsed = append(parsed, models.DistrictImport{Name: name, Kind: kind, Shape: shape})
+	}
+	if err := v.err(); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	imported, assigned, err := models.ImportDistricts(r.Context(), parsed)
+	if err != nil {
+		apierror.Write(w, r, apierror.FromDB(err, "district.not_found", "district.save_failed"))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"imported":        imported,
+		"assigned_points": assigned,
+	})
+}
+
+func DeleteDistrictHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	if err := models.DeleteDistrict(r.Context(), id); err != nil {
+		apierror.Write(w, r, apierror.FromDB(err, "district.not_found", "district.delete_failed"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+func GetDistrictPointsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	points, err := models.GetPointsByDistrict(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, apierror.FromDB(err, "district.not_found", "point.list_failed"))
+		return
+	}
+	if points == nil {
+		points = []models.CollectionPoint{}
+	}
+
+	writeJSON(w, http.StatusOK, &listquery.Page{Items: points, Total: len(points)})
+}
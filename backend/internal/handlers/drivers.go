@@ -1,109 +1,158 @@
 package handlers
 
 import (
-	"context"
-	"encoding/json"
-	"net/http"
-	"strconv"
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/listquery"
 	"garbage_trucks/backend/internal/models"
-	"github.com/gorilla/mux"
+	"net/http"
 )
 
+// GetDriversHandler возвращает страницу списка водителей.
+// Фильтры: name, created_from, created_to, archived (exclude — по умолчанию,
+// include, only); постраничность: limit, cursor, sort.
 func GetDriversHandler(w http.ResponseWriter, r *http.Request) {
-	drivers, err := models.GetAllDrivers(context.Background())
+	q := r.URL.Query()
+
+	params, err := listquery.Parse(q, models.DriverListSpec)
 	if err != nil {
-		http.Error(w, "Ошибка получения водителей", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.FromError(http.StatusBadRequest, apierror.CodeBadRequest, err))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(drivers); err != nil {
-		http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
+	filter := models.DriverFilter{Name: q.Get("name"), Archived: q.Get("archived")}
+	if filter.CreatedFrom, err = optionalDateParam(q, "created_from", false); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if filter.CreatedTo, err = optionalDateParam(q, "created_to", true); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	page, err := models.ListDrivers(r.Context(), filter, params)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("driver.list_failed", err))
+		return
 	}
+
+	writeJSON(w, http.StatusOK, page)
 }
 
-func CreateDriverHandler(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Name string `json:"name"`
+// GetDriverHandler возвращает водителя, в том числе архивного, с версией
+// в ETag; при совпадении If-None-Match отвечает 304.
+func GetDriverHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	driver, err := models.GetDriverByID(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, apierror.FromDB(err, "driver.not_found", "driver.get_failed"))
+		return
+	}
+	if notModified(w, r, driver.Version) {
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+	setETag(w, driver.Version)
+	writeJSON(w, http.StatusOK, driver)
+}
+
+func CreateDriverHandler(w http.ResponseWriter, r *http.Request) {
+	var req driverInput
+	if err := decodeJSON(r, &req); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("error.invalid_body"))
 		return
 	}
 
-	if req.Name == "" {
-		http.Error(w, "Имя обязательно", http.StatusBadRequest)
+	v := newValidator(r)
+	req.validate(v)
+	if err := v.err(); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	driver, err := models.CreateDriver(context.Background(), req.Name)
+	driver, err := models.CreateDriver(r.Context(), req.Name)
 	if err != nil {
-		http.Error(w, "Ошибка создания водителя", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.FromDB(err, "driver.not_found", "driver.create_failed"))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(driver); err != nil {
-		http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
-	}
+	setETag(w, driver.Version)
+	writeJSON(w, http.StatusCreated, driver)
 }
 
+// DeleteDriverHandler переносит водителя в архив. Окончательно удаляет
+// водителя только администратор: DELETE /api/admin/drivers/{id}.
 func DeleteDriverHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
 
-	id, err := strconv.Atoi(idStr)
+	err = models.ArchiveDriver(r.Context(), id)
 	if err != nil {
-		http.Error(w, "id должен быть числом", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.FromDB(err, "driver.not_found", "driver.delete_failed"))
 		return
 	}
 
-	err = models.DeleteDriver(context.Background(), id)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+func RestoreDriverHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
 	if err != nil {
-		http.Error(w, "Ошибка удаления водителя", http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "success"}); err != nil {
-		http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
+	driver, err := models.RestoreDriver(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, archiveError(err, "driver.not_found", "driver.not_archived", "driver.restore_failed"))
+		return
 	}
+
+	setETag(w, driver.Version)
+	writeJSON(w, http.StatusOK, driver)
 }
 
+// UpdateDriverHandler изменяет водителя. С заголовком If-Match изменение
+// выполняется, только если версия водителя не изменилась, иначе — 412.
 func UpdateDriverHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	idStr := vars["id"]
-
-	id, err := strconv.Atoi(idStr)
+	id, err := pathID(r)
 	if err != nil {
-		http.Error(w, "id должен быть числом", http.StatusBadRequest)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	var req struct {
-		Name string `json:"name"`
+	ifVersion, apiErr := ifMatchVersion(r)
+	if apiErr != nil {
+		apierror.Write(w, r, apiErr)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+	var req driverInput
+	if err := decodeJSON(r, &req); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("error.invalid_body"))
 		return
 	}
 
-	if req.Name == "" {
-		http.Error(w, "Имя обязательно", http.StatusBadRequest)
+	v := newValidator(r)
+	req.validate(v)
+	if err := v.err(); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	driver, err := models.UpdateDriver(context.Background(), id, req.Name)
+	driver, err := models.UpdateDriver(r.Context(), id, req.Name, ifVersion)
 	if err != nil {
-		http.Error(w, "Ошибка обновления водителя", http.StatusInternalServerError)
+		apierror.Write(w, r, versionError(err, "driver.not_found", "driver.update_failed"))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(driver); err != nil {
-		http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
-	}
-}
\ No newline at end of file
+	setETag(w, driver.Version)
+	writeJSON(w, http.StatusOK, driver)
+}
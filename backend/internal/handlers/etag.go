@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag — значение заголовка ETag для версии записи.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag выставляет ETag ответа по версии записи.
+func setETag(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// notModified отвечает 304, если If-None-Match совпадает с версией записи.
+func notModified(w http.ResponseWriter, r *http.Request, version int) bool {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" {
+		return false
+	}
+	for _, tag := range strings.Split(inm, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			setETag(w, version)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersion читает версию из If-Match. Без заголовка или со значением
+// "*" возвращает nil — запись изменяется без проверки. ETag, который сервер
+// не выдавал, ни с какой версией не совпадёт, поэтому сразу даёт 412.
+// If-Match сравнивается строго (RFC 9110, 13.1.1): слабый ETag W/"…"
+// не совпадает ни с одной версией.
+func ifMatchVersion(r *http.Request) (*int, *apierror.Error) {
+	s := strings.TrimSpace(r.Header.Get("If-Match"))
+	if s == "" || s == "*" {
+		return nil, nil
+	}
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return nil, apierror.PreconditionFailed("error.version_mismatch")
+	}
+	version, err := strconv.Atoi(s[1 : len(s)-1])
+	if err != nil {
+		return nil, apierror.PreconditionFailed("error.version_mismatch")
+	}
+	return &version, nil
+}
+
+// versionError — как apierror.FromDB, но устаревшая версия из If-Match —
+// 412 Precondition Failed.
+func versionError(err error, notFound, fallback string) *apierror.Error {
+	if errors.Is(err, models.ErrVersionMismatch) {
+		return apierror.PreconditionFailed("error.version_mismatch")
+	}
+	return apierror.FromDB(err, notFound, fallback)
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		version *int
+		failed  bool
+	}{
+		{header: ""},
+		{header: "*"},
+		{header: `"7"`, version: intPtr(7)},
+		{header: ` "7" `, version: intPtr(7)},
+		// Слабое сравнение для If-Match запрещено RFC 9110
+		{header: `W/"7"`, failed: true},
+		{header: `"x"`, failed: true},
+		{header: `7`, failed: true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/api/points/1", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+		version, apiErr := ifMatchVersion(r)
+		if tt.failed {
+			if apiErr == nil || apiErr.Status != http.StatusPreconditionFailed {
+				t.Errorf("If-Match %q: ждали 412, получили %v", tt.header, apiErr)
+			}
+			continue
+		}
+		if apiErr != nil {
+			t.Errorf("If-Match %q: ошибка %v", tt.header, apiErr)
+			continue
+		}
+		if (version == nil) != (tt.version == nil) || version != nil && *version != *tt.version {
+			t.Errorf("If-Match %q: версия %v, ждали %v", tt.header, version, tt.version)
+		}
+	}
+}
+
+func intPtr(v int) *int { return &v }
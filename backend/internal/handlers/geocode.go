@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/geo"
+	"garbage_trucks/backend/internal/geocoding"
+	"garbage_trucks/backend/internal/models"
+	"net/http"
+	"strconv"
+)
+
+// GeocodeHandler ищет координаты по адресу: /api/geocode?address=
+func GeocodeHandler(w http.ResponseWriter, r *http.Request) {
+	address := r.URL.Query().Get("address")
+	if address == "" {
+		apierror.Write(w, r, apierror.BadRequest("param.required", "address"))
+		return
+	}
+	v := newValidator(r)
+	v.maxLen("address", address, models.PointAddressMaxLen)
+	if err := v.err(); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	res, err := geocoding.Geocode(r.Context(), address)
+	if err != nil {
+		apierror.Write(w, r, geocodeLookupError(err))
+		return
+	}
+
+	writeGeocodeResult(w, res)
+}
+
+// ReverseGeocodeHandler ищет адрес по координатам: /api/geocode/reverse?lat=&lon=
+func ReverseGeocodeHandler(w http.ResponseWriter, r *http.Request) {
+	lat, errLat := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	lon, errLon := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
+	if errLat != nil || errLon != nil || !geo.ValidCoordinates(lat, lon) {
+		apierror.Write(w, r, apierror.BadRequest("param.invalid_coordinates"))
+		return
+	}
+
+	res, err := geocoding.Reverse(r.Context(), lat, lon)
+	if err != nil {
+		apierror.Write(w, r, geocodeLookupError(err))
+		return
+	}
+
+	writeGeocodeResult(w, res)
+}
+
+// geocodeLookupError — для прямого поиска "не найдено" означает 404,
+// а не ошибку данных точки, как при сохранении.
+func geocodeLookupError(err error) *apierror.Error {
+	apiErr := geocodingError(err, "geocoding.address_not_found")
+	if apiErr.Code == apierror.CodeValidation {
+		return apierror.NotFound(apiErr.Key, apiErr.Args...)
+	}
+	return apiErr
+}
+
+func writeGeocodeResult(w http.ResponseWriter, res *geocoding.Result) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"address":         res.Address,
+		"latitude":        res.Latitude,
+		"longitude":       res.Longitude,
+		"in_service_area": geocoding.InServiceArea(res.Latitude, res.Longitude),
+	})
+}
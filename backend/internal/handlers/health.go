@@ -1,15 +1,83 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/i18n"
 	"net/http"
+	"time"
 )
 
+// Сколько ждать базу при проверке готовности: балансировщик опрашивает
+// /readyz часто, и зависший запрос хуже быстрого отказа
+const readinessTimeout = 2 * time.Second
+
 func HealthHandler(w http.ResponseWriter, r *http.Request) {
 	response := map[string]string{
 		"status":  "ok",
-		"message": "Garbage Trucks API работает!",
+		"message": i18n.T(i18n.FromContext(r.Context()), "health.ok"),
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// LivenessHandler отвечает, что процесс жив и обрабатывает запросы.
+// Базу не проверяет: её недоступность — повод не слать трафик (/readyz),
+// а не перезапускать сервис.
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readinessCheck — результат одной проверки готовности.
+type readinessCheck struct {
+	Status  string   `json:"status"` // ok или fail
+	Message string   `json:"message,omitempty"`
+	Pending []string `json:"pending,omitempty"`
+}
+
+// ReadinessHandler проверяет, что сервис может обслуживать запросы: база
+// отвечает и все встроенные миграции применены. Иначе — 503. Состояние
+// реплики показывается, но на готовность не влияет: отчёты медленнее,
+// но работают и без неё.
+func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	lang := i18n.FromContext(r.Context())
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]readinessCheck{}
+	ready := true
+
+	if err := database.Ping(ctx); err != nil {
+		checks["database"] = readinessCheck{Status: "fail", Message: i18n.T(lang, "health.database_unavailable")}
+		checks["migrations"] = readinessCheck{Status: "fail", Message: i18n.T(lang, "health.migrations_unknown")}
+		ready = false
+	} else {
+		checks["database"] = readinessCheck{Status: "ok"}
+
+		pending, err := database.PendingMigrations(ctx)
+		switch {
+		case err != nil:
+			checks["migrations"] = readinessCheck{Status: "fail", Message: i18n.T(lang, "health.migrations_unknown")}
+			ready = false
+		case len(pending) > 0:
+			checks["migrations"] = readinessCheck{Status: "fail", Message: i18n.T(lang, "health.migrations_pending"), Pending: pending}
+			ready = false
+		default:
+			checks["migrations"] = readinessCheck{Status: "ok"}
+		}
+	}
+
+	if used, err := database.PingReplica(ctx); used {
+		if err != nil {
+			checks["replica"] = readinessCheck{Status: "fail", Message: i18n.T(lang, "health.replica_unavailable")}
+		} else {
+			checks["replica"] = readinessCheck{Status: "ok"}
+		}
+	}
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "not_ready", http.StatusServiceUnavailable
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
\ No newline at end of file
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, code, map[string]interface{}{"status": status, "checks": checks})
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"garbage_trucks/backend/internal/apierror"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// optionalIntParam читает необязательный целочисленный параметр запроса.
+func optionalIntParam(q url.Values, name string) (*int, error) {
+	s := q.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, apierror.BadRequest("param.not_integer", name)
+	}
+	return &v, nil
+}
+
+// optionalDateParam читает необязательную дату YYYY-MM-DD. Для верхней
+// границы диапазона (inclusiveEnd) возвращается начало следующего дня,
+// чтобы в запросе можно было использовать строгое "<".
+func optionalDateParam(q url.Values, name string, inclusiveEnd bool) (*time.Time, error) {
+	s := q.Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(reportDateLayout, s, time.Local)
+	if err != nil {
+		return nil, apierror.BadRequest("param.not_date", name)
+	}
+	if inclusiveEnd {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// dayParam читает день YYYY-MM-DD; без параметра — день now.
+func dayParam(q url.Values, name string, now time.Time) (time.Time, error) {
+	day, err := optionalDateParam(q, name, false)
+	if err != nil || day == nil {
+		return now, err
+	}
+	return *day, nil
+}
+
+// pathID читает числовой параметр {id} из пути.
+func pathID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, apierror.BadRequest("param.not_integer", "id")
+	}
+	return id, nil
+}
+
+// optionalTimeParam читает необязательный момент времени: RFC 3339 или,
+// как optionalDateParam, дату YYYY-MM-DD.
+func optionalTimeParam(q url.Values, name string, inclusiveEnd bool) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, q.Get(name)); err == nil {
+		return &t, nil
+	}
+	return optionalDateParam(q, name, inclusiveEnd)
+}
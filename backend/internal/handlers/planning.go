@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/models"
+	"net/http"
+	"time"
+)
+
+// PlanCityHandler строит черновик маршрутов всего города на день:
+// POST /api/dispatch/plan. Точки раздаются машинам с учётом вместимости
+// и смены за отведённое время; маршруты в базе не создаются — черновик
+// возвращается диспетчеру на проверку.
+func PlanCityHandler(w http.ResponseWriter, r *http.Request) {
+	var in planInput
+	if err := decodeJSON(r, &in); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("error.invalid_body"))
+		return
+	}
+
+	v := newValidator(r)
+	req, err := in.request(r.Context(), v, time.Now())
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("plan.failed", err))
+		return
+	}
+	if err := v.err(); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	draft, err := models.PlanCity(r.Context(), req)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("plan.failed", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, draft)
+}
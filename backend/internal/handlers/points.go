@@ -2,141 +2,318 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"errors"
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/geo"
+	"garbage_trucks/backend/internal/geocoding"
+	"garbage_trucks/backend/internal/listquery"
+	"garbage_trucks/backend/internal/models"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"garbage_trucks/backend/internal/models"
-	"github.com/gorilla/mux"
 )
 
+// Ограничения для поиска точек поблизости, метры
+const (
+	defaultNearbyRadius = 1000
+	maxNearbyRadius     = 50000
+)
+
+// GetPointsHandler возвращает страницу списка точек.
+// Фильтры: city, name (поиск по названию и адресу), district_id, driver_id,
+// archived (exclude — по умолчанию, include, only); постраничность: limit,
+// cursor, sort. С параметром bbox возвращаются до limit действующих точек
+// области, ближайшие к её центру первыми.
 func GetPointsHandler(w http.ResponseWriter, r *http.Request) {
-	points, err := models.GetAllPoints(context.Background())
+	q := r.URL.Query()
+
+	if bboxStr := q.Get("bbox"); bboxStr != "" {
+		bbox, err := geo.ParseBBox(bboxStr)
+		if err != nil {
+			apierror.Write(w, r, apierror.FromError(http.StatusBadRequest, apierror.CodeBadRequest, err))
+			return
+		}
+		limit, err := listquery.ParseLimit(q, models.PointListSpec)
+		if err != nil {
+			apierror.Write(w, r, apierror.FromError(http.StatusBadRequest, apierror.CodeBadRequest, err))
+			return
+		}
+		points, err := models.FindPointsInBBox(r.Context(), bbox, limit)
+		if err != nil {
+			apierror.Write(w, r, apierror.Internal("point.list_failed", err))
+			return
+		}
+		writeJSON(w, http.StatusOK, &listquery.Page{Items: points, Total: len(points)})
+		return
+	}
+
+	params, err := listquery.Parse(q, models.PointListSpec)
 	if err != nil {
-		http.Error(w, "Ошибка получения точек", http.StatusInternalServerError)
+		apierror.Write(w, r, apierror.FromError(http.StatusBadRequest, apierror.CodeBadRequest, err))
+		return
+	}
+
+	filter := models.PointFilter{City: q.Get("city"), Name: q.Get("name"), Archived: q.Get("archived")}
+	if filter.DistrictID, err = optionalIntParam(q, "district_id"); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if filter.DriverID, err = optionalIntParam(q, "driver_id"); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(points); err != nil {
-		http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
+	page, err := models.ListPoints(r.Context(), filter, params)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("point.list_failed", err))
+		return
 	}
+
+	writeJSON(w, http.StatusOK, page)
 }
 
-func CreatePointHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+// GetNearbyPointsHandler ищет точки в радиусе от заданных координат:
+// /api/points/nearby?lat=&lon=&radius=&limit=, ближайшие первыми. limit —
+// как у списка точек: по умолчанию DefaultLimit, не больше MaxLimit.
+func GetNearbyPointsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	lat, errLat := strconv.ParseFloat(q.Get("lat"), 64)
+	lon, errLon := strconv.ParseFloat(q.Get("lon"), 64)
+	if errLat != nil || errLon != nil || !geo.ValidCoordinates(lat, lon) {
+		apierror.Write(w, r, apierror.BadRequest("param.invalid_coordinates"))
 		return
 	}
 
-	var req struct {
-		Name      string  `json:"name"`
-		Address   string  `json:"address"`
-		Latitude  float64 `json:"latitude"`
-		Longitude float64 `json:"longitude"`
-		City      string  `json:"city"`
-		DriverIDs []int   `json:"driver_ids"`
+	radius := float64(defaultNearbyRadius)
+	if radiusStr := q.Get("radius"); radiusStr != "" {
+		var err error
+		radius, err = strconv.ParseFloat(radiusStr, 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadius {
+			apierror.Write(w, r, apierror.BadRequest("param.invalid_radius", maxNearbyRadius))
+			return
+		}
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Error decoding request: %v", err)
-		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+	limit, err := listquery.ParseLimit(q, models.PointListSpec)
+	if err != nil {
+		apierror.Write(w, r, apierror.FromError(http.StatusBadRequest, apierror.CodeBadRequest, err))
 		return
 	}
 
-	log.Printf("Creating point: %s, drivers: %v, len: %d", req.Name, req.DriverIDs, len(req.DriverIDs))
-
-	if req.Name == "" || req.Address == "" {
-		http.Error(w, "Название и адрес обязательны", http.StatusBadRequest)
+	points, err := models.FindPointsNearby(r.Context(), lat, lon, radius, limit)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("point.search_failed", err))
 		return
 	}
 
-	point, err := models.CreatePointWithDrivers(context.Background(), req.Name, req.Address, req.City, req.Latitude, req.Longitude, req.DriverIDs)
+	writeJSON(w, http.StatusOK, &listquery.Page{Items: points, Total: len(points)})
+}
+
+// GetPointHandler возвращает точку, в том числе архивную, с версией
+// в ETag; при совпадении If-None-Match отвечает 304.
+func GetPointHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
 	if err != nil {
-		log.Printf("Error creating point: %v", err)
-		http.Error(w, "Ошибка создания точки", http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(point); err != nil {
-		http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
+	point, err := models.GetPointByID(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, apierror.FromDB(err, "point.not_found", "point.get_failed"))
+		return
+	}
+	if notModified(w, r, point.Version) {
+		return
 	}
+
+	setETag(w, point.Version)
+	writeJSON(w, http.StatusOK, point)
 }
 
-func DeletePointHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "DELETE" {
-		http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+func CreatePointHandler(w http.ResponseWriter, r *http.Request) {
+	var req pointInput
+	if err := decodeJSON(r, &req); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("error.invalid_body"))
 		return
 	}
 
-	vars := mux.Vars(r)
-	idStr := vars["id"]
+	slog.DebugContext(r.Context(), "Создание точки", "name", req.Name, "driver_ids", req.DriverIDs)
+
+	v := newValidator(r)
+	req.validate(v)
+	if req.ContainerCount != nil && *req.ContainerCount < 0 {
+		v.add("container_count", apierror.FieldOutOfRange, "point.container_count_negative")
+	}
+	if err := checkPointInput(r.Context(), v, &req); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	containerCount := 1
+	if req.ContainerCount != nil && *req.ContainerCount > 0 {
+		containerCount = *req.ContainerCount
+	}
 
-	id, err := strconv.Atoi(idStr)
+	point, err := models.CreatePointWithDrivers(r.Context(), req.Name, req.Address, req.City, req.Latitude, req.Longitude, containerCount, req.PointRestrictions, req.DriverIDs)
 	if err != nil {
-		http.Error(w, "id должен быть числом", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.FromDB(err, "point.not_found", "point.create_failed"))
 		return
 	}
 
-	err = models.DeletePoint(context.Background(), id)
+	setETag(w, point.Version)
+	writeJSON(w, http.StatusCreated, point)
+}
+
+// DeletePointHandler переносит точку в архив. Окончательно удаляет точку
+// только администратор: DELETE /api/admin/points/{id}.
+func DeletePointHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
 	if err != nil {
-		http.Error(w, "Ошибка удаления точки", http.StatusInternalServerError)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "success"}); err != nil {
-		http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
+	err = models.ArchivePoint(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, apierror.FromDB(err, "point.not_found", "point.delete_failed"))
+		return
 	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
 
-func UpdatePointHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "PUT" {
-		http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+func RestorePointHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	point, err := models.RestorePoint(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, archiveError(err, "point.not_found", "point.not_archived", "point.restore_failed"))
 		return
 	}
 
-	vars := mux.Vars(r)
-	idStr := vars["id"]
+	setETag(w, point.Version)
+	writeJSON(w, http.StatusOK, point)
+}
 
-	id, err := strconv.Atoi(idStr)
+// UpdatePointHandler изменяет точку. С заголовком If-Match изменение
+// выполняется, только если версия точки не изменилась, иначе — 412.
+func UpdatePointHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
 	if err != nil {
-		http.Error(w, "id должен быть числом", http.StatusBadRequest)
+		apierror.Write(w, r, err)
 		return
 	}
 
-	var req struct {
-		Name      string  `json:"name"`
-		Address   string  `json:"address"`
-		Latitude  float64 `json:"latitude"`
-		Longitude float64 `json:"longitude"`
-		City      string  `json:"city"`
-		DriverIDs []int   `json:"driver_ids"`
+	ifVersion, apiErr := ifMatchVersion(r)
+	if apiErr != nil {
+		apierror.Write(w, r, apiErr)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+	var req pointInput
+	if err := decodeJSON(r, &req); err != nil {
+		apierror.Write(w, r, apierror.BadRequest("error.invalid_body"))
 		return
 	}
 
-	log.Printf("Updating point %d: %s, drivers: %v", id, req.Name, req.DriverIDs)
+	slog.DebugContext(r.Context(), "Изменение точки", "point_id", id, "name", req.Name, "driver_ids", req.DriverIDs)
 
-	if req.Name == "" || req.Address == "" {
-		http.Error(w, "Название и адрес обязательны", http.StatusBadRequest)
+	v := newValidator(r)
+	req.validate(v)
+	// Если container_count не передан, сохраняем текущее значение
+	if req.ContainerCount != nil && *req.ContainerCount <= 0 {
+		v.add("container_count", apierror.FieldOutOfRange, "point.container_count_positive")
+	}
+	if err := checkPointInput(r.Context(), v, &req); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
 
-	point, err := models.UpdatePointWithDrivers(context.Background(), id, req.Name, req.Address, req.City, req.Latitude, req.Longitude, req.DriverIDs)
+	point, err := models.UpdatePointWithDrivers(r.Context(), id, req.Name, req.Address, req.City, req.Latitude, req.Longitude, req.ContainerCount, req.PointRestrictions, req.DriverIDs, ifVersion)
 	if err != nil {
-		log.Printf("Error updating point: %v", err)
-		http.Error(w, "Ошибка обновления точки", http.StatusInternalServerError)
+		apierror.Write(w, r, versionError(err, "point.not_found", "point.update_failed"))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(point); err != nil {
-		http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
+	setETag(w, point.Version)
+	writeJSON(w, http.StatusOK, point)
+}
+
+// checkPointInput завершает проверку точки после validate: существование
+// водителей, геокодирование недостающих адреса или координат и зона
+// обслуживания. Проверки с обращением к базе и геокодеру выполняются,
+// только если в самих данных ошибок нет.
+func checkPointInput(ctx context.Context, v *validator, in *pointInput) *apierror.Error {
+	if err := v.err(); err != nil {
+		return err
+	}
+
+	if err := v.driversExist(ctx, "driver_ids", in.DriverIDs); err != nil {
+		return apierror.Internal("point.driver_check_failed", err)
+	}
+	if apiErr := resolvePointLocation(ctx, v, in); apiErr != nil {
+		return apiErr
+	}
+	if !v.has("address") {
+		v.maxLen("address", in.Address, models.PointAddressMaxLen)
+	}
+	return v.err()
+}
+
+// resolvePointLocation дополняет недостающие координаты или адрес точки
+// через геокодер и проверяет, что точка попадает в зону обслуживания.
+// Ошибки данных добавляются в v; возвращаются только ошибки сервисов.
+func resolvePointLocation(ctx context.Context, v *validator, in *pointInput) *apierror.Error {
+	switch {
+	case !in.hasCoordinates() && in.Address == "":
+		v.add("address", apierror.FieldRequired, "point.location_required")
+		return nil
+
+	case !in.hasCoordinates():
+		res, err := geocoding.Geocode(ctx, in.Address)
+		if err != nil {
+			return locationGeocodingError(v, "address", err, "point.geocode_failed")
+		}
+		in.Latitude, in.Longitude = res.Latitude, res.Longitude
+
+	case in.Address == "":
+		res, err := geocoding.Reverse(ctx, in.Latitude, in.Longitude)
+		if err != nil {
+			return locationGeocodingError(v, "latitude", err, "point.reverse_failed")
+		}
+		in.Address = res.Address
+	}
+
+	if err := validateLocation(ctx, v, in.Latitude, in.Longitude); err != nil {
+		return apierror.Internal("point.service_area_check_failed", err)
+	}
+	return nil
+}
+
+// locationGeocodingError превращает "не найдено" и "геокодер не настроен"
+// в ошибку поля, а сбои сервиса возвращает как ошибку запроса.
+func locationGeocodingError(v *validator, field string, err error, notFoundKey string) *apierror.Error {
+	apiErr := geocodingError(err, notFoundKey)
+	if apiErr.Code != apierror.CodeValidation {
+		return apiErr
+	}
+	v.add(field, apierror.FieldGeocodingFailed, apiErr.Key, apiErr.Args...)
+	return nil
+}
+
+func geocodingError(err error, notFoundMsg string) *apierror.Error {
+	switch {
+	case errors.Is(err, geocoding.ErrDisabled):
+		return apierror.Validation("geocoding.disabled")
+	case errors.Is(err, geocoding.ErrNotFound):
+		return apierror.Validation(notFoundMsg)
+	default:
+		return &apierror.Error{Status: http.StatusBadGateway, Code: apierror.CodeBadGateway, Key: "geocoding.upstream_failed", Err: err}
 	}
 }
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/i18n"
+	"garbage_trucks/backend/internal/models"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const reportDateLayout = "2006-01-02"
+
+// GetCompletionReportHandler возвращает процент выполненных, пропущенных и
+// проблемных остановок с группировкой по дате, водителю, городу и району.
+//
+// Параметры: from, to (YYYY-MM-DD, включительно; по умолчанию — сегодня),
+// driver_id, city, district_id, group_by (через запятую: date, driver,
+// city, district; по умолчанию driver,city), format (json или csv).
+func GetCompletionReportHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	today := time.Now().Format(reportDateLayout)
+	fromStr := q.Get("from")
+	if fromStr == "" {
+		fromStr = today
+	}
+	toStr := q.Get("to")
+	if toStr == "" {
+		toStr = fromStr
+	}
+
+	from, err := time.ParseInLocation(reportDateLayout, fromStr, time.Local)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("param.not_date", "from"))
+		return
+	}
+	to, err := time.ParseInLocation(reportDateLayout, toStr, time.Local)
+	if err != nil {
+		apierror.Write(w, r, apierror.BadRequest("param.not_date", "to"))
+		return
+	}
+	if to.Before(from) {
+		apierror.Write(w, r, apierror.BadRequest("param.range_reversed"))
+		return
+	}
+
+	filter := models.CompletionReportFilter{
+		From: from,
+		To:   to.AddDate(0, 0, 1),
+		City: q.Get("city"),
+	}
+
+	if driverIDStr := q.Get("driver_id"); driverIDStr != "" {
+		driverID, err := strconv.Atoi(driverIDStr)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("param.not_integer", "driver_id"))
+			return
+		}
+		filter.DriverID = &driverID
+	}
+
+	if districtIDStr := q.Get("district_id"); districtIDStr != "" {
+		districtID, err := strconv.Atoi(districtIDStr)
+		if err != nil {
+			apierror.Write(w, r, apierror.BadRequest("param.not_integer", "district_id"))
+			return
+		}
+		filter.DistrictID = &districtID
+	}
+
+	groupBy := q.Get("group_by")
+	if groupBy == "" {
+		groupBy = models.ReportGroupDriver + "," + models.ReportGroupCity
+	}
+	for _, g := range strings.Split(groupBy, ",") {
+		g = strings.TrimSpace(g)
+		if g == "" {
+			continue
+		}
+		if !models.IsValidReportGroup(g) {
+			apierror.Write(w, r, apierror.BadRequest("report.invalid_group"))
+			return
+		}
+		filter.GroupBy = append(filter.GroupBy, g)
+	}
+
+	format := q.Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		format = "csv"
+	}
+	if format != "" && format != "json" && format != "csv" {
+		apierror.Write(w, r, apierror.BadRequest("report.invalid_format"))
+		return
+	}
+
+	report, err := models.GetCompletionReport(r.Context(), filter)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("report.failed", err))
+		return
+	}
+
+	if format == "csv" {
+		writeCompletionReportCSV(w, r, report, fromStr, toStr)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":     fromStr,
+		"to":       toStr,
+		"group_by": filter.GroupBy,
+		"rows":     report,
+	})
+}
+
+// Колонки CSV-отчёта; заголовки берутся из каталога i18n по ключу report.col.<колонка>
+var completionReportColumns = []string{
+	"date", "driver_id", "driver_name", "city", "district",
+	"total", "completed", "skipped", "problem", "pending", "in_progress",
+	"completed_pct", "skipped_pct", "problem_pct", "avg_delay_minutes",
+}
+
+func writeCompletionReportCSV(w http.ResponseWriter, r *http.Request, report []models.CompletionReportRow, from, to string) {
+	lang := i18n.FromContext(r.Context())
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="completion_`+from+`_`+to+`.csv"`)
+
+	// BOM, чтобы Excel правильно открыл кириллицу
+	w.Write([]byte("\xEF\xBB\xBF"))
+
+	cw := csv.NewWriter(w)
+	header := make([]string, len(completionReportColumns))
+	for i, col := range completionReportColumns {
+		header[i] = i18n.T(lang, "report.col."+col)
+	}
+	cw.Write(header)
+
+	for _, row := range report {
+		driverID := ""
+		if row.DriverID != nil {
+			driverID = strconv.Itoa(*row.DriverID)
+		}
+		avgDelay := ""
+		if row.AvgDelayMinutes != nil {
+			avgDelay = strconv.FormatFloat(*row.AvgDelayMinutes, 'f', 1, 64)
+		}
+		cw.Write([]string{
+			row.Date, driverID, row.DriverName, row.City, row.District,
+			strconv.Itoa(row.Total), strconv.Itoa(row.Completed), strconv.Itoa(row.Skipped),
+			strconv.Itoa(row.Problem), strconv.Itoa(row.Pending), strconv.Itoa(row.InProgress),
+			strconv.FormatFloat(row.CompletedPct, 'f', 2, 64),
+			strconv.FormatFloat(row.SkippedPct, 'f', 2, 64),
+			strconv.FormatFloat(row.ProblemPct, 'f', 2, 64),
+			avgDelay,
+		})
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.ErrorContext(r.Context(), "Ошибка записи CSV отчёта о выполнении", "err", err)
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"garbage_trucks/backend/internal/apierror"
+	"log/slog"
+	"net/http"
+)
+
+// writeJSON отправляет ответ в JSON с указанным статусом.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		// Заголовки уже отправлены, сообщить клиенту об ошибке нельзя
+		slog.Error("Ошибка кодирования ответа", "err", err)
+	}
+}
+
+// decodeJSON разбирает тело запроса в v.
+func decodeJSON(r *http.Request, v interface{}) error {
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// NotFoundHandler отвечает на запросы к несуществующим маршрутам.
+func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	apierror.Write(w, r, apierror.NotFound("error.not_found_route"))
+}
+
+// MethodNotAllowedHandler отвечает, если путь существует, но метод не поддерживается.
+func MethodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	apierror.Write(w, r, apierror.MethodNotAllowed())
+}
@@ -1,117 +1,181 @@
 package handlers
 
 import (
-	"context"
-	"encoding/json"
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/listquery"
 	"garbage_trucks/backend/internal/models"
-	"garbage_trucks/backend/internal/database"
-	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// GetRoutesHandler возвращает страницу списка маршрутов (остановок).
+// Фильтры: driver_id, point_id, status (через запятую), from, to
+// (YYYY-MM-DD, по scheduled_at), archived (остановки архивных водителей
+// и точек: exclude — по умолчанию, include, only); постраничность: limit,
+// cursor, sort.
+// При фильтре по водителю в ответ добавляется его карточка.
 func GetRoutesHandler(w http.ResponseWriter, r *http.Request) {
-	driverIDStr := r.URL.Query().Get("driver_id")
-	pointIDStr := r.URL.Query().Get("point_id")
+	q := r.URL.Query()
 
-	if driverIDStr != "" {
-		// Получаем маршруты по водителю
-		driverID, err := strconv.Atoi(driverIDStr)
-		if err != nil {
-			http.Error(w, "driver_id должен быть числом", http.StatusBadRequest)
-			return
-		}
+	params, err := listquery.Parse(q, models.RouteListSpec)
+	if err != nil {
+		apierror.Write(w, r, apierror.FromError(http.StatusBadRequest, apierror.CodeBadRequest, err))
+		return
+	}
 
-		// Получаем информацию о водителе
-		driver, err := models.GetDriverByID(context.Background(), driverID)
-		if err != nil {
-			http.Error(w, "Ошибка получения водителя", http.StatusInternalServerError)
-			return
+	filter := models.RouteFilter{Archived: q.Get("archived")}
+	if filter.DriverID, err = optionalIntParam(q, "driver_id"); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if filter.PointID, err = optionalIntParam(q, "point_id"); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if filter.From, err = optionalDateParam(q, "from", false); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if filter.To, err = optionalDateParam(q, "to", true); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if statuses := q.Get("status"); statuses != "" {
+		for _, s := range strings.Split(statuses, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				filter.Statuses = append(filter.Statuses, s)
+			}
 		}
+	}
 
-		routes, err := models.GetRoutesByDriverID(context.Background(), driverID)
+	response := struct {
+		*listquery.Page
+		Driver *models.Driver `json:"driver,omitempty"`
+	}{}
+
+	if filter.DriverID != nil {
+		// Получаем информацию о водителе
+		response.Driver, err = models.GetDriverByID(r.Context(), *filter.DriverID)
 		if err != nil {
-			http.Error(w, "Ошибка получения маршрута", http.StatusInternalServerError)
+			apierror.Write(w, r, apierror.FromDB(err, "driver.not_found", "driver.get_failed"))
 			return
 		}
+	}
 
-		response := map[string]interface{}{
-			"driver": driver,
-			"routes": routes,
-		}
+	response.Page, err = models.ListRoutes(r.Context(), filter, params)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("route.list_failed", err))
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
-		}
-	} else if pointIDStr != "" {
-		// Получаем маршруты по точке
-		pointID, err := strconv.Atoi(pointIDStr)
-		if err != nil {
-			http.Error(w, "point_id должен быть числом", http.StatusBadRequest)
-			return
-		}
+	writeJSON(w, http.StatusOK, response)
+}
 
-		routes, err := models.GetRoutesByPointID(context.Background(), pointID)
-		if err != nil {
-			http.Error(w, "Ошибка получения маршрутов", http.StatusInternalServerError)
-			return
-		}
+// GetRouteHandler возвращает остановку с данными точки и версией в ETag;
+// при совпадении If-None-Match отвечает 304.
+func GetRouteHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(routes); err != nil {
-			http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
-		}
-	} else {
-		http.Error(w, "driver_id или point_id обязателен", http.StatusBadRequest)
+	route, err := models.GetRouteByID(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, apierror.FromDB(err, "route.not_found", "route.get_failed"))
+		return
 	}
+	if notModified(w, r, route.Version) {
+		return
+	}
+
+	setETag(w, route.Version)
+	writeJSON(w, http.StatusOK, route)
 }
 
-func UpdateRouteStatusHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Метод не разрешён", http.StatusMethodNotAllowed)
+// GetRouteGeometryHandler отдаёт линию объезда остановок водителя за день
+// по дорогам для карты: GET /api/routes/geometry?driver_id=&date=&format=
+// (date по умолчанию — сегодня, format — polyline или geojson).
+func GetRouteGeometryHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	driverID, err := optionalIntParam(q, "driver_id")
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if driverID == nil {
+		apierror.Write(w, r, apierror.BadRequest("param.required", "driver_id"))
+		return
+	}
+	day, err := dayParam(q, "date", time.Now())
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = models.GeometryFormatPolyline
+	}
+	if !slices.Contains(models.GeometryFormats, format) {
+		apierror.Write(w, r, apierror.BadRequest("route.geometry_format", strings.Join(models.GeometryFormats, ", ")))
 		return
 	}
 
+	if _, err := models.GetDriverByID(r.Context(), *driverID); err != nil {
+		apierror.Write(w, r, apierror.FromDB(err, "driver.not_found", "driver.get_failed"))
+		return
+	}
+
+	geometry, err := models.GetRouteGeometry(r.Context(), *driverID, day, format)
+	if err != nil {
+		apierror.Write(w, r, apierror.Internal("route.geometry_failed", err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, geometry)
+}
+
+// UpdateRouteStatusHandler меняет статус остановки:
+// POST /api/routes/status?route_id=&status=
+// С заголовком If-Match статус меняется, только если версия остановки
+// не изменилась, иначе — 412. Новая версия возвращается в ETag.
+func UpdateRouteStatusHandler(w http.ResponseWriter, r *http.Request) {
 	routeIDStr := r.URL.Query().Get("route_id")
 	status := r.URL.Query().Get("status")
 
-	log.Printf("UpdateRouteStatusHandler: route_id=%s, status=%s", routeIDStr, status)
-
 	if routeIDStr == "" || status == "" {
-		http.Error(w, "route_id и status обязательны", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("route.status_params_required"))
 		return
 	}
 
 	routeID, err := strconv.Atoi(routeIDStr)
 	if err != nil {
-		http.Error(w, "route_id должен быть числом", http.StatusBadRequest)
+		apierror.Write(w, r, apierror.BadRequest("param.not_integer", "route_id"))
 		return
 	}
 
-	// Исправленный запрос
-	query := `
-		UPDATE routes 
-		SET 
-			status = $1,
-			completed_at = CASE WHEN $3 = 'completed' THEN CURRENT_TIMESTAMP ELSE completed_at END,
-			visited_at = CASE WHEN $3 IN ('in_progress', 'completed', 'problem') THEN COALESCE(visited_at, CURRENT_TIMESTAMP) ELSE visited_at END
-		WHERE id = $2
-	`
+	if !models.IsValidRouteStatus(status) {
+		v := newValidator(r)
+		v.add("status", apierror.FieldInvalid, "field.one_of", strings.Join(models.RouteStatuses, ", "))
+		apierror.Write(w, r, v.err())
+		return
+	}
 
-	log.Printf("UpdateRouteStatus: routeID=%d, status=%s", routeID, status)
+	ifVersion, apiErr := ifMatchVersion(r)
+	if apiErr != nil {
+		apierror.Write(w, r, apiErr)
+		return
+	}
 
-	result, err := database.Pool.Exec(context.Background(), query, status, routeID, status)
+	version, err := models.UpdateRouteStatus(r.Context(), routeID, status, ifVersion)
 	if err != nil {
-		log.Printf("UpdateRouteStatus Exec error: %v", err)
-		http.Error(w, "Ошибка обновления статуса: "+err.Error(), http.StatusInternalServerError)
+		apierror.Write(w, r, versionError(err, "route.not_found", "route.status_update_failed"))
 		return
 	}
 
-	log.Printf("UpdateRouteStatus result: RowsAffected=%d", result.RowsAffected())
-
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(map[string]string{"status": "success"}); err != nil {
-		http.Error(w, "Ошибка кодирования ответа", http.StatusInternalServerError)
-	}
+	setETag(w, version)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "success"})
 }
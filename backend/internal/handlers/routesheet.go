@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/i18n"
+	"garbage_trucks/backend/internal/models"
+	"garbage_trucks/backend/internal/routesheet"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// routeSheetDay возвращает день маршрутного листа из параметра date,
+// по умолчанию — сегодня.
+func routeSheetDay(r *http.Request) (time.Time, error) {
+	dateStr := r.URL.Query().Get("date")
+	if dateStr == "" {
+		return time.Now(), nil
+	}
+	day, err := time.ParseInLocation(reportDateLayout, dateStr, time.Local)
+	if err != nil {
+		return time.Time{}, apierror.BadRequest("param.not_date", "date")
+	}
+	return day, nil
+}
+
+// loadRouteSheet загружает водителя из пути и его остановки на день из
+// параметра date.
+func loadRouteSheet(r *http.Request) (*models.Driver, time.Time, []models.Route, error) {
+	id, err := pathID(r)
+	if err != nil {
+		return nil, time.Time{}, nil, err
+	}
+	day, err := routeSheetDay(r)
+	if err != nil {
+		return nil, time.Time{}, nil, err
+	}
+
+	driver, err := models.GetDriverByID(r.Context(), id)
+	if err != nil {
+		return nil, time.Time{}, nil, apierror.FromDB(err, "driver.not_found", "driver.get_failed")
+	}
+
+	routes, err := models.GetRoutesByDriverIDOnDate(r.Context(), id, day)
+	if err != nil {
+		return nil, time.Time{}, nil, apierror.Internal("route.get_failed", err)
+	}
+	return driver, day, routes, nil
+}
+
+// GetDriverRoutesOnDateHandler отдаёт маршрутный лист водителя на дату
+// в JSON для приложения водителя: остановки по порядку объезда вместе
+// с кодами ворот, которых нет в общем списке маршрутов.
+func GetDriverRoutesOnDateHandler(w http.ResponseWriter, r *http.Request) {
+	driver, day, routes, err := loadRouteSheet(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if routes == nil {
+		routes = []models.Route{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"driver": driver,
+		"date":   day.Format(reportDateLayout),
+		"items":  routes,
+	})
+}
+
+// GetRouteSheetHandler отдаёт маршрутный лист водителя на дату в PDF
+// для водителей, работающих с бумажной копией.
+func GetRouteSheetHandler(w http.ResponseWriter, r *http.Request) {
+	driver, day, routes, err := loadRouteSheet(r)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+
+	// Рендерим в буфер, чтобы при ошибке не отдать клиенту обрезанный PDF
+	var buf bytes.Buffer
+	if err := routesheet.Render(&buf, i18n.FromContext(r.Context()), driver, day, routes); err != nil {
+		apierror.Write(w, r, apierror.Internal("routesheet.failed", err))
+		return
+	}
+
+	filename := "routesheet_" + strconv.Itoa(driver.ID) + "_" + day.Format(reportDateLayout) + ".pdf"
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `inline; filename="`+filename+`"`)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	buf.WriteTo(w)
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/geocoding"
+	"garbage_trucks/backend/internal/i18n"
+	"garbage_trucks/backend/internal/models"
+	"garbage_trucks/backend/internal/planning"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// validator собирает ошибки полей, чтобы вернуть клиенту все сразу.
+type validator struct {
+	lang   i18n.Lang
+	errors []apierror.FieldError
+}
+
+func newValidator(r *http.Request) *validator {
+	return &validator{lang: i18n.FromContext(r.Context())}
+}
+
+// add добавляет ошибку поля с сообщением из каталога i18n.
+func (v *validator) add(field, code, key string, args ...interface{}) {
+	v.errors = append(v.errors, apierror.FieldError{
+		Field:   field,
+		Code:    code,
+		Message: i18n.T(v.lang, key, args...),
+	})
+}
+
+// has сообщает, есть ли уже ошибка для поля.
+func (v *validator) has(field string) bool {
+	for _, e := range v.errors {
+		if e.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, apierror.FieldRequired, "field.required")
+	}
+}
+
+// maxLen проверяет длину в символах, как её считает VARCHAR(n).
+func (v *validator) maxLen(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.add(field, apierror.FieldTooLong, "field.too_long", max)
+	}
+}
+
+func (v *validator) coordinates(latField string, lat float64, lonField string, lon float64) {
+	if lat < -90 || lat > 90 {
+		v.add(latField, apierror.FieldOutOfRange, "field.latitude_range")
+	}
+	if lon < -180 || lon > 180 {
+		v.add(lonField, apierror.FieldOutOfRange, "field.longitude_range")
+	}
+}
+
+// uniqueIDs отмечает повторяющиеся идентификаторы в списке.
+func (v *validator) uniqueIDs(field string, ids []int) {
+	seen := make(map[int]bool, len(ids))
+	for i, id := range ids {
+		if seen[id] {
+			v.add(field+"["+strconv.Itoa(i)+"]", apierror.FieldDuplicate, "field.duplicate_id", id)
+		}
+		seen[id] = true
+	}
+}
+
+// driversExist отмечает идентификаторы водителей, которых нет в базе.
+func (v *validator) driversExist(ctx context.Context, field string, ids []int) error {
+	existing, err := models.ExistingDriverIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i, id := range ids {
+		if !existing[id] {
+			v.add(field+"["+strconv.Itoa(i)+"]", apierror.FieldNotFound, "field.driver_not_found", id)
+		}
+	}
+	return nil
+}
+
+// err возвращает ошибку 422 со списком полей или nil, если ошибок нет.
+func (v *validator) err() *apierror.Error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return apierror.Validation("validation.failed").WithDetails(v.errors)
+}
+
+// driverInput — тело запроса создания и изменения водителя.
+type driverInput struct {
+	Name string `json:"name"`
+}
+
+func (in *driverInput) validate(v *validator) {
+	in.Name = strings.TrimSpace(in.Name)
+	v.required("name", in.Name)
+	v.maxLen("name", in.Name, models.DriverNameMaxLen)
+}
+
+// pointInput — тело запроса создания и изменения точки сбора. Нулевые
+// координаты означают, что их нужно определить по адресу.
+type pointInput struct {
+	Name           string  `json:"name"`
+	Address        string  `json:"address"`
+	Latitude       float64 `json:"latitude"`
+	Longitude      float64 `json:"longitude"`
+	City           string  `json:"city"`
+	ContainerCount *int    `json:"container_count"`
+	DriverIDs      []int   `json:"driver_ids"`
+	models.PointRestrictions
+}
+
+func (in *pointInput) validate(v *validator) {
+	in.Name = strings.TrimSpace(in.Name)
+	in.Address = strings.TrimSpace(in.Address)
+	in.City = strings.TrimSpace(in.City)
+
+	v.required("name", in.Name)
+	v.maxLen("name", in.Name, models.PointNameMaxLen)
+	v.maxLen("address", in.Address, models.PointAddressMaxLen)
+	v.maxLen("city", in.City, models.PointCityMaxLen)
+	v.coordinates("latitude", in.Latitude, "longitude", in.Longitude)
+	v.uniqueIDs("driver_ids", in.DriverIDs)
+	in.validateRestrictions(v)
+}
+
+// validateRestrictions проверяет ограничения обслуживания: окно задаётся
+// или снимается целиком, дни недели — ISO без повторов.
+func (in *pointInput) validateRestrictions(v *validator) {
+	rs := &in.PointRestrictions
+	start, errStart := serviceWindowTime(v, "window_start", rs.WindowStart)
+	end, errEnd := serviceWindowTime(v, "window_end", rs.WindowEnd)
+	if (rs.WindowStart == nil) != (rs.WindowEnd == nil) ||
+		(rs.WindowStart != nil && (*rs.WindowStart == "") != (*rs.WindowEnd == "")) {
+		v.add("window_end", apierror.FieldInvalid, "point.window_pair")
+	} else if rs.WindowStart != nil && *rs.WindowStart != "" && errStart == nil && errEnd == nil && !end.After(start) {
+		v.add("window_end", apierror.FieldOutOfRange, "point.window_order")
+	}
+
+	seen := make(map[int]bool, len(rs.ExcludedWeekdays))
+	for i, d := range rs.ExcludedWeekdays {
+		field := "excluded_weekdays[" + strconv.Itoa(i) + "]"
+		switch {
+		case d < 1 || d > 7:
+			v.add(field, apierror.FieldOutOfRange, "point.weekday_range")
+		case seen[d]:
+			v.add(field, apierror.FieldDuplicate, "field.duplicate_items")
+		}
+		seen[d] = true
+	}
+
+	if rs.AccessNotes != nil {
+		*rs.AccessNotes = strings.TrimSpace(*rs.AccessNotes)
+		v.maxLen("access_notes", *rs.AccessNotes, models.PointAccessNotesMaxLen)
+	}
+	if rs.GateCode != nil {
+		*rs.GateCode = strings.TrimSpace(*rs.GateCode)
+		v.maxLen("gate_code", *rs.GateCode, models.PointGateCodeMaxLen)
+	}
+}
+
+// serviceWindowTime разбирает границу окна обслуживания; nil и пустая
+// строка дают нулевое время без ошибки.
+func serviceWindowTime(v *validator, field string, value *string) (time.Time, error) {
+	if value == nil || *value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(models.ServiceWindowLayout, *value)
+	if err != nil {
+		v.add(field, apierror.FieldInvalid, "field.time")
+	}
+	return t, err
+}
+
+// hasCoordinates — заданы ли координаты; 0,0 считается незаполненным.
+func (in *pointInput) hasCoordinates() bool {
+	return in.Latitude != 0 || in.Longitude != 0
+}
+
+// positionInput — тело запроса GPS-отметки водителя; без recorded_at
+// отметка получает время сервера.
+type positionInput struct {
+	Latitude   *float64   `json:"latitude"`
+	Longitude  *float64   `json:"longitude"`
+	RecordedAt *time.Time `json:"recorded_at"`
+}
+
+func (in *positionInput) validate(v *validator) {
+	if in.Latitude == nil {
+		v.add("latitude", apierror.FieldRequired, "field.required")
+	}
+	if in.Longitude == nil {
+		v.add("longitude", apierror.FieldRequired, "field.required")
+	}
+	if in.Latitude != nil && in.Longitude != nil {
+		v.coordinates("latitude", *in.Latitude, "longitude", *in.Longitude)
+	}
+}
+
+// rerouteInput — тело запроса применения перераспределения.
+type rerouteInput struct {
+	Date        string `json:"date"`
+	Assignments []struct {
+		RouteID  int `json:"route_id"`
+		Version  int `json:"version"`
+		DriverID int `json:"driver_id"`
+	} `json:"assignments"`
+}
+
+// validate проверяет назначения и возвращает день (по умолчанию — день
+// now). Передавать остановки самому водителю driverID нельзя.
+func (in *rerouteInput) validate(v *validator, driverID int, now time.Time) time.Time {
+	day := now
+	if in.Date != "" {
+		d, err := time.ParseInLocation(reportDateLayout, in.Date, time.Local)
+		if err != nil {
+			v.add("date", apierror.FieldInvalid, "field.date")
+		}
+		day = d
+	}
+	if len(in.Assignments) == 0 {
+		v.add("assignments", apierror.FieldRequired, "field.required")
+	}
+	routeIDs := make([]int, len(in.Assignments))
+	for i, a := range in.Assignments {
+		routeIDs[i] = a.RouteID
+		if a.DriverID == driverID {
+			v.add("assignments["+strconv.Itoa(i)+"].driver_id", apierror.FieldInvalid, "reroute.same_driver")
+		}
+	}
+	v.uniqueIDs("assignments", routeIDs)
+	return day
+}
+
+// planInput — тело запроса планирования маршрутов города.
+type planInput struct {
+	Date  string `json:"date"`
+	Depot *struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+	} `json:"depot"`
+	Vehicles []struct {
+		DriverID   int    `json:"driver_id"`
+		Capacity   int    `json:"capacity"`
+		ShiftStart string `json:"shift_start"`
+		ShiftEnd   string `json:"shift_end"`
+	} `json:"vehicles"`
+	PointIDs     []int  `json:"point_ids"`
+	DistrictID   *int   `json:"district_id"`
+	City         string `json:"city"`
+	TimeBudgetMs *int   `json:"time_budget_ms"`
+}
+
+// Формат начала и конца смены
+const shiftTimeLayout = "15:04"
+
+// request проверяет тело и собирает запрос планирования. Без date — на
+// день now, без depot — база из конфигурации, без time_budget_ms —
+// наибольшее допустимое время.
+func (in *planInput) request(ctx context.Context, v *validator, now time.Time) (models.PlanRequest, error) {
+	req := models.PlanRequest{Date: now, PointIDs: in.PointIDs, DistrictID: in.DistrictID, City: strings.TrimSpace(in.City)}
+
+	if in.Date != "" {
+		d, err := time.ParseInLocation(reportDateLayout, in.Date, time.Local)
+		if err != nil {
+			v.add("date", apierror.FieldInvalid, "field.date")
+		}
+		req.Date = d
+	}
+	day := time.Date(req.Date.Year(), req.Date.Month(), req.Date.Day(), 0, 0, 0, 0, time.Local)
+
+	if in.Depot != nil {
+		v.coordinates("depot.latitude", in.Depot.Latitude, "depot.longitude", in.Depot.Longitude)
+		req.Depot = planning.Point{Latitude: in.Depot.Latitude, Longitude: in.Depot.Longitude}
+	} else if depot, ok := planning.DefaultDepot(); ok {
+		req.Depot = depot
+	} else {
+		v.add("depot", apierror.FieldRequired, "plan.depot_required")
+	}
+
+	if len(in.Vehicles) == 0 {
+		v.add("vehicles", apierror.FieldRequired, "field.required")
+	}
+	driverIDs := make([]int, len(in.Vehicles))
+	for i, veh := range in.Vehicles {
+		field := "vehicles[" + strconv.Itoa(i) + "]"
+		driverIDs[i] = veh.DriverID
+		if veh.Capacity <= 0 {
+			v.add(field+".capacity", apierror.FieldOutOfRange, "field.positive")
+		}
+		start, errStart := time.Parse(shiftTimeLayout, veh.ShiftStart)
+		if errStart != nil {
+			v.add(field+".shift_start", apierror.FieldInvalid, "field.time")
+		}
+		end, errEnd := time.Parse(shiftTimeLayout, veh.ShiftEnd)
+		if errEnd != nil {
+			v.add(field+".shift_end", apierror.FieldInvalid, "field.time")
+		}
+		if errStart == nil && errEnd == nil && !end.After(start) {
+			v.add(field+".shift_end", apierror.FieldOutOfRange, "plan.shift_order")
+		}
+		req.Vehicles = append(req.Vehicles, models.PlanVehicle{
+			DriverID:   veh.DriverID,
+			Capacity:   veh.Capacity,
+			ShiftStart: day.Add(time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute),
+			ShiftEnd:   day.Add(time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute),
+		})
+	}
+	v.uniqueIDs("vehicles", driverIDs)
+	v.uniqueIDs("point_ids", in.PointIDs)
+	if err := v.driversExist(ctx, "vehicles", driverIDs); err != nil {
+		return req, err
+	}
+
+	max := planning.MaxBudget()
+	req.Budget = max
+	if in.TimeBudgetMs != nil {
+		req.Budget = time.Duration(*in.TimeBudgetMs) * time.Millisecond
+		if req.Budget <= 0 || req.Budget > max {
+			v.add("time_budget_ms", apierror.FieldOutOfRange, "plan.budget_range", max.Milliseconds())
+		}
+	}
+	return req, nil
+}
+
+// validateLocation проверяет, что точка попадает в зону обслуживания:
+// сначала по прямоугольнику из конфигурации, затем по полигону из базы.
+func validateLocation(ctx context.Context, v *validator, lat, lon float64) error {
+	if !geocoding.InServiceArea(lat, lon) {
+		v.add("latitude", apierror.FieldOutsideServiceArea, "point.outside_service_area")
+		return nil
+	}
+	inArea, err := models.InServiceAreaPolygon(ctx, lat, lon)
+	if err != nil {
+		return err
+	}
+	if !inArea {
+		v.add("latitude", apierror.FieldOutsideServiceArea, "point.outside_service_area")
+	}
+	return nil
+}
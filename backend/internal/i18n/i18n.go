@@ -0,0 +1,153 @@
+// Package i18n — каталог сообщений API на русском и английском и выбор
+// языка по заголовку Accept-Language.
+package i18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lang — код поддерживаемого языка.
+type Lang string
+
+const (
+	RU Lang = "ru"
+	EN Lang = "en"
+)
+
+// Default — язык по умолчанию, если клиент не указал подходящий.
+const Default = RU
+
+// Supported — поддерживаемые языки в порядке предпочтения при равном весе.
+var Supported = []Lang{RU, EN}
+
+// Message — сообщение из каталога с аргументами. Реализует error, поэтому
+// пакеты могут возвращать ошибки, которые переводятся при отдаче клиенту.
+type Message struct {
+	Key  string
+	Args []interface{}
+}
+
+// Errorf возвращает ошибку-сообщение каталога.
+func Errorf(key string, args ...interface{}) error {
+	return &Message{Key: key, Args: args}
+}
+
+func (m *Message) Error() string {
+	return T(Default, m.Key, m.Args...)
+}
+
+// In переводит сообщение на указанный язык.
+func (m *Message) In(lang Lang) string {
+	return T(lang, m.Key, m.Args...)
+}
+
+// T возвращает сообщение key на языке lang. Если перевода нет, берётся
+// язык по умолчанию, а если нет и его — сам ключ. Аргументы-ошибки,
+// содержащие *Message, переводятся на тот же язык.
+func T(lang Lang, key string, args ...interface{}) string {
+	texts, ok := catalog[key]
+	if !ok {
+		return key
+	}
+	format, ok := texts[lang]
+	if !ok {
+		format = texts[Default]
+	}
+	if len(args) == 0 {
+		return format
+	}
+
+	localized := make([]interface{}, len(args))
+	for i, a := range args {
+		localized[i] = a
+		if err, ok := a.(error); ok {
+			var m *Message
+			if errors.As(err, &m) {
+				localized[i] = m.In(lang)
+			}
+		}
+	}
+	return fmt.Sprintf(format, localized...)
+}
+
+// Localize переводит ошибку, если она содержит сообщение каталога,
+// иначе возвращает её текст как есть.
+func Localize(lang Lang, err error) string {
+	var m *Message
+	if errors.As(err, &m) {
+		return m.In(lang)
+	}
+	return err.Error()
+}
+
+// Negotiate выбирает язык по значению Accept-Language с учётом весов q,
+// например "en-US,en;q=0.9,ru;q=0.8" → en.
+func Negotiate(header string) Lang {
+	type candidate struct {
+		lang Lang
+		q    float64
+	}
+	var candidates []candidate
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		if tag == "*" {
+			candidates = append(candidates, candidate{Default, q})
+			continue
+		}
+		base := tag
+		if idx := strings.IndexAny(tag, "-_"); idx >= 0 {
+			base = tag[:idx]
+		}
+		for _, l := range Supported {
+			if Lang(base) == l {
+				candidates = append(candidates, candidate{l, q})
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return Default
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].q > candidates[b].q
+	})
+	return candidates[0].lang
+}
+
+type contextKey struct{}
+
+// WithLang возвращает контекст с выбранным языком.
+func WithLang(ctx context.Context, lang Lang) context.Context {
+	return context.WithValue(ctx, contextKey{}, lang)
+}
+
+// FromContext возвращает язык запроса или язык по умолчанию.
+func FromContext(ctx context.Context) Lang {
+	if lang, ok := ctx.Value(contextKey{}).(Lang); ok {
+		return lang
+	}
+	return Default
+}
@@ -0,0 +1,561 @@
+package i18n
+
+// catalog — все сообщения API. Ключи стабильны: клиенты могут опираться
+// на коды ошибок, а тексты меняются и переводятся свободно.
+var catalog = map[string]map[Lang]string{
+	// Общие
+	"health.ok": {
+		RU: "Garbage Trucks API работает!",
+		EN: "Garbage Trucks API is up!",
+	},
+	"health.database_unavailable": {
+		RU: "База данных недоступна",
+		EN: "Database is unavailable",
+	},
+	"health.replica_unavailable": {
+		RU: "Реплика для отчётов недоступна",
+		EN: "Reporting replica is unavailable",
+	},
+	"health.migrations_pending": {
+		RU: "Не все миграции схемы применены",
+		EN: "Some schema migrations have not been applied",
+	},
+	"health.migrations_unknown": {
+		RU: "Не удалось проверить миграции схемы",
+		EN: "Could not check schema migrations",
+	},
+	"cors.origin_not_allowed": {
+		RU: "Запросы с источника %s не разрешены",
+		EN: "Requests from origin %s are not allowed",
+	},
+	"error.feature_disabled": {
+		RU: "Возможность %s отключена в настройках сервера",
+		EN: "Feature %s is disabled in the server configuration",
+	},
+	"error.internal": {
+		RU: "Внутренняя ошибка сервера",
+		EN: "Internal server error",
+	},
+	"error.not_found_route": {
+		RU: "Маршрут API не найден",
+		EN: "API route not found",
+	},
+	"error.method_not_allowed": {
+		RU: "Метод не поддерживается",
+		EN: "Method not allowed",
+	},
+	"error.timeout": {
+		RU: "Запрос выполнялся слишком долго и был прерван",
+		EN: "The request took too long and was aborted",
+	},
+	"error.version_mismatch": {
+		RU: "Запись изменена другим пользователем; загрузите её заново",
+		EN: "The record was changed by someone else; reload it and try again",
+	},
+	"error.invalid_body": {
+		RU: "Неверный формат данных",
+		EN: "Invalid request body",
+	},
+	"request.invalid_params": {
+		RU: "Параметры запроса не соответствуют спецификации API",
+		EN: "Request parameters do not match the API specification",
+	},
+	"request.too_large": {
+		RU: "Тело запроса больше %d байт",
+		EN: "Request body exceeds %d bytes",
+	},
+	"request.invalid_body": {
+		RU: "Тело запроса не соответствует спецификации API",
+		EN: "Request body does not match the API specification",
+	},
+	"error.db_foreign_key": {
+		RU: "Связанная запись не существует или используется",
+		EN: "Related record does not exist or is still in use",
+	},
+	"error.db_unique": {
+		RU: "Запись с такими данными уже существует",
+		EN: "A record with the same data already exists",
+	},
+	"error.db_check": {
+		RU: "Данные не прошли проверку",
+		EN: "Data failed validation",
+	},
+
+	// Параметры запроса
+	"param.required": {
+		RU: "%s обязателен",
+		EN: "%s is required",
+	},
+	"param.not_integer": {
+		RU: "%s должен быть числом",
+		EN: "%s must be an integer",
+	},
+	"param.not_date": {
+		RU: "%s должен быть в формате YYYY-MM-DD",
+		EN: "%s must be in YYYY-MM-DD format",
+	},
+	"param.range_reversed": {
+		RU: "to не может быть раньше from",
+		EN: "to must not be earlier than from",
+	},
+	"param.invalid_coordinates": {
+		RU: "lat и lon должны быть корректными координатами",
+		EN: "lat and lon must be valid coordinates",
+	},
+	"param.invalid_radius": {
+		RU: "radius должен быть от 0 до %d метров",
+		EN: "radius must be between 0 and %d meters",
+	},
+	"param.unknown": {
+		RU: "Такого параметра нет в API",
+		EN: "Parameter is not part of the API",
+	},
+
+	// Проверка полей
+	"validation.failed": {
+		RU: "Проверьте введённые данные",
+		EN: "Some fields are invalid",
+	},
+	"field.required": {
+		RU: "Обязательное поле",
+		EN: "This field is required",
+	},
+	"field.too_long": {
+		RU: "Не более %d символов",
+		EN: "Must be at most %d characters",
+	},
+	"field.latitude_range": {
+		RU: "Широта должна быть от -90 до 90",
+		EN: "Latitude must be between -90 and 90",
+	},
+	"field.longitude_range": {
+		RU: "Долгота должна быть от -180 до 180",
+		EN: "Longitude must be between -180 and 180",
+	},
+	"field.duplicate_id": {
+		RU: "ID %d указан несколько раз",
+		EN: "ID %d is listed more than once",
+	},
+	"field.duplicate_items": {
+		RU: "Значения не должны повторяться",
+		EN: "Values must be unique",
+	},
+	"field.unknown": {
+		RU: "Такого поля нет в API",
+		EN: "Field is not part of the API",
+	},
+	"field.driver_not_found": {
+		RU: "Водитель %d не найден",
+		EN: "Driver %d not found",
+	},
+	"field.one_of": {
+		RU: "Допустимые значения: %s",
+		EN: "Allowed values: %s",
+	},
+	"field.date": {
+		RU: "Ожидается дата в формате YYYY-MM-DD",
+		EN: "Expected a date in YYYY-MM-DD format",
+	},
+	"field.time": {
+		RU: "Ожидается время в формате HH:MM",
+		EN: "Expected a time in HH:MM format",
+	},
+	"field.positive": {
+		RU: "Должно быть больше нуля",
+		EN: "Must be greater than zero",
+	},
+
+	// Постраничные списки
+	"list.invalid_limit": {
+		RU: "limit должен быть положительным числом",
+		EN: "limit must be a positive integer",
+	},
+	"list.limit_too_large": {
+		RU: "limit не может быть больше %d",
+		EN: "limit must not exceed %d",
+	},
+	"list.invalid_sort": {
+		RU: "sort может быть одним из: %s",
+		EN: "sort must be one of: %s",
+	},
+	"list.invalid_cursor": {
+		RU: "неверный cursor",
+		EN: "invalid cursor",
+	},
+
+	// Геометрия
+	"bbox.format": {
+		RU: "bbox должен содержать 4 числа: minLon,minLat,maxLon,maxLat",
+		EN: "bbox must contain 4 numbers: minLon,minLat,maxLon,maxLat",
+	},
+	"bbox.not_number": {
+		RU: "bbox: %q не является числом",
+		EN: "bbox: %q is not a number",
+	},
+	"bbox.min_gt_max": {
+		RU: "bbox: минимальные значения больше максимальных",
+		EN: "bbox: minimum values exceed maximum values",
+	},
+	"bbox.out_of_range": {
+		RU: "bbox: координаты вне допустимого диапазона",
+		EN: "bbox: coordinates out of range",
+	},
+	"geometry.missing": {
+		RU: "геометрия отсутствует",
+		EN: "geometry is missing",
+	},
+	"geometry.unsupported": {
+		RU: "неподдерживаемый тип геометрии: %s",
+		EN: "unsupported geometry type: %s",
+	},
+	"geometry.no_rings": {
+		RU: "полигон без контуров",
+		EN: "polygon has no rings",
+	},
+	"geometry.ring_too_short": {
+		RU: "контур полигона должен содержать минимум 4 точки",
+		EN: "polygon ring must have at least 4 positions",
+	},
+	"geometry.out_of_range": {
+		RU: "координаты вне допустимого диапазона: %v",
+		EN: "coordinates out of range: %v",
+	},
+
+	// Водители
+	"driver.not_found": {
+		RU: "Водитель не найден",
+		EN: "Driver not found",
+	},
+	"driver.list_failed": {
+		RU: "Ошибка получения водителей",
+		EN: "Failed to load drivers",
+	},
+	"driver.get_failed": {
+		RU: "Ошибка получения водителя",
+		EN: "Failed to load driver",
+	},
+	"driver.create_failed": {
+		RU: "Ошибка создания водителя",
+		EN: "Failed to create driver",
+	},
+	"driver.update_failed": {
+		RU: "Ошибка обновления водителя",
+		EN: "Failed to update driver",
+	},
+	"driver.delete_failed": {
+		RU: "Ошибка удаления водителя",
+		EN: "Failed to delete driver",
+	},
+	"driver.restore_failed": {
+		RU: "Ошибка восстановления водителя",
+		EN: "Failed to restore driver",
+	},
+	"driver.not_archived": {
+		RU: "Водитель не в архиве",
+		EN: "Driver is not archived",
+	},
+	"driver.position_failed": {
+		RU: "Ошибка сохранения положения водителя",
+		EN: "Failed to save driver position",
+	},
+
+	// Точки сбора
+	"point.not_found": {
+		RU: "Точка не найдена",
+		EN: "Collection point not found",
+	},
+	"point.container_count_negative": {
+		RU: "container_count не может быть отрицательным",
+		EN: "container_count must not be negative",
+	},
+	"point.container_count_positive": {
+		RU: "container_count должен быть больше нуля",
+		EN: "container_count must be greater than zero",
+	},
+	"point.location_required": {
+		RU: "Укажите адрес или координаты",
+		EN: "Provide an address or coordinates",
+	},
+	"point.geocode_failed": {
+		RU: "Не удалось определить координаты по адресу",
+		EN: "Could not determine coordinates for the address",
+	},
+	"point.reverse_failed": {
+		RU: "Не удалось определить адрес по координатам",
+		EN: "Could not determine the address for the coordinates",
+	},
+	"point.outside_service_area": {
+		RU: "Точка находится вне зоны обслуживания",
+		EN: "The point is outside the service area",
+	},
+	"point.service_area_check_failed": {
+		RU: "Ошибка проверки зоны обслуживания",
+		EN: "Failed to check the service area",
+	},
+	"point.driver_check_failed": {
+		RU: "Ошибка проверки водителей",
+		EN: "Failed to check drivers",
+	},
+	"point.get_failed": {
+		RU: "Ошибка получения точки",
+		EN: "Failed to load collection point",
+	},
+	"point.list_failed": {
+		RU: "Ошибка получения точек",
+		EN: "Failed to load collection points",
+	},
+	"point.search_failed": {
+		RU: "Ошибка поиска точек",
+		EN: "Failed to search collection points",
+	},
+	"point.create_failed": {
+		RU: "Ошибка создания точки",
+		EN: "Failed to create collection point",
+	},
+	"point.update_failed": {
+		RU: "Ошибка обновления точки",
+		EN: "Failed to update collection point",
+	},
+	"point.delete_failed": {
+		RU: "Ошибка удаления точки",
+		EN: "Failed to delete collection point",
+	},
+	"point.restore_failed": {
+		RU: "Ошибка восстановления точки",
+		EN: "Failed to restore collection point",
+	},
+	"point.not_archived": {
+		RU: "Точка не в архиве",
+		EN: "Collection point is not archived",
+	},
+	"point.window_pair": {
+		RU: "Окно обслуживания задаётся началом и концом вместе",
+		EN: "Service window start and end must be set together",
+	},
+	"point.window_order": {
+		RU: "Конец окна обслуживания должен быть позже начала",
+		EN: "Service window end must be after its start",
+	},
+	"point.weekday_range": {
+		RU: "День недели — число от 1 (понедельник) до 7 (воскресенье)",
+		EN: "Weekday must be a number from 1 (Monday) to 7 (Sunday)",
+	},
+
+	// Маршруты
+	"route.not_found": {
+		RU: "Маршрут не найден",
+		EN: "Route not found",
+	},
+	"route.status_params_required": {
+		RU: "route_id и status обязательны",
+		EN: "route_id and status are required",
+	},
+	"route.list_failed": {
+		RU: "Ошибка получения маршрутов",
+		EN: "Failed to load routes",
+	},
+	"route.get_failed": {
+		RU: "Ошибка получения маршрута",
+		EN: "Failed to load route",
+	},
+	"route.status_update_failed": {
+		RU: "Ошибка обновления статуса",
+		EN: "Failed to update status",
+	},
+	"route.geometry_format": {
+		RU: "format: допустимо %s",
+		EN: "format must be one of %s",
+	},
+	"route.geometry_failed": {
+		RU: "Ошибка построения линии маршрута",
+		EN: "Failed to build the route line",
+	},
+
+	// Геокодирование
+	"geocoding.disabled": {
+		RU: "Укажите и адрес, и координаты: геокодер не настроен",
+		EN: "Provide both address and coordinates: geocoder is not configured",
+	},
+	"geocoding.address_not_found": {
+		RU: "Адрес не найден",
+		EN: "Address not found",
+	},
+	"geocoding.upstream_failed": {
+		RU: "Ошибка сервиса геокодирования",
+		EN: "Geocoding service error",
+	},
+
+	// Районы
+	"district.not_found": {
+		RU: "Район не найден",
+		EN: "District not found",
+	},
+	"district.invalid_geojson": {
+		RU: "Неверный формат GeoJSON",
+		EN: "Invalid GeoJSON",
+	},
+	"district.expected_feature": {
+		RU: "Ожидается FeatureCollection или Feature",
+		EN: "Expected a FeatureCollection or Feature",
+	},
+	"district.empty": {
+		RU: "GeoJSON не содержит объектов",
+		EN: "GeoJSON contains no features",
+	},
+	"district.name_missing": {
+		RU: "Объект #%d: отсутствует свойство name",
+		EN: "Feature #%d: missing name property",
+	},
+	"district.invalid_kind": {
+		RU: "kind должен быть district или service_area",
+		EN: "kind must be district or service_area",
+	},
+	"district.duplicate_name": {
+		RU: "Район %s встречается в файле несколько раз",
+		EN: "District %s appears more than once in the file",
+	},
+	"district.invalid_geometry": {
+		RU: "Объект %s: %s",
+		EN: "Feature %s: %s",
+	},
+	"district.list_failed": {
+		RU: "Ошибка получения районов",
+		EN: "Failed to load districts",
+	},
+	"district.save_failed": {
+		RU: "Ошибка сохранения района",
+		EN: "Failed to save district",
+	},
+	"district.delete_failed": {
+		RU: "Ошибка удаления района",
+		EN: "Failed to delete district",
+	},
+
+	// Отчёты
+	"report.invalid_group": {
+		RU: "group_by может содержать только date, driver, city, district",
+		EN: "group_by may only contain date, driver, city, district",
+	},
+	"report.invalid_format": {
+		RU: "format должен быть json или csv",
+		EN: "format must be json or csv",
+	},
+	"report.failed": {
+		RU: "Ошибка построения отчёта",
+		EN: "Failed to build the report",
+	},
+	"report.col.date":              {RU: "Дата", EN: "Date"},
+	"report.col.driver_id":         {RU: "ID водителя", EN: "Driver ID"},
+	"report.col.driver_name":       {RU: "Водитель", EN: "Driver"},
+	"report.col.city":              {RU: "Город", EN: "City"},
+	"report.col.district":          {RU: "Район", EN: "District"},
+	"report.col.total":             {RU: "Всего", EN: "Total"},
+	"report.col.completed":         {RU: "Выполнено", EN: "Completed"},
+	"report.col.skipped":           {RU: "Пропущено", EN: "Skipped"},
+	"report.col.problem":           {RU: "Проблемы", EN: "Problems"},
+	"report.col.pending":           {RU: "Ожидают", EN: "Pending"},
+	"report.col.in_progress":       {RU: "В работе", EN: "In progress"},
+	"report.col.completed_pct":     {RU: "Выполнено, %", EN: "Completed, %"},
+	"report.col.skipped_pct":       {RU: "Пропущено, %", EN: "Skipped, %"},
+	"report.col.problem_pct":       {RU: "Проблемы, %", EN: "Problems, %"},
+	"report.col.avg_delay_minutes": {RU: "Среднее опоздание, мин", EN: "Average delay, min"},
+
+	// Обзор диспетчера
+	"dispatch.overview_failed": {
+		RU: "Ошибка построения обзора диспетчера",
+		EN: "Failed to build the dispatch overview",
+	},
+	"reroute.failed": {
+		RU: "Ошибка построения плана перераспределения",
+		EN: "Failed to build the reroute plan",
+	},
+	"reroute.apply_failed": {
+		RU: "Ошибка применения перераспределения",
+		EN: "Failed to apply the reroute",
+	},
+	"reroute.no_drivers": {
+		RU: "В этот день нет других водителей на смене",
+		EN: "No other drivers are on shift that day",
+	},
+	"reroute.stale": {
+		RU: "Остановка уже не ожидает объезда у этого водителя; постройте план заново",
+		EN: "A stop is no longer pending for this driver; build the plan again",
+	},
+	"reroute.driver_not_on_shift": {
+		RU: "Водитель не на смене в этот день",
+		EN: "The driver is not on shift that day",
+	},
+	"reroute.same_driver": {
+		RU: "Нельзя передать остановку тому же водителю",
+		EN: "A stop cannot be reassigned to the same driver",
+	},
+
+	// Планирование маршрутов города
+	"plan.failed": {
+		RU: "Ошибка планирования маршрутов",
+		EN: "Failed to plan routes",
+	},
+	"plan.depot_required": {
+		RU: "Укажите базу: в конфигурации DEPOT_LOCATION не задан",
+		EN: "Depot is required: DEPOT_LOCATION is not configured",
+	},
+	"plan.shift_order": {
+		RU: "Конец смены должен быть позже начала",
+		EN: "Shift end must be after shift start",
+	},
+	"plan.budget_range": {
+		RU: "Допустимо от 1 до %d мс",
+		EN: "Must be between 1 and %d ms",
+	},
+
+	// Маршрутный лист
+	"routesheet.failed": {
+		RU: "Ошибка формирования маршрутного листа",
+		EN: "Failed to generate the route sheet",
+	},
+	"routesheet.title": {
+		RU: "Маршрутный лист",
+		EN: "Route sheet",
+	},
+	"routesheet.driver": {
+		RU: "Водитель: %s",
+		EN: "Driver: %s",
+	},
+	"routesheet.date": {
+		RU: "Дата: %s",
+		EN: "Date: %s",
+	},
+	"routesheet.summary": {
+		RU: "Остановок: %d, контейнеров: %d",
+		EN: "Stops: %d, containers: %d",
+	},
+	"routesheet.signatures": {
+		RU: "Подпись водителя: ____________________    Подпись диспетчера: ____________________",
+		EN: "Driver signature: ____________________    Dispatcher signature: ____________________",
+	},
+	"routesheet.date_format":    {RU: "02.01.2006", EN: "2006-01-02"},
+	"routesheet.col.number":     {RU: "№", EN: "#"},
+	"routesheet.col.time":       {RU: "Время", EN: "Time"},
+	"routesheet.col.point":      {RU: "Точка", EN: "Point"},
+	"routesheet.col.address":    {RU: "Адрес", EN: "Address"},
+	"routesheet.col.containers": {RU: "Конт.", EN: "Cont."},
+	"routesheet.col.check":      {RU: "Отметка", EN: "Done"},
+	"routesheet.window":         {RU: "Окно %s–%s", EN: "Window %s–%s"},
+	"routesheet.gate_code":      {RU: "Код ворот: %s", EN: "Gate code: %s"},
+
+	// Администрирование
+	"admin.disabled": {
+		RU: "Действия администратора отключены: не задан ADMIN_TOKEN",
+		EN: "Admin actions are disabled: ADMIN_TOKEN is not set",
+	},
+	"admin.unauthorized": {
+		RU: "Неверный токен администратора",
+		EN: "Invalid admin token",
+	},
+
+	// Журнал изменений
+	"audit.list_failed": {
+		RU: "Ошибка получения журнала изменений",
+		EN: "Failed to get the audit log",
+	},
+}
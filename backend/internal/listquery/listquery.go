@@ -0,0 +1,231 @@
+// Package listquery — общий разбор параметров списков (limit, cursor, sort)
+// и построение SQL с фильтрами и постраничной выборкой по курсору.
+package listquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"garbage_trucks/backend/internal/i18n"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SortField описывает поле, по которому разрешена сортировка.
+// Column не должен возвращать NULL, иначе сравнение по курсору сломается.
+type SortField struct {
+	Column string
+	Type   string // SQL-тип для приведения значения курсора: int, text, timestamptz, float8
+}
+
+// DefaultLimit — размер страницы, если limit не задан: список целиком
+// можно получить только по страницам.
+const DefaultLimit = 100
+
+// Spec — допустимые сортировки и ограничения конкретного списка.
+type Spec struct {
+	IDColumn    string
+	Sorts       map[string]SortField
+	DefaultSort string
+	MaxLimit    int
+}
+
+// Params — разобранные параметры запроса списка.
+type Params struct {
+	Limit int
+	Sort  string
+	Desc  bool
+
+	cursor *cursor
+	spec   Spec
+}
+
+// cursor — место, где закончилась страница. Sort — сортировка, для которой
+// он выдан ("-поле" — по убыванию): значение другого поля нельзя
+// сравнивать с колонкой новой сортировки.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+// Page — единый конверт ответа для списков.
+type Page struct {
+	Items      interface{} `json:"items"`
+	NextCursor *string     `json:"next_cursor"`
+	Total      int         `json:"total"`
+}
+
+// ParseLimit читает limit для списков без курсора, например результатов
+// поиска: по умолчанию DefaultLimit, не больше MaxLimit.
+func ParseLimit(q url.Values, spec Spec) (int, error) {
+	limit := DefaultLimit
+	if spec.MaxLimit > 0 && spec.MaxLimit < limit {
+		limit = spec.MaxLimit
+	}
+
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return limit, i18n.Errorf("list.invalid_limit")
+		}
+		if spec.MaxLimit > 0 && n > spec.MaxLimit {
+			return limit, i18n.Errorf("list.limit_too_large", spec.MaxLimit)
+		}
+		limit = n
+	}
+
+	return limit, nil
+}
+
+// Parse читает limit (по умолчанию DefaultLimit, не больше MaxLimit),
+// cursor и sort (поле, "-поле" — по убыванию). Курсор принимается только
+// с той сортировкой, с которой он выдан.
+func Parse(q url.Values, spec Spec) (Params, error) {
+	p := Params{spec: spec}
+	limit, err := ParseLimit(q, spec)
+	if err != nil {
+		return p, err
+	}
+	p.Limit = limit
+
+	sortName := q.Get("sort")
+	if sortName == "" {
+		sortName = spec.DefaultSort
+	}
+	if strings.HasPrefix(sortName, "-") {
+		p.Desc = true
+		sortName = sortName[1:]
+	}
+	if _, ok := spec.Sorts[sortName]; !ok {
+		names := make([]string, 0, len(spec.Sorts))
+		for name := range spec.Sorts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return p, i18n.Errorf("list.invalid_sort", strings.Join(names, ", "))
+	}
+	p.Sort = sortName
+
+	if s := q.Get("cursor"); s != "" {
+		c, err := decodeCursor(s)
+		if err != nil || c.Sort != p.sortKey() {
+			return p, i18n.Errorf("list.invalid_cursor")
+		}
+		p.cursor = c
+	}
+
+	return p, nil
+}
+
+// sortKey — сортировка в виде параметра sort: "поле" или "-поле".
+func (p Params) sortKey() string {
+	if p.Desc {
+		return "-" + p.Sort
+	}
+	return p.Sort
+}
+
+// Builder накапливает условия WHERE и аргументы с нумерацией $1, $2, ...
+type Builder struct {
+	where []string
+	args  []interface{}
+}
+
+// Where добавляет условие; каждый символ ? заменяется на очередной $n.
+func (b *Builder) Where(cond string, args ...interface{}) {
+	for _, a := range args {
+		b.args = append(b.args, a)
+		cond = strings.Replace(cond, "?", "$"+strconv.Itoa(len(b.args)), 1)
+	}
+	b.where = append(b.where, cond)
+}
+
+// WhereSQL возвращает " WHERE ..." или пустую строку.
+func (b *Builder) WhereSQL() string {
+	if len(b.where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.where, " AND ")
+}
+
+// Args возвращает накопленные аргументы.
+func (b *Builder) Args() []interface{} {
+	return b.args
+}
+
+// Clone копирует построитель, чтобы одни и те же фильтры использовать
+// для подсчёта total и для выборки страницы.
+func (b *Builder) Clone() *Builder {
+	return &Builder{
+		where: append([]string(nil), b.where...),
+		args:  append([]interface{}(nil), b.args...),
+	}
+}
+
+// ApplyCursor добавляет условие продолжения выборки после курсора.
+func (p Params) ApplyCursor(b *Builder) {
+	if p.cursor == nil {
+		return
+	}
+	f := p.spec.Sorts[p.Sort]
+	op := ">"
+	if p.Desc {
+		op = "<"
+	}
+	// Значение курсора передаётся строкой и приводится к типу поля в SQL
+	b.Where(fmt.Sprintf("(%s, %s) %s ((?::text)::%s, ?)", f.Column, p.spec.IDColumn, op, f.Type), p.cursor.Value, p.cursor.ID)
+}
+
+// OrderSQL возвращает " ORDER BY ... LIMIT n" с запасом в одну строку,
+// чтобы понять, есть ли следующая страница.
+func (p Params) OrderSQL() string {
+	f := p.spec.Sorts[p.Sort]
+	dir := "ASC"
+	if p.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s LIMIT %d", f.Column, dir, p.spec.IDColumn, dir, p.Limit+1)
+}
+
+// Trim обрезает лишнюю строку и возвращает курсор следующей страницы.
+// key возвращает значение поля сортировки и id i-го элемента.
+func (p Params) Trim(n int, key func(i int) (interface{}, int)) (int, *string) {
+	if n <= p.Limit {
+		return n, nil
+	}
+	v, id := key(p.Limit - 1)
+	next := encodeCursor(cursor{Sort: p.sortKey(), Value: formatValue(v), ID: id})
+	return p.Limit, &next
+}
+
+func formatValue(v interface{}) string {
+	switch x := v.(type) {
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case string:
+		return x
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,74 @@
+package listquery
+
+import (
+	"net/url"
+	"testing"
+)
+
+var testSpec = Spec{
+	IDColumn: "id",
+	Sorts: map[string]SortField{
+		"id":   {Column: "id", Type: "int"},
+		"name": {Column: "name", Type: "text"},
+	},
+	DefaultSort: "id",
+	MaxLimit:    500,
+}
+
+func TestParseDefaultLimit(t *testing.T) {
+	p, err := Parse(url.Values{}, testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Limit != DefaultLimit {
+		t.Errorf("limit %d без параметра, ждали %d", p.Limit, DefaultLimit)
+	}
+
+	small := testSpec
+	small.MaxLimit = 20
+	if p, _ := Parse(url.Values{}, small); p.Limit != 20 {
+		t.Errorf("limit %d при MaxLimit 20", p.Limit)
+	}
+
+	if _, err := Parse(url.Values{"limit": {"501"}}, testSpec); err == nil {
+		t.Error("limit больше MaxLimit принят")
+	}
+}
+
+// Поиск без курсора тоже ограничен: limit=0 не означает «без ограничения».
+func TestParseLimit(t *testing.T) {
+	if limit, err := ParseLimit(url.Values{}, testSpec); err != nil || limit != DefaultLimit {
+		t.Errorf("limit %d, %v без параметра, ждали %d", limit, err, DefaultLimit)
+	}
+	if limit, err := ParseLimit(url.Values{"limit": {"500"}}, testSpec); err != nil || limit != 500 {
+		t.Errorf("limit %d, %v при limit=500", limit, err)
+	}
+	for _, s := range []string{"0", "-1", "501", "x"} {
+		if _, err := ParseLimit(url.Values{"limit": {s}}, testSpec); err == nil {
+			t.Errorf("limit=%s принят", s)
+		}
+	}
+}
+
+// Курсор страницы принимается со своей сортировкой и отклоняется с другой:
+// значение name нельзя сравнивать с колонкой id.
+func TestCursorBoundToSort(t *testing.T) {
+	p, err := Parse(url.Values{"sort": {"-name"}, "limit": {"2"}}, testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"Вера", "Борис", "Анна"}
+	n, next := p.Trim(len(names), func(i int) (interface{}, int) { return names[i], i + 1 })
+	if n != 2 || next == nil {
+		t.Fatalf("Trim: n=%d, next=%v", n, next)
+	}
+
+	if _, err := Parse(url.Values{"sort": {"-name"}, "cursor": {*next}}, testSpec); err != nil {
+		t.Errorf("курсор с той же сортировкой: %v", err)
+	}
+	for _, sort := range []string{"", "id", "name"} {
+		if _, err := Parse(url.Values{"sort": {sort}, "cursor": {*next}}, testSpec); err == nil {
+			t.Errorf("курсор от -name принят с sort=%q", sort)
+		}
+	}
+}
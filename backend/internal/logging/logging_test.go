@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"garbage_trucks/backend/internal/requestid"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	AddSecrets("s3cr3t-token", "abc")
+
+	tests := []struct {
+		in, want string
+	}{
+		{"token s3cr3t-token", "token " + Redacted},
+		// Короткие значения не считаются секретами
+		{"abc", "abc"},
+		{"postgres://app:hunter2@db:5432/trucks", "postgres://app:" + Redacted + "@db:5432/trucks"},
+		{"host=db password=hunter2 sslmode=off", "host=db password=" + Redacted + " sslmode=off"},
+		{"Password: hunter2", "Password: " + Redacted},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, ждали %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Секрет не должен попасть в журнал ни через сообщение, ни через атрибуты.
+func TestHandlerRedacts(t *testing.T) {
+	AddSecrets("s3cr3t-token")
+
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf, slog.LevelInfo, FormatText))
+	ctx := requestid.WithID(context.Background(), "req-1")
+
+	logger.InfoContext(ctx, "подключение к postgres://app:hunter2@db/trucks с токеном s3cr3t-token",
+		"admin_token", "anything",
+		"dsn", "host=db password=hunter2",
+		"err", errors.New("auth s3cr3t-token failed"),
+	)
+
+	out := buf.String()
+	for _, leaked := range []string{"hunter2", "s3cr3t-token", "anything"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("в журнале %q: %s", leaked, out)
+		}
+	}
+	if !strings.Contains(out, "request_id=req-1") {
+		t.Errorf("нет request_id: %s", out)
+	}
+}
@@ -0,0 +1,154 @@
+// Package metrics собирает метрики сервиса в формате Prometheus: запросы
+// HTTP по шаблонам маршрутов, состояние пула соединений с базой
+// и показатели текущего дня (остановки, водители на смене).
+package metrics
+
+import (
+	"context"
+	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/models"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "garbage_trucks"
+
+// Сколько ждать базу при сборе показателей дня, чтобы медленный запрос
+// не задерживал весь ответ /metrics
+const statsTimeout = 5 * time.Second
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Число HTTP-запросов по маршруту, методу и коду ответа.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Время обработки HTTP-запроса по маршруту и методу.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		poolCollector{},
+		dayCollector{},
+	)
+}
+
+// Handler отдаёт метрики для Prometheus.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest учитывает обработанный запрос. route — шаблон маршрута
+// (/api/drivers/{id}), а не путь, чтобы число рядов не зависело от id.
+func ObserveRequest(route, method string, status int, elapsed time.Duration) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(elapsed.Seconds())
+}
+
+func desc(name, help string, labels ...string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil)
+}
+
+// poolCollector снимает статистику pgxpool в момент запроса метрик; метка
+// pool — primary или replica.
+type poolCollector struct{}
+
+var (
+	poolAcquired        = desc("db_pool_acquired_connections", "Соединения, занятые запросами.", "pool")
+	poolIdle            = desc("db_pool_idle_connections", "Свободные соединения в пуле.", "pool")
+	poolTotal           = desc("db_pool_total_connections", "Все открытые соединения пула.", "pool")
+	poolMax             = desc("db_pool_max_connections", "Максимальный размер пула.", "pool")
+	poolAcquireCount    = desc("db_pool_acquires_total", "Успешные получения соединения из пула.", "pool")
+	poolAcquireDuration = desc("db_pool_acquire_duration_seconds_total", "Суммарное время ожидания соединения.", "pool")
+	poolEmptyAcquire    = desc("db_pool_empty_acquires_total", "Получения соединения, которым пришлось ждать.", "pool")
+	poolCanceledAcquire = desc("db_pool_canceled_acquires_total", "Ожидания соединения, прерванные отменой контекста.", "pool")
+)
+
+func (poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquired
+	ch <- poolIdle
+	ch <- poolTotal
+	ch <- poolMax
+	ch <- poolAcquireCount
+	ch <- poolAcquireDuration
+	ch <- poolEmptyAcquire
+	ch <- poolCanceledAcquire
+}
+
+func (poolCollector) Collect(ch chan<- prometheus.Metric) {
+	collectPool(ch, database.Pool, "primary")
+	collectPool(ch, database.ReplicaPool, "replica")
+}
+
+func collectPool(ch chan<- prometheus.Metric, pool *pgxpool.Pool, name string) {
+	if pool == nil {
+		return
+	}
+	s := pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquired, prometheus.GaugeValue, float64(s.AcquiredConns()), name)
+	ch <- prometheus.MustNewConstMetric(poolIdle, prometheus.GaugeValue, float64(s.IdleConns()), name)
+	ch <- prometheus.MustNewConstMetric(poolTotal, prometheus.GaugeValue, float64(s.TotalConns()), name)
+	ch <- prometheus.MustNewConstMetric(poolMax, prometheus.GaugeValue, float64(s.MaxConns()), name)
+	ch <- prometheus.MustNewConstMetric(poolAcquireCount, prometheus.CounterValue, float64(s.AcquireCount()), name)
+	ch <- prometheus.MustNewConstMetric(poolAcquireDuration, prometheus.CounterValue, s.AcquireDuration().Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquire, prometheus.CounterValue, float64(s.EmptyAcquireCount()), name)
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquire, prometheus.CounterValue, float64(s.CanceledAcquireCount()), name)
+}
+
+// dayCollector считает остановки и водителей на смене за сегодня запросом
+// к базе при каждом сборе метрик. Если база недоступна, показатели дня
+// пропускаются, а stats_up становится 0.
+type dayCollector struct{}
+
+var (
+	dayStops = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "stops_today"),
+		"Остановки на сегодня по статусу.", []string{"status"}, nil)
+	dayDriversOnShift = desc("drivers_on_shift", "Водители, начавшие маршрут и ещё не закончившие его.")
+	dayStatsUp        = desc("stats_up", "1, если показатели дня удалось получить из базы.")
+)
+
+func (dayCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dayStops
+	ch <- dayDriversOnShift
+	ch <- dayStatsUp
+}
+
+func (dayCollector) Collect(ch chan<- prometheus.Metric) {
+	if database.Pool == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), statsTimeout)
+	defer cancel()
+	s, err := models.GetTodayStats(ctx)
+	if err != nil {
+		slog.Error("Ошибка сбора показателей дня", "err", err)
+		ch <- prometheus.MustNewConstMetric(dayStatsUp, prometheus.GaugeValue, 0)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(dayStatsUp, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(dayStops, prometheus.GaugeValue, float64(s.Pending), models.RouteStatusPending)
+	ch <- prometheus.MustNewConstMetric(dayStops, prometheus.GaugeValue, float64(s.InProgress), models.RouteStatusInProgress)
+	ch <- prometheus.MustNewConstMetric(dayStops, prometheus.GaugeValue, float64(s.Completed), models.RouteStatusCompleted)
+	ch <- prometheus.MustNewConstMetric(dayDriversOnShift, prometheus.GaugeValue, float64(s.DriversOnShift))
+}
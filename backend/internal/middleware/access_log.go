@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Проверки оркестратора и сбор метрик идут каждые несколько секунд;
+// в журнал доступа они попадают только на уровне debug
+var quietRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// AccessLog пишет в журнал каждый запрос: метод, путь, шаблон маршрута,
+// код ответа, размер и время обработки. Ответы 5xx пишутся с уровнем
+// error. Идентификатор запроса добавляет обработчик журнала из контекста,
+// поэтому AccessLog подключается после RequestID.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := routeTemplate(r)
+		status := rec.code()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case quietRoutes[route]:
+			level = slog.LevelDebug
+		}
+
+		slog.Default().LogAttrs(r.Context(), level, "HTTP-запрос",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Int("bytes", rec.bytes),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("remote", r.RemoteAddr),
+		)
+	})
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"garbage_trucks/backend/internal/actor"
+	"net"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Столько же символов хранит колонка audit_log.actor
+const maxActorLen = 100
+
+// Actor берёт имя исполнителя из заголовка X-Actor для журнала изменений.
+// Пустое или некорректное значение заменяется на anonymous. Имя ничем не
+// подтверждено, поэтому рядом с ним записывается адрес соединения.
+func Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSpace(r.Header.Get(actor.Header))
+		if name == "" || utf8.RuneCountInString(name) > maxActorLen || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+			name = actor.Anonymous
+		}
+
+		// Адрес без порта; за прокси это адрес прокси
+		addr := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+
+		ctx := actor.WithRemoteAddr(actor.WithName(r.Context(), name), addr)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
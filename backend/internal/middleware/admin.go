@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"garbage_trucks/backend/internal/apierror"
+	"net/http"
+	"strings"
+)
+
+var adminToken string
+
+// Admin пропускает запрос только с заголовком Authorization: Bearer
+// <ADMIN_TOKEN>. Если токен не задан, действия администратора отключены.
+func Admin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			apierror.Write(w, r, apierror.Forbidden("admin.disabled"))
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			apierror.Write(w, r, apierror.Unauthorized("admin.unauthorized"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
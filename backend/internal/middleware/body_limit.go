@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"garbage_trucks/backend/internal/apierror"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultMaxBodySize — ограничение тела запроса, если для маршрута не
+// задано другое.
+const DefaultMaxBodySize = 1 << 20
+
+// BodyLimit ограничивает размер тела запроса: limits задаёт ограничение
+// для шаблона пути маршрута, остальным — defaultLimit. Запрос с
+// Content-Length больше ограничения сразу получает 413; тело без длины
+// обрезается, и ошибку чтения превращает в 413 openapi.Validate. Должно
+// стоять до неё: проверка читает тело целиком ещё до обработчика.
+func BodyLimit(defaultLimit int64, limits map[string]int64) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limit := defaultLimit
+			if route := mux.CurrentRoute(r); route != nil {
+				if path, err := route.GetPathTemplate(); err == nil {
+					if l, ok := limits[path]; ok {
+						limit = l
+					}
+				}
+			}
+
+			if r.ContentLength > limit {
+				apierror.Write(w, r, apierror.TooLarge("request.too_large", limit))
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
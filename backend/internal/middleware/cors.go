@@ -1,22 +1,170 @@
 package middleware
 
-import "net/http"
-
-func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Разрешаем все origins временно
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, PATCH, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Max-Age", "3600")
-		
-		// ВАЖНО: обрабатываем OPTIONS ДО вызова next
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
+import (
+	"fmt"
+	"garbage_trucks/backend/internal/apierror"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Заголовки, которые фронтенд может передавать и читать в ответе
+const (
+	corsAllowHeaders  = "Content-Type, Authorization, X-Requested-With, X-Actor, If-Match, If-None-Match"
+	corsExposeHeaders = "ETag, X-Request-ID"
+	corsMaxAge        = "3600"
+)
+
+// Методы, которые проверяются по маршрутизатору при ответе на preflight
+var corsMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// originPattern — разрешённый источник: схема, хост и порт. Хост вида
+// *.example.com разрешает любой поддомен первого уровня, но не сам
+// example.com.
+type originPattern struct {
+	scheme   string
+	host     string // для шаблона — суффикс .example.com
+	port     string
+	wildcard bool
+}
+
+// corsOrigins — разрешённые источники из FRONTEND_URL; corsAnyOrigin —
+// в списке есть *, и запросы принимаются с любого источника, но без
+// учётных данных.
+var (
+	corsOrigins   []originPattern
+	corsAnyOrigin bool
+)
+
+// parseOrigins разбирает список источников через запятую:
+// https://app.example.com, https://*.vercel.app, http://localhost:3000, *.
+func parseOrigins(s string) ([]originPattern, bool, error) {
+	var patterns []originPattern
+	anyOrigin := false
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSuffix(strings.TrimSpace(item), "/")
+		if item == "" {
+			continue
+		}
+		if item == "*" {
+			anyOrigin = true
+			continue
+		}
+
+		u, err := url.Parse(strings.ToLower(item))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" || u.User != nil {
+			return nil, false, fmt.Errorf("неверный источник в FRONTEND_URL: %q", item)
+		}
+
+		p := originPattern{scheme: u.Scheme, host: u.Hostname(), port: u.Port()}
+		if rest, ok := strings.CutPrefix(p.host, "*."); ok {
+			if rest == "" || strings.Contains(rest, "*") {
+				return nil, false, fmt.Errorf("неверный шаблон в FRONTEND_URL: %q", item)
+			}
+			p.host, p.wildcard = "."+rest, true
+		} else if strings.Contains(p.host, "*") {
+			return nil, false, fmt.Errorf("неверный шаблон в FRONTEND_URL: %q (* допустима только в начале хоста)", item)
 		}
-		
-		next.ServeHTTP(w, r)
-	})
-}
\ No newline at end of file
+		patterns = append(patterns, p)
+	}
+	return patterns, anyOrigin, nil
+}
+
+// match проверяет источник из заголовка Origin, уже разобранный url.Parse.
+func (p originPattern) match(u *url.URL) bool {
+	if u.Scheme != p.scheme || u.Port() != p.port {
+		return false
+	}
+	host := u.Hostname()
+	if !p.wildcard {
+		return host == p.host
+	}
+	sub, ok := strings.CutSuffix(host, p.host)
+	return ok && sub != "" && !strings.Contains(sub, ".")
+}
+
+// allowedOrigin проверяет заголовок Origin по списку разрешённых.
+func allowedOrigin(origin string) bool {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	for _, p := range corsOrigins {
+		if p.match(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeMethods возвращает методы, для которых в маршрутизаторе есть
+// маршрут с путём запроса.
+func routeMethods(router *mux.Router, r *http.Request) []string {
+	var methods []string
+	for _, m := range corsMethods {
+		req := r.Clone(r.Context())
+		req.Method = m
+		var match mux.RouteMatch
+		if router.Match(req, &match) && match.MatchErr == nil {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+// CORS разрешает запросы фронтенда с источников из FRONTEND_URL.
+// Разрешённому источнику возвращается он сам с Allow-Credentials, для
+// остальных заголовки CORS не ставятся, и браузер ответ не отдаст.
+// Ответ зависит от Origin, поэтому всегда добавляется Vary: Origin.
+//
+// Preflight-запросы завершаются здесь: методы берутся из маршрутизатора
+// для пути запроса, несуществующий путь — 404, чужой источник — 403.
+func CORS(router *mux.Router) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			allowed := false
+			switch {
+			case origin == "":
+			case allowedOrigin(origin):
+				allowed = true
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Set("Access-Control-Allow-Credentials", "true")
+			case corsAnyOrigin:
+				allowed = true
+				h.Set("Access-Control-Allow-Origin", "*")
+			}
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !preflight {
+				if allowed {
+					h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			if !allowed {
+				apierror.Write(w, r, apierror.Forbidden("cors.origin_not_allowed", origin))
+				return
+			}
+			methods := routeMethods(router, r)
+			if len(methods) == 0 {
+				apierror.Write(w, r, apierror.NotFound("error.not_found_route"))
+				return
+			}
+
+			h.Set("Access-Control-Allow-Methods", strings.Join(append(methods, http.MethodOptions), ", "))
+			h.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			h.Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
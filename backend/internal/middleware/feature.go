@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/config"
+	"net/http"
+)
+
+var features config.Features
+
+// Feature пропускает запрос, только если возможность включена
+// в конфигурации (FEATURE_<ИМЯ>); иначе маршрут отвечает 404.
+func Feature(name config.Feature, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !features.Enabled(name) {
+			apierror.Write(w, r, apierror.NotFound("error.feature_disabled", string(name)))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
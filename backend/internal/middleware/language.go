@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"garbage_trucks/backend/internal/i18n"
+	"net/http"
+)
+
+// Language выбирает язык ответа по заголовку Accept-Language (параметр
+// lang в запросе имеет приоритет) и сохраняет его в контексте запроса.
+func Language(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := i18n.Negotiate(r.Header.Get("Accept-Language"))
+		if l := r.URL.Query().Get("lang"); l != "" {
+			lang = i18n.Negotiate(l)
+		}
+
+		w.Header().Set("Content-Language", string(lang))
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(i18n.WithLang(r.Context(), lang)))
+	})
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"garbage_trucks/backend/internal/metrics"
+	"net/http"
+	"time"
+)
+
+// Metrics считает запросы и время их обработки по шаблону маршрута.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		metrics.ObserveRequest(routeTemplate(r), r.Method, rec.code(), time.Since(start))
+	})
+}
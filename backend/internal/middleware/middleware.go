@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"garbage_trucks/backend/internal/config"
+	"garbage_trucks/backend/internal/logging"
+)
+
+// Init читает настройки middleware из конфигурации.
+func Init(cfg *config.Config) {
+	adminToken = cfg.AdminToken
+	requestTimeout = cfg.RequestTimeout
+	features = cfg.Features
+
+	var err error
+	corsOrigins, corsAnyOrigin, err = parseOrigins(cfg.FrontendURL)
+	if err != nil {
+		logging.Fatal("Ошибка настройки CORS", "err", err)
+	}
+}
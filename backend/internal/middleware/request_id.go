@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"garbage_trucks/backend/internal/requestid"
+	"net/http"
+	"regexp"
+)
+
+// Допускаем идентификаторы от прокси только разумного вида
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID присваивает каждому запросу идентификатор (или берёт его из
+// заголовка X-Request-ID) и возвращает его в ответе.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !validRequestID.MatchString(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.WithID(r.Context(), id)))
+	})
+}
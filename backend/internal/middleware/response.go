@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Метка маршрута для запросов, не попавших ни в один маршрут (404, 405)
+const unmatchedRoute = "unmatched"
+
+// statusRecorder запоминает код ответа и размер тела, отправленные
+// обработчиком.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap даёт http.ResponseController доступ к исходному ResponseWriter.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// code возвращает код ответа; обработчик, ничего не записавший, ответил 200.
+func (w *statusRecorder) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// routeTemplate возвращает шаблон сработавшего маршрута (/api/drivers/{id})
+// или unmatchedRoute.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tpl, err := current.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return unmatchedRoute
+}
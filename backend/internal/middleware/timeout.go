@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+var requestTimeout time.Duration
+
+// Timeout ограничивает время обработки запроса: по истечении
+// HTTP_REQUEST_TIMEOUT контекст запроса отменяется, и запросы к базе
+// и геокодеру прерываются. Контекст отменяется и при разрыве соединения
+// клиентом.
+func Timeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestTimeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/listquery"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Водители и точки не удаляются, а архивируются: колонка archived_at.
+// Маршруты архивных записей остаются в базе, поэтому отчёты по истории
+// их видят. Окончательно удаляет запись только Purge* (действие
+// администратора), и только уже архивную.
+
+// ErrNotArchived — восстановление или окончательное удаление записи,
+// которая не в архиве.
+var ErrNotArchived = errors.New("record is not archived")
+
+// Фильтр по архиву в списках
+const (
+	ArchivedExclude = "exclude" // только действующие (по умолчанию)
+	ArchivedInclude = "include" // действующие и архивные
+	ArchivedOnly    = "only"    // только архивные
+)
+
+// ArchivedValues — допустимые значения параметра archived.
+var ArchivedValues = []string{ArchivedExclude, ArchivedInclude, ArchivedOnly}
+
+// whereArchived добавляет условие фильтра по архиву для колонки column.
+func whereArchived(b *listquery.Builder, column, archived string) {
+	switch archived {
+	case ArchivedInclude:
+	case ArchivedOnly:
+		b.Where(column + " IS NOT NULL")
+	default:
+		b.Where(column + " IS NULL")
+	}
+}
+
+// archiveRow помечает запись архивной. Уже архивная запись считается
+// отсутствующей, как и несуществующая.
+func archiveRow(ctx context.Context, table string, id int) error {
+	return database.InTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, `
+			UPDATE `+table+` SET archived_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND archived_at IS NULL
+		`, id)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+		return nil
+	})
+}
+
+// lockArchived блокирует запись до конца транзакции и проверяет, что она
+// в архиве.
+func lockArchived(ctx context.Context, tx pgx.Tx, table string, id int) error {
+	var archived bool
+	err := tx.QueryRow(ctx, `
+		SELECT archived_at IS NOT NULL FROM `+table+` WHERE id = $1 FOR UPDATE
+	`, id).Scan(&archived)
+	if err != nil {
+		return err
+	}
+	if !archived {
+		return ErrNotArchived
+	}
+	return nil
+}
+
+// restoreRow возвращает запись из архива.
+func restoreRow(ctx context.Context, table string, id int) error {
+	return database.InTx(ctx, func(tx pgx.Tx) error {
+		if err := lockArchived(ctx, tx, table, id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `UPDATE `+table+` SET archived_at = NULL WHERE id = $1`, id)
+		return err
+	})
+}
+
+// purgeRow окончательно удаляет архивную запись вместе с её маршрутами.
+func purgeRow(ctx context.Context, table string, id int) error {
+	return database.InTx(ctx, func(tx pgx.Tx) error {
+		if err := lockArchived(ctx, tx, table, id); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE id = $1`, id)
+		return err
+	})
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/listquery"
+	"time"
+)
+
+// Сущности журнала изменений — первый аргумент триггера audit_row_change
+const (
+	AuditEntityDriver   = "driver"
+	AuditEntityPoint    = "point"
+	AuditEntityRoute    = "route"
+	AuditEntityDistrict = "district"
+)
+
+// Действия журнала изменений
+const (
+	AuditActionCreate       = "create"
+	AuditActionUpdate       = "update"
+	AuditActionDelete       = "delete"
+	AuditActionStatusChange = "status_change"
+	AuditActionArchive      = "archive"
+	AuditActionRestore      = "restore"
+)
+
+var (
+	AuditEntities = []string{AuditEntityDriver, AuditEntityPoint, AuditEntityRoute, AuditEntityDistrict}
+	AuditActions  = []string{
+		AuditActionCreate, AuditActionUpdate, AuditActionDelete,
+		AuditActionStatusChange, AuditActionArchive, AuditActionRestore,
+	}
+)
+
+// AuditEntry — запись журнала: состояние строки до и после изменения.
+// Before пуст для создания, After — для удаления. Actor клиент указывает
+// сам, поэтому рядом хранится адрес, с которого пришёл запрос.
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	Entity     string          `json:"entity"`
+	EntityID   *int            `json:"entity_id"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	RequestID  *string         `json:"request_id,omitempty"`
+	RemoteAddr *string         `json:"remote_addr,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter — фильтры журнала изменений.
+type AuditFilter struct {
+	Entity   string
+	EntityID *int
+	Actor    string
+	Actions  []string
+	From     *time.Time // created_at >= From
+	To       *time.Time // created_at < To
+}
+
+var AuditListSpec = listquery.Spec{
+	IDColumn: "id",
+	Sorts: map[string]listquery.SortField{
+		"id":         {Column: "id", Type: "bigint"},
+		"created_at": {Column: "created_at", Type: "timestamptz"},
+	},
+	DefaultSort: "-created_at",
+	MaxLimit:    1000,
+}
+
+func ListAudit(ctx context.Context, f AuditFilter, p listquery.Params) (*listquery.Page, error) {
+	b := &listquery.Builder{}
+	if f.Entity != "" {
+		b.Where("entity = ?", f.Entity)
+	}
+	if f.EntityID != nil {
+		b.Where("entity_id = ?", *f.EntityID)
+	}
+	if f.Actor != "" {
+		b.Where("actor = ?", f.Actor)
+	}
+	if len(f.Actions) > 0 {
+		b.Where("action = ANY(?)", f.Actions)
+	}
+	if f.From != nil {
+		b.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		b.Where("created_at < ?", *f.To)
+	}
+
+	var total int
+	if err := database.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM audit_log`+b.WhereSQL(), b.Args()...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	p.ApplyCursor(b)
+	rows, err := database.Pool.Query(ctx, `
+		SELECT id, actor, action, entity, entity_id, before, after, request_id, remote_addr, created_at
+		FROM audit_log`+b.WhereSQL()+p.OrderSQL(), b.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var before, after []byte
+		if err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.Entity, &e.EntityID, &before, &after, &e.RequestID, &e.RemoteAddr, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if before != nil {
+			e.Before = before
+		}
+		if after != nil {
+			e.After = after
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := p.Trim(len(entries), func(i int) (interface{}, int) {
+		if p.Sort == "created_at" {
+			return entries[i].CreatedAt, int(entries[i].ID)
+		}
+		return entries[i].ID, int(entries[i].ID)
+	})
+
+	return &listquery.Page{Items: entries[:n], NextCursor: next, Total: total}, nil
+}
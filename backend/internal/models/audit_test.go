@@ -0,0 +1,45 @@
+package models
+
+import (
+	"garbage_trucks/backend/internal/actor"
+	"garbage_trucks/backend/internal/database/dbtest"
+	"garbage_trucks/backend/internal/listquery"
+	"garbage_trucks/backend/internal/requestid"
+	"net/url"
+	"testing"
+)
+
+// Рядом с именем из X-Actor в журнал попадают адрес клиента и
+// идентификатор запроса.
+func TestAuditRecordsRemoteAddr(t *testing.T) {
+	ctx := dbtest.Open(t)
+	reqCtx := actor.WithRemoteAddr(actor.WithName(requestid.WithID(ctx, "req-1"), "Диспетчер"), "203.0.113.7")
+
+	driver, err := CreateDriver(reqCtx, "Водитель")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := listquery.Parse(url.Values{}, AuditListSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := ListAudit(ctx, AuditFilter{Entity: AuditEntityDriver, EntityID: &driver.ID}, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := page.Items.([]AuditEntry)
+	if len(entries) != 1 {
+		t.Fatalf("записей %d, ждали 1", len(entries))
+	}
+	e := entries[0]
+	if e.Actor != "Диспетчер" || e.Action != AuditActionCreate {
+		t.Errorf("исполнитель %q, действие %q", e.Actor, e.Action)
+	}
+	if e.RemoteAddr == nil || *e.RemoteAddr != "203.0.113.7" {
+		t.Errorf("адрес %v, ждали 203.0.113.7", e.RemoteAddr)
+	}
+	if e.RequestID == nil || *e.RequestID != "req-1" {
+		t.Errorf("идентификатор запроса %v, ждали req-1", e.RequestID)
+	}
+}
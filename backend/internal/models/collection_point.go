@@ -2,157 +2,352 @@ package models
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/listquery"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Максимальные длины строковых полей — размеры колонок collection_points
+const (
+	PointNameMaxLen    = 200
+	PointAddressMaxLen = 300
+	PointCityMaxLen    = 100
 )
 
 type CollectionPoint struct {
-	ID        int      `json:"id"`
-	Name      string   `json:"name"`
-	Address   string   `json:"address"`
-	Latitude  float64  `json:"latitude"`
-	Longitude float64  `json:"longitude"`
-	City      string   `json:"city"`
-	Drivers   []string `json:"drivers,omitempty"`
+	ID             int      `json:"id"`
+	Name           string   `json:"name"`
+	Address        string   `json:"address"`
+	Latitude       float64  `json:"latitude"`
+	Longitude      float64  `json:"longitude"`
+	City           string   `json:"city"`
+	ContainerCount int      `json:"container_count"`
+	DistrictID     *int     `json:"district_id,omitempty"`
+	PointRestrictions
+	Drivers        []string   `json:"drivers,omitempty"`
+	Distance       *float64   `json:"distance_m,omitempty"`
+	ArchivedAt     *time.Time `json:"archived_at,omitempty"`
+	Version        int        `json:"version"`
 }
 
-func GetAllPoints(ctx context.Context) ([]CollectionPoint, error) {
-	rows, err := database.Pool.Query(ctx, `
-		SELECT 
-			cp.id, cp.name, cp.address, cp.latitude, cp.longitude, cp.city,
-			COALESCE(ARRAY_AGG(DISTINCT d.name) FILTER (WHERE d.name IS NOT NULL), '{}') as drivers
-		FROM collection_points cp
-		LEFT JOIN routes r ON cp.id = r.point_id
-		LEFT JOIN drivers d ON r.driver_id = d.id
-		GROUP BY cp.id, cp.name, cp.address, cp.latitude, cp.longitude, cp.city
-		ORDER BY cp.id
-	`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+// activeStopStatuses — статусы остановок, которые ещё впереди. Водитель
+// назначен на точку, пока у него есть такая остановка; пройденные,
+// пропущенные и проблемные остаются только в истории.
+const activeStopStatuses = `('pending', 'in_progress')`
 
-	var points []CollectionPoint
-	for rows.Next() {
-		var p CollectionPoint
-		if err := rows.Scan(&p.ID, &p.Name, &p.Address, &p.Latitude, &p.Longitude, &p.City, &p.Drivers); err != nil {
-			return nil, err
-		}
-		points = append(points, p)
-	}
-
-	return points, rows.Err()
-}
+// pointDriversColumn — имена действующих водителей, назначенных на точку
+// cp. Подзапрос выполняется только для выбранных строк, поэтому страница
+// или найденные индексом точки не требуют соединения со всеми маршрутами.
+const pointDriversColumn = `COALESCE((
+	SELECT ARRAY_AGG(DISTINCT d.name)
+	FROM routes r
+	JOIN drivers d ON d.id = r.driver_id
+	WHERE r.point_id = cp.id AND d.archived_at IS NULL AND r.status IN ` + activeStopStatuses + `
+), '{}') as drivers`
 
-func CreatePoint(ctx context.Context, name, address, city string, latitude, longitude float64) (*CollectionPoint, error) {
+func insertPoint(ctx context.Context, tx pgx.Tx, name, address, city string, latitude, longitude float64, containerCount int, rs PointRestrictions) (*CollectionPoint, error) {
 	var point CollectionPoint
-	err := database.Pool.QueryRow(ctx, `
-		INSERT INTO collection_points (name, address, latitude, longitude, city) 
-		VALUES ($1, $2, $3, $4, $5) 
-		RETURNING id, name, address, latitude, longitude, city
-	`, name, address, latitude, longitude, city).Scan(&point.ID, &point.Name, &point.Address, &point.Latitude, &point.Longitude, &point.City)
-	
+	err := tx.QueryRow(ctx, `
+		INSERT INTO collection_points (name, address, latitude, longitude, city, container_count,
+			window_start, window_end, excluded_weekdays, access_notes, gate_code) 
+		VALUES ($1, $2, $3, $4, $5, $6,
+			NULLIF($7, '')::time, NULLIF($8, '')::time, COALESCE($9::smallint[], '{}'), NULLIF($10, ''), NULLIF($11, '')) 
+		RETURNING id, name, address, latitude, longitude, city, container_count, version, `+pointRestrictionColumns("")+`
+	`, name, address, latitude, longitude, city, containerCount,
+		rs.WindowStart, rs.WindowEnd, rs.ExcludedWeekdays, rs.AccessNotes, rs.GateCode).Scan(append([]interface{}{&point.ID, &point.Name, &point.Address, &point.Latitude, &point.Longitude, &point.City, &point.ContainerCount, &point.Version}, point.scanTargets()...)...)
 	if err != nil {
 		return nil, err
 	}
-	
 	return &point, nil
 }
 
-func CreatePointWithDrivers(ctx context.Context, name, address, city string, latitude, longitude float64, driverIDs []int) (*CollectionPoint, error) {
-	// Создаем точку
-	point, err := CreatePoint(ctx, name, address, city, latitude, longitude)
+// CreatePointWithDrivers создаёт точку с ограничениями обслуживания,
+// определяет её район и добавляет точку в конец маршрута каждого из
+// водителей — всё в одной транзакции.
+func CreatePointWithDrivers(ctx context.Context, name, address, city string, latitude, longitude float64, containerCount int, rs PointRestrictions, driverIDs []int) (*CollectionPoint, error) {
+	var point *CollectionPoint
+	err := database.InTx(ctx, func(tx pgx.Tx) error {
+		var err error
+		point, err = insertPoint(ctx, tx, name, address, city, latitude, longitude, containerCount, rs)
+		if err != nil {
+			return err
+		}
+
+		// Определяем район по полигонам
+		if err := assignPointDistrict(ctx, tx, point); err != nil {
+			return err
+		}
+
+		slog.DebugContext(ctx, "Создана точка", "point_id", point.ID, "driver_ids", driverIDs)
+		return appendPointRoutes(ctx, tx, point.ID, driverIDs)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("Created point %d, adding drivers: %v", point.ID, driverIDs)
+	pointIndex.Insert(point.ID, point.Latitude, point.Longitude)
+	return point, nil
+}
 
-	// Создаем маршруты для каждого водителя
+// appendPointRoutes создаёт маршрут для каждого водителя: точка встаёт в
+// конец его списка остановок. Остановка назначается на ближайший день,
+// когда вывоз на точке разрешён, и не раньше начала окна обслуживания.
+func appendPointRoutes(ctx context.Context, tx pgx.Tx, pointID int, driverIDs []int) error {
 	for _, driverID := range driverIDs {
 		// Получаем следующий order_number для водителя
 		var maxOrder int
-		err := database.Pool.QueryRow(ctx, `
+		err := tx.QueryRow(ctx, `
 			SELECT COALESCE(MAX(order_number), 0) FROM routes WHERE driver_id = $1
 		`, driverID).Scan(&maxOrder)
 		if err != nil {
-			log.Printf("Error getting max order for driver %d: %v", driverID, err)
-			continue
+			return err
 		}
 
 		// Создаем маршрут
-		_, err = database.Pool.Exec(ctx, `
+		_, err = tx.Exec(ctx, `
 			INSERT INTO routes (driver_id, point_id, order_number, scheduled_at, status)
-			VALUES ($1, $2, $3, CURRENT_DATE + TIME '08:00:00' + (INTERVAL '10 minutes' * ($3 - 1)), 'pending')
-		`, driverID, point.ID, maxOrder+1)
+			SELECT $1, $2, $3, GREATEST(
+				d.day + TIME '08:00:00' + (INTERVAL '10 minutes' * ($3 - 1)),
+				d.day + COALESCE(cp.window_start, TIME '00:00:00')), 'pending'
+			FROM collection_points cp
+			CROSS JOIN LATERAL (
+				SELECT CURRENT_DATE + COALESCE(MIN(k), 0) AS day
+				FROM generate_series(0, 6) k
+				WHERE NOT (EXTRACT(ISODOW FROM CURRENT_DATE + k)::smallint = ANY(cp.excluded_weekdays))
+			) d
+			WHERE cp.id = $2
+		`, driverID, pointID, maxOrder+1)
 		if err != nil {
-			log.Printf("Error creating route for driver %d, point %d: %v", driverID, point.ID, err)
-			continue
+			return err
 		}
-		log.Printf("Created route: driver=%d, point=%d, order=%d", driverID, point.ID, maxOrder+1)
+		slog.DebugContext(ctx, "Создана остановка", "driver_id", driverID, "point_id", pointID, "order", maxOrder+1)
+	}
+	return nil
+}
+
+// GetPointByID возвращает точку с водителями, в том числе архивную,
+// вместе с кодом ворот.
+func GetPointByID(ctx context.Context, id int) (*CollectionPoint, error) {
+	var p CollectionPoint
+	err := database.Pool.QueryRow(ctx, `
+		SELECT 
+			cp.id, cp.name, cp.address, cp.latitude, cp.longitude, cp.city, cp.container_count, cp.district_id, cp.archived_at, cp.version,
+			`+pointDriversColumn+`,
+			`+pointRestrictionColumnsWithGateCode("cp.")+`
+		FROM collection_points cp
+		WHERE cp.id = $1
+	`, id).Scan(append([]interface{}{&p.ID, &p.Name, &p.Address, &p.Latitude, &p.Longitude, &p.City, &p.ContainerCount, &p.DistrictID, &p.ArchivedAt, &p.Version, &p.Drivers}, p.scanTargets()...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ArchivePoint переносит точку в архив и убирает её из поиска; маршруты
+// точки сохраняются для отчётов.
+func ArchivePoint(ctx context.Context, id int) error {
+	if err := archiveRow(ctx, "collection_points", id); err != nil {
+		return err
 	}
 
+	pointIndex.Remove(id)
+	return nil
+}
+
+// RestorePoint возвращает точку из архива.
+func RestorePoint(ctx context.Context, id int) (*CollectionPoint, error) {
+	if err := restoreRow(ctx, "collection_points", id); err != nil {
+		return nil, err
+	}
+
+	point, err := GetPointByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	pointIndex.Insert(point.ID, point.Latitude, point.Longitude)
 	return point, nil
 }
 
-func DeletePoint(ctx context.Context, id int) error {
-	_, err := database.Pool.Exec(ctx, `DELETE FROM collection_points WHERE id = $1`, id)
-	return err
+// PurgePoint окончательно удаляет архивную точку; её маршруты удаляются
+// каскадно и попадают в журнал изменений той же транзакцией.
+func PurgePoint(ctx context.Context, id int) error {
+	return purgeRow(ctx, "collection_points", id)
 }
 
-func UpdatePoint(ctx context.Context, id int, name, address, city string, latitude, longitude float64) (*CollectionPoint, error) {
+// updatePoint меняет точку; незаданные (nil) ограничения остаются прежними,
+// пустые — снимаются.
+func updatePoint(ctx context.Context, tx pgx.Tx, id int, name, address, city string, latitude, longitude float64, containerCount *int, rs PointRestrictions) (*CollectionPoint, error) {
 	var point CollectionPoint
-	err := database.Pool.QueryRow(ctx, `
+	err := tx.QueryRow(ctx, `
 		UPDATE collection_points 
-		SET name = $1, address = $2, latitude = $3, longitude = $4, city = $5
-		WHERE id = $6
-		RETURNING id, name, address, latitude, longitude, city
-	`, name, address, latitude, longitude, city, id).Scan(&point.ID, &point.Name, &point.Address, &point.Latitude, &point.Longitude, &point.City)
-	
+		SET name = $1, address = $2, latitude = $3, longitude = $4, city = $5, container_count = COALESCE($6, container_count),
+			window_start = CASE WHEN $8::text IS NULL THEN window_start ELSE NULLIF($8, '')::time END,
+			window_end = CASE WHEN $9::text IS NULL THEN window_end ELSE NULLIF($9, '')::time END,
+			excluded_weekdays = COALESCE($10::smallint[], excluded_weekdays),
+			access_notes = CASE WHEN $11::text IS NULL THEN access_notes ELSE NULLIF($11, '') END,
+			gate_code = CASE WHEN $12::text IS NULL THEN gate_code ELSE NULLIF($12, '') END
+		WHERE id = $7 AND archived_at IS NULL
+		RETURNING id, name, address, latitude, longitude, city, container_count, version, `+pointRestrictionColumns("")+`
+	`, name, address, latitude, longitude, city, containerCount, id,
+		rs.WindowStart, rs.WindowEnd, rs.ExcludedWeekdays, rs.AccessNotes, rs.GateCode).Scan(append([]interface{}{&point.ID, &point.Name, &point.Address, &point.Latitude, &point.Longitude, &point.City, &point.ContainerCount, &point.Version}, point.scanTargets()...)...)
 	if err != nil {
 		return nil, err
 	}
-	
 	return &point, nil
 }
 
-func UpdatePointWithDrivers(ctx context.Context, id int, name, address, city string, latitude, longitude float64, driverIDs []int) (*CollectionPoint, error) {
-	// Обновляем точку
-	point, err := UpdatePoint(ctx, id, name, address, city, latitude, longitude)
+// UpdatePointWithDrivers обновляет точку и приводит её маршруты к списку
+// водителей в одной транзакции: у убранных водителей удаляются ожидающие
+// остановки (пройденные, пропущенные и проблемные остаются для отчётов),
+// водителям без предстоящей остановки точка добавляется в конец
+// маршрута. Если передана ifVersion, а версия точки другая, возвращается
+// ErrVersionMismatch.
+func UpdatePointWithDrivers(ctx context.Context, id int, name, address, city string, latitude, longitude float64, containerCount *int, rs PointRestrictions, driverIDs []int, ifVersion *int) (*CollectionPoint, error) {
+	var point *CollectionPoint
+	err := database.InTx(ctx, func(tx pgx.Tx) error {
+		if err := checkVersion(ctx, tx, "collection_points", id, ifVersion); err != nil {
+			return err
+		}
+
+		var err error
+		point, err = updatePoint(ctx, tx, id, name, address, city, latitude, longitude, containerCount, rs)
+		if err != nil {
+			return err
+		}
+
+		if err := assignPointDistrict(ctx, tx, point); err != nil {
+			return err
+		}
+
+		slog.DebugContext(ctx, "Изменена точка", "point_id", id, "driver_ids", driverIDs)
+		if driverIDs == nil {
+			// Пустой массив, а не NULL: иначе ANY($2) ничего не удалит
+			driverIDs = []int{}
+		}
+
+		// Водителям, убранным из списка, не объезжать точку: удаляются
+		// только их ожидающие остановки, пройденные остаются в истории
+		if _, err := tx.Exec(ctx, `
+			DELETE FROM routes
+			WHERE point_id = $1 AND status = 'pending' AND NOT (driver_id = ANY($2))
+		`, id, driverIDs); err != nil {
+			return err
+		}
+
+		// Остановки добавляются водителям, у которых нет предстоящей
+		// остановки точки, в том числе убранным раньше и добавленным снова
+		rows, err := tx.Query(ctx, `
+			SELECT d FROM unnest($2::int[]) d
+			WHERE NOT EXISTS (
+				SELECT 1 FROM routes
+				WHERE point_id = $1 AND driver_id = d AND status IN `+activeStopStatuses+`
+			)
+		`, id, driverIDs)
+		if err != nil {
+			return err
+		}
+		var added []int
+		for rows.Next() {
+			var driverID int
+			if err := rows.Scan(&driverID); err != nil {
+				rows.Close()
+				return err
+			}
+			added = append(added, driverID)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return appendPointRoutes(ctx, tx, id, added)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("Updated point %d, updating drivers: %v", id, driverIDs)
+	pointIndex.Insert(point.ID, point.Latitude, point.Longitude)
+	return point, nil
+}
+
+// PointFilter — фильтры списка точек.
+type PointFilter struct {
+	City       string
+	Name       string // поиск по подстроке в названии или адресе
+	DistrictID *int
+	DriverID   *int   // точки, входящие в маршрут водителя
+	Archived   string // ArchivedExclude, ArchivedInclude или ArchivedOnly
+}
 
-	// Удаляем старые маршруты
-	_, err = database.Pool.Exec(ctx, `DELETE FROM routes WHERE point_id = $1`, id)
+var PointListSpec = listquery.Spec{
+	IDColumn: "cp.id",
+	Sorts: map[string]listquery.SortField{
+		"id":      {Column: "cp.id", Type: "int"},
+		"name":    {Column: "cp.name", Type: "text"},
+		"city":    {Column: "COALESCE(cp.city, '')", Type: "text"},
+		"address": {Column: "COALESCE(cp.address, '')", Type: "text"},
+	},
+	DefaultSort: "id",
+	MaxLimit:    1000,
+}
+
+func ListPoints(ctx context.Context, f PointFilter, p listquery.Params) (*listquery.Page, error) {
+	b := &listquery.Builder{}
+	whereArchived(b, "cp.archived_at", f.Archived)
+	if f.City != "" {
+		b.Where("cp.city = ?", f.City)
+	}
+	if f.Name != "" {
+		b.Where("(cp.name ILIKE '%' || ? || '%' OR cp.address ILIKE '%' || ? || '%')", f.Name, f.Name)
+	}
+	if f.DistrictID != nil {
+		b.Where("cp.district_id = ?", *f.DistrictID)
+	}
+	if f.DriverID != nil {
+		b.Where("EXISTS (SELECT 1 FROM routes fr WHERE fr.point_id = cp.id AND fr.driver_id = ?)", *f.DriverID)
+	}
+
+	var total int
+	if err := database.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM collection_points cp`+b.WhereSQL(), b.Args()...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	p.ApplyCursor(b)
+	rows, err := database.Pool.Query(ctx, `
+		SELECT 
+			cp.id, cp.name, cp.address, cp.latitude, cp.longitude, cp.city, cp.container_count, cp.district_id, cp.archived_at, cp.version,
+			`+pointDriversColumn+`,
+			`+pointRestrictionColumns("cp.")+`
+		FROM collection_points cp`+b.WhereSQL()+p.OrderSQL(), b.Args()...)
 	if err != nil {
-		log.Printf("Error deleting old routes for point %d: %v", id, err)
 		return nil, err
 	}
+	defer rows.Close()
 
-	// Создаем новые маршруты
-	for _, driverID := range driverIDs {
-		var maxOrder int
-		err := database.Pool.QueryRow(ctx, `
-			SELECT COALESCE(MAX(order_number), 0) FROM routes WHERE driver_id = $1
-		`, driverID).Scan(&maxOrder)
-		if err != nil {
-			log.Printf("Error getting max order for driver %d: %v", driverID, err)
-			continue
+	points := []CollectionPoint{}
+	for rows.Next() {
+		var pt CollectionPoint
+		if err := rows.Scan(append([]interface{}{&pt.ID, &pt.Name, &pt.Address, &pt.Latitude, &pt.Longitude, &pt.City, &pt.ContainerCount, &pt.DistrictID, &pt.ArchivedAt, &pt.Version, &pt.Drivers}, pt.scanTargets()...)...); err != nil {
+			return nil, err
 		}
+		points = append(points, pt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		_, err = database.Pool.Exec(ctx, `
-			INSERT INTO routes (driver_id, point_id, order_number, scheduled_at, status)
-			VALUES ($1, $2, $3, CURRENT_DATE + TIME '08:00:00' + (INTERVAL '10 minutes' * ($3 - 1)), 'pending')
-		`, driverID, point.ID, maxOrder+1)
-		if err != nil {
-			log.Printf("Error creating route for driver %d, point %d: %v", driverID, point.ID, err)
-			continue
+	n, next := p.Trim(len(points), func(i int) (interface{}, int) {
+		switch p.Sort {
+		case "name":
+			return points[i].Name, points[i].ID
+		case "city":
+			return points[i].City, points[i].ID
+		case "address":
+			return points[i].Address, points[i].ID
 		}
-		log.Printf("Created route: driver=%d, point=%d, order=%d", driverID, point.ID, maxOrder+1)
-	}
+		return points[i].ID, points[i].ID
+	})
 
-	return point, nil
-}
\ No newline at end of file
+	return &listquery.Page{Items: points[:n], NextCursor: next, Total: total}, nil
+}
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/database/dbtest"
+	"garbage_trucks/backend/internal/geo"
+	"garbage_trucks/backend/internal/listquery"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+// Изменение точки не трогает пройденные остановки и остановки оставшихся
+// водителей: удаляются только ожидающие остановки убранных водителей.
+func TestUpdatePointKeepsStopHistory(t *testing.T) {
+	ctx := dbtest.Open(t)
+	a := dbtest.Exec(t, ctx, `INSERT INTO drivers (name) VALUES ('А') RETURNING id`)
+	b := dbtest.Exec(t, ctx, `INSERT INTO drivers (name) VALUES ('Б') RETURNING id`)
+	c := dbtest.Exec(t, ctx, `INSERT INTO drivers (name) VALUES ('В') RETURNING id`)
+
+	point, err := CreatePointWithDrivers(ctx, "Точка", "ул. Свободы, 1", "Рязань", 54.62, 39.74, 1, PointRestrictions{}, []int{a, b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stops := func() map[int]Route {
+		routes, err := GetRoutesByPointID(ctx, point.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byDriver := map[int]Route{}
+		for _, r := range routes {
+			byDriver[r.DriverID] = r
+		}
+		return byDriver
+	}
+	before := stops()
+	if _, err := UpdateRouteStatus(ctx, before[a].ID, RouteStatusCompleted, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// А убран, но его остановка пройдена; Б остаётся; В добавлен
+	if _, err := UpdatePointWithDrivers(ctx, point.ID, "Точка", "ул. Свободы, 1", "Рязань", 54.62, 39.74, nil, PointRestrictions{}, []int{b, c}, nil); err != nil {
+		t.Fatal(err)
+	}
+	after := stops()
+	if r, ok := after[a]; !ok || r.Status != RouteStatusCompleted || r.CompletedAt == nil {
+		t.Errorf("пройденная остановка А: %+v, %v", r, ok)
+	}
+	if after[b].ID != before[b].ID {
+		t.Errorf("остановка Б пересоздана: %d → %d", before[b].ID, after[b].ID)
+	}
+	if r, ok := after[c]; !ok || r.Status != RouteStatusPending {
+		t.Errorf("новому водителю В не добавлена остановка: %+v", after)
+	}
+	assigned := func(want ...string) {
+		t.Helper()
+		p, err := GetPointByID(ctx, point.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(p.Drivers, want) {
+			t.Errorf("водители точки %v, ждали %v", p.Drivers, want)
+		}
+	}
+	// Пройденная остановка А не делает его назначенным
+	assigned("Б", "В")
+
+	// Без водителей: ожидающие остановки удаляются, пройденная остаётся
+	if _, err := UpdatePointWithDrivers(ctx, point.ID, "Точка", "ул. Свободы, 1", "Рязань", 54.62, 39.74, nil, PointRestrictions{}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	var left []int
+	rows, err := database.Pool.Query(ctx, `SELECT driver_id FROM routes WHERE point_id = $1`, point.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+		var id int
+		rows.Scan(&id)
+		left = append(left, id)
+	}
+	if len(left) != 1 || left[0] != a {
+		t.Errorf("остались остановки водителей %v, ждали только %d", left, a)
+	}
+	assigned()
+
+	// А добавлен снова: пройденная остановка не мешает новой
+	if _, err := UpdatePointWithDrivers(ctx, point.ID, "Точка", "ул. Свободы, 1", "Рязань", 54.62, 39.74, nil, PointRestrictions{}, []int{a}, nil); err != nil {
+		t.Fatal(err)
+	}
+	routes, err := GetRoutesByPointID(ctx, point.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]int{}
+	for _, r := range routes {
+		if r.DriverID != a {
+			t.Errorf("остановка водителя %d, ждали только %d", r.DriverID, a)
+		}
+		statuses[r.Status]++
+	}
+	if statuses[RouteStatusCompleted] != 1 || statuses[RouteStatusPending] != 1 {
+		t.Errorf("остановки А по статусам %v, ждали одну пройденную и одну ожидающую", statuses)
+	}
+	assigned("А")
+}
+
+// Код ворот есть только в карточке точки и в маршрутном листе; списки,
+// маршруты и журнал изменений его не показывают.
+func TestGateCodeHidden(t *testing.T) {
+	ctx := dbtest.Open(t)
+	driver := dbtest.Exec(t, ctx, `INSERT INTO drivers (name) VALUES ('А') RETURNING id`)
+
+	code := "1234#"
+	point, err := CreatePointWithDrivers(ctx, "Точка", "ул. Свободы, 1", "Рязань", 54.62, 39.74, 1, PointRestrictions{GateCode: &code}, []int{driver})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if point.GateCode != nil {
+		t.Errorf("код ворот в ответе на создание: %q", *point.GateCode)
+	}
+
+	p, err := GetPointByID(ctx, point.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GateCode == nil || *p.GateCode != code {
+		t.Errorf("карточка точки: код ворот %v, ждали %q", p.GateCode, code)
+	}
+
+	params := func(spec listquery.Spec) listquery.Params {
+		p, err := listquery.Parse(url.Values{}, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	hidden := func(where string, points []CollectionPoint) {
+		t.Helper()
+		if len(points) != 1 || points[0].ID != point.ID || points[0].GateCode != nil {
+			t.Errorf("%s: %+v, ждали точку %d без кода ворот", where, points, point.ID)
+		}
+	}
+
+	page, err := ListPoints(ctx, PointFilter{}, params(PointListSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden("список точек", page.Items.([]CollectionPoint))
+
+	inBBox, err := FindPointsInBBox(ctx, geo.BBox{MinLon: 39.7, MinLat: 54.6, MaxLon: 39.8, MaxLat: 54.65}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden("точки в области", inBBox)
+
+	nearby, err := FindPointsNearby(ctx, 54.62, 39.74, 100, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden("точки рядом", nearby)
+
+	page, err = ListRoutes(ctx, RouteFilter{DriverID: &driver}, params(RouteListSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := page.Items.([]Route)
+	if len(routes) != 1 || routes[0].Point.GateCode != nil {
+		t.Fatalf("код ворот в списке маршрутов: %+v", routes)
+	}
+
+	sheet, err := GetRoutesByDriverIDOnDate(ctx, driver, routes[0].ScheduledAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet) != 1 || sheet[0].Point.GateCode == nil || *sheet[0].Point.GateCode != code {
+		t.Errorf("маршрутный лист без кода ворот: %+v", sheet)
+	}
+
+	var logged int
+	err = database.Pool.QueryRow(ctx, `
+		SELECT count(*) FROM audit_log WHERE before ? 'gate_code' OR after ? 'gate_code'
+	`).Scan(&logged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logged != 0 {
+		t.Errorf("код ворот в журнале изменений: %d записей", logged)
+	}
+}
@@ -0,0 +1,556 @@
+package models
+
+import (
+	"context"
+	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/geo"
+	"garbage_trucks/backend/internal/routing"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Оценка времени прибытия без дорожного графа (или когда точка далеко от
+// дорог): расстояние по прямой с поправкой на извилистость дорог при
+// средней городской скорости мусоровоза. Этого хватает, чтобы диспетчер
+// видел отставание.
+const (
+	etaSpeedKmh     = 25.0
+	etaDetourFactor = 1.3
+	// Сколько ещё водитель пробудет на начатой остановке
+	etaServiceTime = 5 * time.Minute
+	// GPS-отметка старше этого считается устаревшей: водитель мог уехать
+	positionMaxAge = 30 * time.Minute
+	// Остановка под угрозой, если ожидаемое прибытие ближе этого к концу
+	// окна обслуживания точки
+	windowRiskMargin = 15 * time.Minute
+)
+
+// Почему остановка может не состояться
+const (
+	StopRiskWindow      = "window"       // не успеть до конца окна обслуживания
+	StopRiskExcludedDay = "excluded_day" // в этот день недели вывоз на точке запрещён
+)
+
+// Источники последнего известного положения водителя
+const (
+	PositionSourceGPS  = "gps"  // отметка трекера
+	PositionSourceStop = "stop" // последняя посещённая остановка
+)
+
+// DriverPosition — GPS-отметка водителя.
+type DriverPosition struct {
+	DriverID   int       `json:"driver_id"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// RecordDriverPosition сохраняет GPS-отметку действующего водителя.
+// Если водителя нет или он в архиве, возвращается pgx.ErrNoRows.
+func RecordDriverPosition(ctx context.Context, driverID int, lat, lon float64, recordedAt *time.Time) (*DriverPosition, error) {
+	p := DriverPosition{DriverID: driverID, Latitude: lat, Longitude: lon}
+	err := database.InTx(ctx, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			INSERT INTO driver_positions (driver_id, latitude, longitude, recorded_at)
+			SELECT id, $2, $3, COALESCE($4, CURRENT_TIMESTAMP)
+			FROM drivers WHERE id = $1 AND archived_at IS NULL
+			RETURNING recorded_at
+		`, driverID, lat, lon, recordedAt).Scan(&p.RecordedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DispatchStop — остановка в обзоре диспетчера.
+type DispatchStop struct {
+	RouteID     int        `json:"route_id"`
+	PointID     int        `json:"point_id"`
+	PointName   string     `json:"point_name"`
+	Address     string     `json:"address"`
+	Latitude    float64    `json:"latitude"`
+	Longitude   float64    `json:"longitude"`
+	OrderNumber int        `json:"order_number"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	VisitedAt   *time.Time `json:"visited_at,omitempty"`
+	WindowStart *string    `json:"window_start,omitempty"`
+	WindowEnd   *string    `json:"window_end,omitempty"`
+	ETA         *time.Time `json:"eta,omitempty"`
+	Risk        string     `json:"risk,omitempty"` // StopRiskWindow или StopRiskExcludedDay
+}
+
+// window — окно обслуживания точки в день day.
+func (s *DispatchStop) window(day time.Time) (from, to time.Time, ok bool) {
+	return PointRestrictions{WindowStart: s.WindowStart, WindowEnd: s.WindowEnd}.Window(day)
+}
+
+// waitForWindow сдвигает прибытие к началу окна обслуживания: раньше
+// водитель точку не обслужит.
+func (s *DispatchStop) waitForWindow(eta, day time.Time) time.Time {
+	if from, _, ok := s.window(day); ok && eta.Before(from) {
+		return from
+	}
+	return eta
+}
+
+// LastPosition — последнее известное положение водителя за день.
+type LastPosition struct {
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Source     string    `json:"source"`
+}
+
+// DriverOverview — состояние маршрута водителя за день.
+type DriverOverview struct {
+	DriverID     int           `json:"driver_id"`
+	DriverName   string        `json:"driver_name"`
+	Total        int           `json:"total"`
+	Completed    int           `json:"completed"`
+	Problem      int           `json:"problem"`
+	Skipped      int           `json:"skipped"`
+	Pending      int           `json:"pending"`
+	InProgress   int           `json:"in_progress"`
+	CurrentStop  *DispatchStop `json:"current_stop"`
+	NextStop     *DispatchStop `json:"next_stop"`
+	LastPosition *LastPosition `json:"last_position"`
+	// Отставание от плана в минутах (отрицательное — опережение): по
+	// ожидаемому прибытию на следующую остановку, а если остановок не
+	// осталось — по последней посещённой
+	DelayMinutes *int `json:"delay_minutes"`
+	Finished     bool `json:"finished"`
+	// Ожидающие остановки, которые могут не состояться: прибытие по
+	// прогнозу впритык к концу окна точки или позже, либо вывоз в этот
+	// день недели запрещён
+	AtRisk []DispatchStop `json:"at_risk"`
+}
+
+// DispatchTotals — сводка по всем водителям.
+type DispatchTotals struct {
+	Drivers    int `json:"drivers"`
+	Active     int `json:"active"`  // начали маршрут и ещё не закончили
+	Late       int `json:"late"`    // отстают от плана больше чем на DispatchLateMinutes
+	AtRisk     int `json:"at_risk"` // остановок под угрозой
+	Total      int `json:"total"`
+	Completed  int `json:"completed"`
+	Problem    int `json:"problem"`
+	Skipped    int `json:"skipped"`
+	Pending    int `json:"pending"`
+	InProgress int `json:"in_progress"`
+}
+
+// DispatchOverview — обзор диспетчера за день.
+type DispatchOverview struct {
+	Date        string           `json:"date"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Totals      DispatchTotals   `json:"totals"`
+	Drivers     []DriverOverview `json:"drivers"`
+}
+
+// Водитель считается отстающим, если опаздывает больше чем на столько минут
+const DispatchLateMinutes = 15
+
+// dispatchStopColumns — поля остановки из LATERAL-подзапроса с алиасом a.
+func dispatchStopColumns(a string) string {
+	return a + `.id, ` + a + `.point_id, ` + a + `.name, COALESCE(` + a + `.address, ''), ` +
+		a + `.latitude, ` + a + `.longitude, ` + a + `.order_number, ` + a + `.scheduled_at, ` + a + `.visited_at, ` +
+		`to_char(` + a + `.window_start, 'HH24:MI'), to_char(` + a + `.window_end, 'HH24:MI')`
+}
+
+// dispatchStopLateral — первая остановка водителя за день по условию и порядку.
+func dispatchStopLateral(where, order string) string {
+	return `LEFT JOIN LATERAL (
+			SELECT r.id, r.point_id, cp.name, cp.address, cp.latitude, cp.longitude,
+				r.order_number, r.scheduled_at, COALESCE(r.visited_at, r.completed_at) AS visited_at,
+				cp.window_start, cp.window_end
+			FROM day r
+			JOIN collection_points cp ON cp.id = r.point_id
+			WHERE r.driver_id = d.id AND ` + where + `
+			ORDER BY ` + order + `
+			LIMIT 1
+		)`
+}
+
+// GetDispatchOverview собирает обзор по всем действующим водителям за день
+// [day, day+1) одним запросом: счётчики по статусам, текущая (начатая)
+// и следующая остановки, последняя посещённая остановка и последняя
+// GPS-отметка. Точки, архивированные до начала дня, не учитываются, как
+// и в маршрутном листе. Время прибытия и отставание считаются уже в Go,
+// остановки под угрозой — по второму запросу с ожидающими остановками.
+func GetDispatchOverview(ctx context.Context, day, now time.Time) (*DispatchOverview, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+
+	rows, err := database.Pool.Query(ctx, `
+		WITH day AS (
+			SELECT r.id, r.driver_id, r.point_id, r.order_number, r.scheduled_at, r.status, r.visited_at, r.completed_at
+			FROM routes r
+			JOIN collection_points cp ON cp.id = r.point_id
+			WHERE r.scheduled_at >= $1 AND r.scheduled_at < $2
+				AND (cp.archived_at IS NULL OR cp.archived_at >= $1)
+		),
+		stats AS (
+			SELECT driver_id,
+				COUNT(*) AS total,
+				COUNT(*) FILTER (WHERE status = 'completed') AS completed,
+				COUNT(*) FILTER (WHERE status = 'problem') AS problem,
+				COUNT(*) FILTER (WHERE status = 'skipped') AS skipped,
+				COUNT(*) FILTER (WHERE status = 'pending') AS pending,
+				COUNT(*) FILTER (WHERE status = 'in_progress') AS in_progress
+			FROM day
+			GROUP BY driver_id
+		)
+		SELECT d.id, d.name,
+			COALESCE(st.total, 0), COALESCE(st.completed, 0), COALESCE(st.problem, 0),
+			COALESCE(st.skipped, 0), COALESCE(st.pending, 0), COALESCE(st.in_progress, 0),
+			`+dispatchStopColumns("s")+`,
+			`+dispatchStopColumns("n")+`,
+			`+dispatchStopColumns("l")+`,
+			pos.latitude, pos.longitude, pos.recorded_at
+		FROM drivers d
+		LEFT JOIN stats st ON st.driver_id = d.id
+		`+dispatchStopLateral("r.status = 'in_progress'", "r.order_number")+` s ON true
+		`+dispatchStopLateral("r.status = 'pending'", "r.order_number")+` n ON true
+		`+dispatchStopLateral("COALESCE(r.visited_at, r.completed_at) IS NOT NULL", "COALESCE(r.visited_at, r.completed_at) DESC")+` l ON true
+		LEFT JOIN LATERAL (
+			SELECT p.latitude, p.longitude, p.recorded_at
+			FROM driver_positions p
+			WHERE p.driver_id = d.id AND p.recorded_at >= $1 AND p.recorded_at < $2
+			ORDER BY p.recorded_at DESC
+			LIMIT 1
+		) pos ON true
+		WHERE d.archived_at IS NULL
+		ORDER BY d.name, d.id
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overview := &DispatchOverview{
+		Date:        from.Format("2006-01-02"),
+		GeneratedAt: now,
+		Drivers:     []DriverOverview{},
+	}
+	for rows.Next() {
+		var o DriverOverview
+		var cur, next, last nullableStop
+		var posLat, posLon *float64
+		var posAt *time.Time
+		err := rows.Scan(
+			&o.DriverID, &o.DriverName,
+			&o.Total, &o.Completed, &o.Problem, &o.Skipped, &o.Pending, &o.InProgress,
+			&cur.RouteID, &cur.PointID, &cur.PointName, &cur.Address, &cur.Latitude, &cur.Longitude, &cur.OrderNumber, &cur.ScheduledAt, &cur.VisitedAt, &cur.WindowStart, &cur.WindowEnd,
+			&next.RouteID, &next.PointID, &next.PointName, &next.Address, &next.Latitude, &next.Longitude, &next.OrderNumber, &next.ScheduledAt, &next.VisitedAt, &next.WindowStart, &next.WindowEnd,
+			&last.RouteID, &last.PointID, &last.PointName, &last.Address, &last.Latitude, &last.Longitude, &last.OrderNumber, &last.ScheduledAt, &last.VisitedAt, &last.WindowStart, &last.WindowEnd,
+			&posLat, &posLon, &posAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		o.CurrentStop = cur.stop()
+		o.NextStop = next.stop()
+		lastVisited := last.stop()
+
+		switch {
+		case posAt != nil:
+			o.LastPosition = &LastPosition{Latitude: *posLat, Longitude: *posLon, RecordedAt: *posAt, Source: PositionSourceGPS}
+		case lastVisited != nil && lastVisited.VisitedAt != nil:
+			o.LastPosition = &LastPosition{Latitude: lastVisited.Latitude, Longitude: lastVisited.Longitude, RecordedAt: *lastVisited.VisitedAt, Source: PositionSourceStop}
+		}
+		o.Finished = o.Total > 0 && o.Pending == 0 && o.InProgress == 0
+
+		estimateArrival(&o, from, to, now)
+		switch {
+		case o.NextStop != nil && o.NextStop.ETA != nil:
+			o.DelayMinutes = delayMinutes(*o.NextStop.ETA, o.NextStop.ScheduledAt)
+		case o.NextStop == nil && lastVisited != nil && lastVisited.VisitedAt != nil:
+			o.DelayMinutes = delayMinutes(*lastVisited.VisitedAt, lastVisited.ScheduledAt)
+		}
+
+		o.AtRisk = []DispatchStop{}
+		overview.Drivers = append(overview.Drivers, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	pending, err := dispatchPendingStops(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	for i := range overview.Drivers {
+		o := &overview.Drivers[i]
+		flagRisks(o, pending[o.DriverID], from, to, now)
+		overview.Totals.add(o)
+	}
+	return overview, nil
+}
+
+// stopRisk — почему остановка на точке в день day с прибытием at может
+// не состояться; пустая строка — угрозы нет.
+func stopRisk(rs PointRestrictions, day, at time.Time) string {
+	if !rs.ServedOn(day) {
+		return StopRiskExcludedDay
+	}
+	if _, end, ok := rs.Window(day); ok && at.Add(windowRiskMargin).After(end) {
+		return StopRiskWindow
+	}
+	return ""
+}
+
+// pendingStop — ожидающая остановка для прогноза.
+type pendingStop struct {
+	DispatchStop
+	restrictions PointRestrictions
+}
+
+// dispatchPendingStops возвращает ожидающие остановки действующих
+// водителей за день в порядке объезда.
+func dispatchPendingStops(ctx context.Context, from, to time.Time) (map[int][]pendingStop, error) {
+	rows, err := database.Pool.Query(ctx, `
+		SELECT r.driver_id, r.id, r.point_id, cp.name, COALESCE(cp.address, ''), cp.latitude, cp.longitude,
+			r.order_number, r.scheduled_at, `+pointRestrictionColumns("cp.")+`
+		FROM routes r
+		JOIN collection_points cp ON cp.id = r.point_id
+		JOIN drivers d ON d.id = r.driver_id AND d.archived_at IS NULL
+		WHERE r.scheduled_at >= $1 AND r.scheduled_at < $2 AND r.status = 'pending'
+			AND (cp.archived_at IS NULL OR cp.archived_at >= $1)
+		ORDER BY r.driver_id, r.order_number, r.id
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stops := make(map[int][]pendingStop)
+	for rows.Next() {
+		var driverID int
+		var s pendingStop
+		err := rows.Scan(append([]interface{}{
+			&driverID, &s.RouteID, &s.PointID, &s.PointName, &s.Address, &s.Latitude, &s.Longitude,
+			&s.OrderNumber, &s.ScheduledAt,
+		}, s.restrictions.scanTargets()...)...)
+		if err != nil {
+			return nil, err
+		}
+		s.WindowStart, s.WindowEnd = s.restrictions.WindowStart, s.restrictions.WindowEnd
+		stops[driverID] = append(stops[driverID], s)
+	}
+	return stops, rows.Err()
+}
+
+// flagRisks прогнозирует прибытие на ожидающие остановки водителя и
+// отмечает те, что могут не состояться. Первая из них — следующая
+// остановка с уже посчитанным прибытием, дальше прибытие набегает по
+// пути с обслуживанием и ожиданием окон. Для будущих дней прогноз —
+// плановое время, для прошедших остановки не отмечаются.
+func flagRisks(o *DriverOverview, stops []pendingStop, from, to, now time.Time) {
+	if !now.Before(to) {
+		return
+	}
+	var legs []time.Duration
+	if !now.Before(from) {
+		legs = pendingLegs(o.DriverID, from, stops)
+	}
+	var prev *pendingStop
+	for i := range stops {
+		s := &stops[i]
+		var eta time.Time
+		switch {
+		case now.Before(from):
+			eta = s.ScheduledAt
+		case prev == nil && o.NextStop != nil && o.NextStop.RouteID == s.RouteID && o.NextStop.ETA != nil:
+			eta = *o.NextStop.ETA
+		case prev != nil:
+			eta = prev.ETA.Add(etaServiceTime + legs[i])
+		default:
+			eta = s.ScheduledAt
+			if eta.Before(now) {
+				eta = now
+			}
+		}
+		eta = s.waitForWindow(eta, from).Truncate(time.Minute)
+		s.ETA = &eta
+		prev = s
+
+		s.Risk = stopRisk(s.restrictions, from, eta)
+		if s.Risk == "" {
+			continue
+		}
+		o.AtRisk = append(o.AtRisk, s.DispatchStop)
+		if o.NextStop != nil && o.NextStop.RouteID == s.RouteID {
+			o.NextStop.Risk = s.Risk
+		}
+	}
+}
+
+// estimateArrival считает ожидаемое время прибытия на следующую остановку.
+// Для прошедших дней прогноз не нужен, для будущих — это плановое время.
+// Сегодня путь считается от последнего известного положения: свежей
+// GPS-отметки, начатой остановки (плюс время на её обслуживание) или
+// последней посещённой; если водитель ещё не выехал, прибытие не раньше
+// плана. До начала окна обслуживания точки водитель ждёт.
+func estimateArrival(o *DriverOverview, from, to, now time.Time) {
+	if o.NextStop == nil || !now.Before(to) {
+		return
+	}
+	next := o.NextStop
+	if now.Before(from) {
+		eta := next.ScheduledAt
+		next.ETA = &eta
+		return
+	}
+
+	start := now
+	var lat, lon float64
+	switch {
+	case o.LastPosition != nil && o.LastPosition.Source == PositionSourceGPS && now.Sub(o.LastPosition.RecordedAt) <= positionMaxAge:
+		lat, lon = o.LastPosition.Latitude, o.LastPosition.Longitude
+	case o.CurrentStop != nil:
+		lat, lon = o.CurrentStop.Latitude, o.CurrentStop.Longitude
+		start = now.Add(etaServiceTime)
+	case o.LastPosition != nil:
+		lat, lon = o.LastPosition.Latitude, o.LastPosition.Longitude
+	default:
+		// Водитель не начинал маршрут: считаем, что выедет по плану
+		eta := next.ScheduledAt
+		if eta.Before(now) {
+			eta = now
+		}
+		eta = next.waitForWindow(eta, from)
+		next.ETA = &eta
+		return
+	}
+
+	_, d := travel(lat, lon, next.Latitude, next.Longitude)
+	eta := next.waitForWindow(start.Add(d), from).Truncate(time.Minute)
+	next.ETA = &eta
+}
+
+type cachedPendingLegs struct {
+	stops []geometryStop
+	legs  []time.Duration
+}
+
+// Кэш участков между ожидающими остановками водителей: обзор диспетчера
+// опрашивается часто, а остановки меняются редко. Как и линия маршрута,
+// участки считаются заново, только когда изменились ожидающие остановки,
+// их порядок или координаты точек.
+var pendingLegsCache = struct {
+	sync.Mutex
+	entries map[geometryKey]*cachedPendingLegs
+}{entries: map[geometryKey]*cachedPendingLegs{}}
+
+// pendingLegs возвращает время в пути до каждой ожидающей остановки
+// водителя от предыдущей; legs[0] равен нулю. Каждый участок считается
+// одним поиском пути.
+func pendingLegs(driverID int, day time.Time, stops []pendingStop) []time.Duration {
+	key := geometryKey{driverID: driverID, day: day.Format("2006-01-02")}
+	seq := make([]geometryStop, len(stops))
+	for i, s := range stops {
+		seq[i] = geometryStop{routeID: s.RouteID, pointID: s.PointID, lat: s.Latitude, lon: s.Longitude}
+	}
+
+	pendingLegsCache.Lock()
+	cached := pendingLegsCache.entries[key]
+	pendingLegsCache.Unlock()
+	if cached != nil && slices.Equal(cached.stops, seq) {
+		return cached.legs
+	}
+
+	cached = &cachedPendingLegs{stops: seq, legs: make([]time.Duration, len(seq))}
+	for i := 1; i < len(seq); i++ {
+		_, cached.legs[i] = travel(seq[i-1].lat, seq[i-1].lon, seq[i].lat, seq[i].lon)
+	}
+	pendingLegsCache.Lock()
+	if len(pendingLegsCache.entries) >= geometryCacheSize {
+		pendingLegsCache.entries = map[geometryKey]*cachedPendingLegs{}
+	}
+	pendingLegsCache.entries[key] = cached
+	pendingLegsCache.Unlock()
+	return cached.legs
+}
+
+// travel — длина и время пути между точками по дорожному графу, если он
+// загружен, иначе по прямой с поправкой на извилистость. Длина и время
+// берутся из одного поиска пути.
+func travel(lat1, lon1, lat2, lon2 float64) (float64, time.Duration) {
+	if m, d, err := routing.Travel(routing.Point{Latitude: lat1, Longitude: lon1}, routing.Point{Latitude: lat2, Longitude: lon2}); err == nil {
+		return m, d
+	}
+	m := straightMeters(lat1, lon1, lat2, lon2)
+	return m, straightTime(m)
+}
+
+// straightMeters — оценка пути по прямой с поправкой на извилистость.
+func straightMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	return geo.Distance(lat1, lon1, lat2, lon2) * etaDetourFactor
+}
+
+// straightTime — время на путь длиной meters при средней скорости.
+func straightTime(meters float64) time.Duration {
+	return time.Duration(meters / 1000 / etaSpeedKmh * float64(time.Hour))
+}
+
+func delayMinutes(actual, planned time.Time) *int {
+	m := int(math.Round(actual.Sub(planned).Minutes()))
+	return &m
+}
+
+func (t *DispatchTotals) add(o *DriverOverview) {
+	t.Drivers++
+	if o.Total > o.Pending && !o.Finished {
+		t.Active++
+	}
+	if o.DelayMinutes != nil && *o.DelayMinutes > DispatchLateMinutes && !o.Finished {
+		t.Late++
+	}
+	t.AtRisk += len(o.AtRisk)
+	t.Total += o.Total
+	t.Completed += o.Completed
+	t.Problem += o.Problem
+	t.Skipped += o.Skipped
+	t.Pending += o.Pending
+	t.InProgress += o.InProgress
+}
+
+// nullableStop — остановка из LEFT JOIN: все поля могут быть NULL.
+type nullableStop struct {
+	RouteID     *int
+	PointID     *int
+	PointName   *string
+	Address     *string
+	Latitude    *float64
+	Longitude   *float64
+	OrderNumber *int
+	ScheduledAt *time.Time
+	VisitedAt   *time.Time
+	WindowStart *string
+	WindowEnd   *string
+}
+
+func (s nullableStop) stop() *DispatchStop {
+	if s.RouteID == nil {
+		return nil
+	}
+	return &DispatchStop{
+		RouteID:     *s.RouteID,
+		PointID:     *s.PointID,
+		PointName:   *s.PointName,
+		Address:     *s.Address,
+		Latitude:    *s.Latitude,
+		Longitude:   *s.Longitude,
+		OrderNumber: *s.OrderNumber,
+		ScheduledAt: *s.ScheduledAt,
+		VisitedAt:   s.VisitedAt,
+		WindowStart: s.WindowStart,
+		WindowEnd:   s.WindowEnd,
+	}
+}
@@ -0,0 +1,340 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/geo"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Виды полигонов
+const (
+	DistrictKindDistrict    = "district"
+	DistrictKindServiceArea = "service_area"
+)
+
+// Максимальная длина названия района — размер колонки districts.name
+const DistrictNameMaxLen = 100
+
+type District struct {
+	ID         int             `json:"id"`
+	Name       string          `json:"name"`
+	Kind       string          `json:"kind"`
+	Bounds     geo.BBox        `json:"bounds"`
+	PointCount int             `json:"point_count"`
+	Geometry   json.RawMessage `json:"geometry,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// districtShape — полигон района, загруженный для проверки попадания точек.
+type districtShape struct {
+	id     int
+	bounds geo.BBox
+	shape  geo.MultiPolygon
+}
+
+func GetAllDistricts(ctx context.Context, withGeometry bool) ([]District, error) {
+	rows, err := database.Pool.Query(ctx, `
+		SELECT
+			d.id, d.name, d.kind, d.min_lon, d.min_lat, d.max_lon, d.max_lat, d.created_at,
+			CASE WHEN $1 THEN d.geometry END,
+			(SELECT COUNT(*) FROM collection_points cp WHERE cp.district_id = d.id AND cp.archived_at IS NULL)
+		FROM districts d
+		ORDER BY d.kind, d.name
+	`, withGeometry)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var districts []District
+	for rows.Next() {
+		var d District
+		var geometry []byte
+		if err := rows.Scan(
+			&d.ID, &d.Name, &d.Kind,
+			&d.Bounds.MinLon, &d.Bounds.MinLat, &d.Bounds.MaxLon, &d.Bounds.MaxLat, &d.CreatedAt,
+			&geometry, &d.PointCount,
+		); err != nil {
+			return nil, err
+		}
+		if geometry != nil {
+			d.Geometry = geometry
+		}
+		districts = append(districts, d)
+	}
+
+	return districts, rows.Err()
+}
+
+// DistrictImport — полигон из загружаемого файла.
+type DistrictImport struct {
+	Name  string
+	Kind  string
+	Shape geo.MultiPolygon
+}
+
+// ImportDistricts сохраняет полигоны и пересчитывает районы всех точек
+// в одной транзакции: при ошибке не остаётся ни части файла, ни точек,
+// привязанных к неполному набору районов. Район с тем же видом и именем
+// перезаписывается. Возвращает сохранённые районы и число точек, попавших
+// в какой-либо район.
+func ImportDistricts(ctx context.Context, districts []DistrictImport) ([]District, int, error) {
+	var imported []District
+	var assigned int
+	err := database.InTx(ctx, func(tx pgx.Tx) error {
+		imported = imported[:0]
+		for _, in := range districts {
+			d, err := upsertDistrict(ctx, tx, in)
+			if err != nil {
+				return err
+			}
+			imported = append(imported, *d)
+		}
+
+		var err error
+		assigned, err = reassignPointDistricts(ctx, tx)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return imported, assigned, nil
+}
+
+func upsertDistrict(ctx context.Context, q database.Querier, in DistrictImport) (*District, error) {
+	geometry, err := in.Shape.GeoJSON()
+	if err != nil {
+		return nil, err
+	}
+	b := in.Shape.Bounds()
+
+	var d District
+	err = q.QueryRow(ctx, `
+		INSERT INTO districts (name, kind, geometry, min_lon, min_lat, max_lon, max_lat)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (kind, name) DO UPDATE
+		SET geometry = EXCLUDED.geometry,
+			min_lon = EXCLUDED.min_lon, min_lat = EXCLUDED.min_lat,
+			max_lon = EXCLUDED.max_lon, max_lat = EXCLUDED.max_lat
+		RETURNING id, name, kind, min_lon, min_lat, max_lon, max_lat, created_at
+	`, in.Name, in.Kind, geometry, b.MinLon, b.MinLat, b.MaxLon, b.MaxLat).Scan(
+		&d.ID, &d.Name, &d.Kind,
+		&d.Bounds.MinLon, &d.Bounds.MinLat, &d.Bounds.MaxLon, &d.Bounds.MaxLat, &d.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// DeleteDistrict удаляет район и в той же транзакции пересчитывает районы
+// точек: точки удалённого района могли попасть в соседний полигон.
+func DeleteDistrict(ctx context.Context, id int) error {
+	return database.InTx(ctx, func(tx pgx.Tx) error {
+		result, err := tx.Exec(ctx, `DELETE FROM districts WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected() == 0 {
+			return pgx.ErrNoRows
+		}
+
+		_, err = reassignPointDistricts(ctx, tx)
+		return err
+	})
+}
+
+// loadDistrictShapes загружает полигоны заданного вида. Если указана точка,
+// загружаются только полигоны, чей прямоугольник её содержит.
+func loadDistrictShapes(ctx context.Context, q database.Querier, kind string, lat, lon *float64) ([]districtShape, error) {
+	query := `
+		SELECT id, geometry, min_lon, min_lat, max_lon, max_lat
+		FROM districts
+		WHERE kind = $1
+	`
+	args := []interface{}{kind}
+	if lat != nil && lon != nil {
+		query += ` AND $2 BETWEEN min_lat AND max_lat AND $3 BETWEEN min_lon AND max_lon`
+		args = append(args, *lat, *lon)
+	}
+	query += ` ORDER BY id`
+
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shapes []districtShape
+	for rows.Next() {
+		var s districtShape
+		var g geo.Geometry
+		if err := rows.Scan(&s.id, &g, &s.bounds.MinLon, &s.bounds.MinLat, &s.bounds.MaxLon, &s.bounds.MaxLat); err != nil {
+			return nil, err
+		}
+		s.shape, err = geo.ParseMultiPolygon(&g)
+		if err != nil {
+			return nil, err
+		}
+		shapes = append(shapes, s)
+	}
+
+	return shapes, rows.Err()
+}
+
+func findShape(shapes []districtShape, lat, lon float64) *int {
+	for _, s := range shapes {
+		if s.bounds.Contains(lat, lon) && s.shape.Contains(lat, lon) {
+			id := s.id
+			return &id
+		}
+	}
+	return nil
+}
+
+// findDistrictID возвращает район, в который попадает точка, или nil.
+// Полигоны читаются через q, чтобы в транзакции были видны районы,
+// сохранённые в ней же.
+func findDistrictID(ctx context.Context, q database.Querier, lat, lon float64) (*int, error) {
+	shapes, err := loadDistrictShapes(ctx, q, DistrictKindDistrict, &lat, &lon)
+	if err != nil {
+		return nil, err
+	}
+	return findShape(shapes, lat, lon), nil
+}
+
+// InServiceAreaPolygon проверяет попадание точки в один из полигонов зоны
+// обслуживания. Если зона не загружена, подходит любая точка.
+func InServiceAreaPolygon(ctx context.Context, lat, lon float64) (bool, error) {
+	var configured bool
+	err := database.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM districts WHERE kind = $1)
+	`, DistrictKindServiceArea).Scan(&configured)
+	if err != nil {
+		return false, err
+	}
+	if !configured {
+		return true, nil
+	}
+
+	shapes, err := loadDistrictShapes(ctx, database.Pool, DistrictKindServiceArea, &lat, &lon)
+	if err != nil {
+		return false, err
+	}
+	return findShape(shapes, lat, lon) != nil, nil
+}
+
+// assignPointDistrict определяет район точки и сохраняет его. Смена района
+// меняет и версию точки, поэтому она перечитывается.
+func assignPointDistrict(ctx context.Context, q database.Querier, point *CollectionPoint) error {
+	districtID, err := findDistrictID(ctx, q, point.Latitude, point.Longitude)
+	if err != nil {
+		return err
+	}
+
+	err = q.QueryRow(ctx, `
+		UPDATE collection_points SET district_id = $1 WHERE id = $2
+		RETURNING version
+	`, districtID, point.ID).Scan(&point.Version)
+	if err != nil {
+		return err
+	}
+
+	point.DistrictID = districtID
+	return nil
+}
+
+// reassignPointDistricts пересчитывает районы всех точек, например после
+// импорта или удаления полигонов. Возвращает число точек, попавших
+// в какой-либо район.
+func reassignPointDistricts(ctx context.Context, q database.Querier) (int, error) {
+	shapes, err := loadDistrictShapes(ctx, q, DistrictKindDistrict, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := q.Query(ctx, `SELECT id, latitude, longitude FROM collection_points`)
+	if err != nil {
+		return 0, err
+	}
+
+	type pointLocation struct {
+		id       int
+		lat, lon float64
+	}
+	var points []pointLocation
+	for rows.Next() {
+		var p pointLocation
+		if err := rows.Scan(&p.id, &p.lat, &p.lon); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		points = append(points, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	batch := &pgx.Batch{}
+	assigned := 0
+	for _, p := range points {
+		districtID := findShape(shapes, p.lat, p.lon)
+		if districtID != nil {
+			assigned++
+		}
+		batch.Queue(`UPDATE collection_points SET district_id = $1 WHERE id = $2 AND district_id IS DISTINCT FROM $1`, districtID, p.id)
+	}
+
+	if batch.Len() > 0 {
+		if err := q.SendBatch(ctx, batch).Close(); err != nil {
+			return 0, err
+		}
+	}
+
+	return assigned, nil
+}
+
+// GetPointsByDistrict возвращает действующие точки района с водителями,
+// как ListPoints. Если района нет, возвращается pgx.ErrNoRows.
+func GetPointsByDistrict(ctx context.Context, districtID int) ([]CollectionPoint, error) {
+	var exists bool
+	err := database.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM districts WHERE id = $1)`, districtID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, pgx.ErrNoRows
+	}
+
+	rows, err := database.Pool.Query(ctx, `
+		SELECT 
+			cp.id, cp.name, cp.address, cp.latitude, cp.longitude, cp.city, cp.container_count, cp.district_id, cp.version,
+			`+pointDriversColumn+`,
+			`+pointRestrictionColumns("cp.")+`
+		FROM collection_points cp
+		WHERE cp.district_id = $1 AND cp.archived_at IS NULL
+		ORDER BY cp.id
+	`, districtID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []CollectionPoint
+	for rows.Next() {
+		var p CollectionPoint
+		if err := rows.Scan(append([]interface{}{&p.ID, &p.Name, &p.Address, &p.Latitude, &p.Longitude, &p.City, &p.ContainerCount, &p.DistrictID, &p.Version, &p.Drivers}, p.scanTargets()...)...); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+
+	return points, rows.Err()
+}
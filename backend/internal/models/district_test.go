@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"garbage_trucks/backend/internal/database/dbtest"
+	"garbage_trucks/backend/internal/geo"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Импорт и удаление района сразу пересчитывают районы точек; точки
+// несуществующего района — ErrNoRows.
+func TestDistrictImportAndDelete(t *testing.T) {
+	ctx := dbtest.Open(t)
+	before, err := CreatePointWithDrivers(ctx, "До импорта", "ул. Свободы, 1", "Рязань", 54.62, 39.74, 1, PointRestrictions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	square := geo.MultiPolygon{{{{39.70, 54.60}, {39.80, 54.60}, {39.80, 54.65}, {39.70, 54.65}, {39.70, 54.60}}}}
+	imported, assigned, err := ImportDistricts(ctx, []DistrictImport{{Name: "Центр", Kind: DistrictKindDistrict, Shape: square}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != 1 || assigned != 1 {
+		t.Fatalf("импортировано %d, точек в районах %d; ждали 1 и 1", len(imported), assigned)
+	}
+	district := imported[0].ID
+
+	after, err := CreatePointWithDrivers(ctx, "После импорта", "ул. Свободы, 2", "Рязань", 54.63, 39.75, 1, PointRestrictions{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.DistrictID == nil || *after.DistrictID != district {
+		t.Errorf("район новой точки %v, ждали %d", after.DistrictID, district)
+	}
+
+	points, err := GetPointsByDistrict(ctx, district)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 {
+		t.Errorf("точек района %d, ждали 2", len(points))
+	}
+	if _, err := GetPointsByDistrict(ctx, district+1000); !errors.Is(err, pgx.ErrNoRows) {
+		t.Errorf("точки несуществующего района: %v, ждали ErrNoRows", err)
+	}
+
+	if err := DeleteDistrict(ctx, district); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int{before.ID, after.ID} {
+		p, err := GetPointByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.DistrictID != nil {
+			t.Errorf("точка %d осталась в удалённом районе %d", id, *p.DistrictID)
+		}
+	}
+}
@@ -3,45 +3,32 @@ package models
 import (
 	"context"
 	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/listquery"
 	"time"
-)
 
-type Driver struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
-}
-
-func GetAllDrivers(ctx context.Context) ([]Driver, error) {
-	rows, err := database.Pool.Query(ctx, `
-		SELECT id, name, created_at 
-		FROM drivers 
-		ORDER BY id
-	`)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	"github.com/jackc/pgx/v5"
+)
 
-	var drivers []Driver
-	for rows.Next() {
-		var d Driver
-		if err := rows.Scan(&d.ID, &d.Name, &d.CreatedAt); err != nil {
-			return nil, err
-		}
-		drivers = append(drivers, d)
-	}
+// Максимальная длина имени водителя — размер колонки drivers.name
+const DriverNameMaxLen = 100
 
-	return drivers, rows.Err()
+type Driver struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	Version    int        `json:"version"`
 }
 
+// GetDriverByID возвращает водителя, в том числе архивного: он нужен для
+// маршрутных листов и отчётов за прошлые дни.
 func GetDriverByID(ctx context.Context, id int) (*Driver, error) {
 	var driver Driver
 	err := database.Pool.QueryRow(ctx, `
-		SELECT id, name, created_at 
+		SELECT id, name, created_at, archived_at, version 
 		FROM drivers 
 		WHERE id = $1
-	`, id).Scan(&driver.ID, &driver.Name, &driver.CreatedAt)
+	`, id).Scan(&driver.ID, &driver.Name, &driver.CreatedAt, &driver.ArchivedAt, &driver.Version)
 	
 	if err != nil {
 		return nil, err
@@ -52,36 +39,154 @@ func GetDriverByID(ctx context.Context, id int) (*Driver, error) {
 
 func CreateDriver(ctx context.Context, name string) (*Driver, error) {
 	var driver Driver
-	err := database.Pool.QueryRow(ctx, `
-		INSERT INTO drivers (name) 
-		VALUES ($1) 
-		RETURNING id, name, created_at
-	`, name).Scan(&driver.ID, &driver.Name, &driver.CreatedAt)
-	
+	err := database.InTx(ctx, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			INSERT INTO drivers (name) 
+			VALUES ($1) 
+			RETURNING id, name, created_at, version
+		`, name).Scan(&driver.ID, &driver.Name, &driver.CreatedAt, &driver.Version)
+	})
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return &driver, nil
 }
 
-func DeleteDriver(ctx context.Context, id int) error {
-	_, err := database.Pool.Exec(ctx, `DELETE FROM drivers WHERE id = $1`, id)
-	return err
+// ArchiveDriver переносит водителя в архив; его маршруты сохраняются
+// для отчётов.
+func ArchiveDriver(ctx context.Context, id int) error {
+	return archiveRow(ctx, "drivers", id)
+}
+
+// RestoreDriver возвращает водителя из архива.
+func RestoreDriver(ctx context.Context, id int) (*Driver, error) {
+	if err := restoreRow(ctx, "drivers", id); err != nil {
+		return nil, err
+	}
+	return GetDriverByID(ctx, id)
 }
 
-func UpdateDriver(ctx context.Context, id int, name string) (*Driver, error) {
+// PurgeDriver окончательно удаляет архивного водителя; его маршруты
+// удаляются каскадно и попадают в журнал изменений той же транзакцией.
+func PurgeDriver(ctx context.Context, id int) error {
+	return purgeRow(ctx, "drivers", id)
+}
+
+// UpdateDriver изменяет действующего водителя; архивного нужно сначала
+// восстановить. Если передана ifVersion, а версия водителя другая,
+// возвращается ErrVersionMismatch.
+func UpdateDriver(ctx context.Context, id int, name string, ifVersion *int) (*Driver, error) {
 	var driver Driver
-	err := database.Pool.QueryRow(ctx, `
-		UPDATE drivers 
-		SET name = $1
-		WHERE id = $2
-		RETURNING id, name, created_at
-	`, name, id).Scan(&driver.ID, &driver.Name, &driver.CreatedAt)
-	
+	err := database.InTx(ctx, func(tx pgx.Tx) error {
+		if err := checkVersion(ctx, tx, "drivers", id, ifVersion); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `
+			UPDATE drivers 
+			SET name = $1
+			WHERE id = $2 AND archived_at IS NULL
+			RETURNING id, name, created_at, version
+		`, name, id).Scan(&driver.ID, &driver.Name, &driver.CreatedAt, &driver.Version)
+	})
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return &driver, nil
 }
+
+// DriverFilter — фильтры списка водителей.
+type DriverFilter struct {
+	Name        string // поиск по подстроке без учёта регистра
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Archived    string // ArchivedExclude, ArchivedInclude или ArchivedOnly
+}
+
+var DriverListSpec = listquery.Spec{
+	IDColumn: "id",
+	Sorts: map[string]listquery.SortField{
+		"id":         {Column: "id", Type: "int"},
+		"name":       {Column: "name", Type: "text"},
+		"created_at": {Column: "COALESCE(created_at, 'epoch'::timestamptz)", Type: "timestamptz"},
+	},
+	DefaultSort: "id",
+	MaxLimit:    500,
+}
+
+func ListDrivers(ctx context.Context, f DriverFilter, p listquery.Params) (*listquery.Page, error) {
+	b := &listquery.Builder{}
+	whereArchived(b, "archived_at", f.Archived)
+	if f.Name != "" {
+		b.Where("name ILIKE '%' || ? || '%'", f.Name)
+	}
+	if f.CreatedFrom != nil {
+		b.Where("created_at >= ?", *f.CreatedFrom)
+	}
+	if f.CreatedTo != nil {
+		b.Where("created_at < ?", *f.CreatedTo)
+	}
+
+	var total int
+	if err := database.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM drivers`+b.WhereSQL(), b.Args()...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	p.ApplyCursor(b)
+	rows, err := database.Pool.Query(ctx, `
+		SELECT id, name, created_at, archived_at, version 
+		FROM drivers`+b.WhereSQL()+p.OrderSQL(), b.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drivers := []Driver{}
+	for rows.Next() {
+		var d Driver
+		if err := rows.Scan(&d.ID, &d.Name, &d.CreatedAt, &d.ArchivedAt, &d.Version); err != nil {
+			return nil, err
+		}
+		drivers = append(drivers, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	n, next := p.Trim(len(drivers), func(i int) (interface{}, int) {
+		switch p.Sort {
+		case "name":
+			return drivers[i].Name, drivers[i].ID
+		case "created_at":
+			return drivers[i].CreatedAt, drivers[i].ID
+		}
+		return drivers[i].ID, drivers[i].ID
+	})
+
+	return &listquery.Page{Items: drivers[:n], NextCursor: next, Total: total}, nil
+}
+
+// ExistingDriverIDs возвращает множество тех ids, для которых есть
+// действующий (не архивный) водитель.
+func ExistingDriverIDs(ctx context.Context, ids []int) (map[int]bool, error) {
+	existing := make(map[int]bool, len(ids))
+	if len(ids) == 0 {
+		return existing, nil
+	}
+
+	rows, err := database.Pool.Query(ctx, `SELECT id FROM drivers WHERE id = ANY($1) AND archived_at IS NULL`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		existing[id] = true
+	}
+	return existing, rows.Err()
+}
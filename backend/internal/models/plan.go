@@ -0,0 +1,275 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/planning"
+	"garbage_trucks/backend/internal/routing"
+	"math"
+	"strings"
+	"time"
+)
+
+// PlanStatusDraft — план построен, но маршруты по нему ещё не созданы.
+const PlanStatusDraft = "draft"
+
+// PlanVehicle — машина водителя, доступная в день планирования.
+type PlanVehicle struct {
+	DriverID   int
+	Capacity   int // контейнеров за смену
+	ShiftStart time.Time
+	ShiftEnd   time.Time
+}
+
+// PlanRequest — что планировать: действующие точки (все или выбранные по
+// идентификаторам, району и городу), машины и база.
+type PlanRequest struct {
+	Date       time.Time
+	Depot      planning.Point
+	Vehicles   []PlanVehicle
+	PointIDs   []int
+	DistrictID *int
+	City       string
+	Budget     time.Duration
+}
+
+// PlanStop — точка в маршруте черновика.
+type PlanStop struct {
+	Order      int       `json:"order"`
+	PointID    int       `json:"point_id"`
+	Name       string    `json:"name"`
+	Address    string    `json:"address"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	Containers int       `json:"containers"`
+	Arrival    time.Time `json:"arrival"`
+	LegMeters  int       `json:"leg_meters"` // от предыдущей точки или базы
+}
+
+// PlanRoute — маршрут водителя в черновике.
+type PlanRoute struct {
+	DriverID        int        `json:"driver_id"`
+	DriverName      string     `json:"driver_name"`
+	Capacity        int        `json:"capacity"`
+	Load            int        `json:"load"`
+	Meters          int        `json:"meters"` // с возвратом на базу
+	DurationMinutes int        `json:"duration_minutes"`
+	ShiftStart      time.Time  `json:"shift_start"`
+	ShiftEnd        time.Time  `json:"shift_end"`
+	ReturnAt        time.Time  `json:"return_at"`
+	Stops           []PlanStop `json:"stops"`
+}
+
+// PlanPoint — точка, не вошедшая в план.
+type PlanPoint struct {
+	PointID    int    `json:"point_id"`
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	Containers int    `json:"containers"`
+}
+
+// PlanLocation — база в черновике.
+type PlanLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// PlanDraft — черновик плана для проверки диспетчером; в базе ничего
+// не меняется.
+type PlanDraft struct {
+	Date        string       `json:"date"`
+	Status      string       `json:"status"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Depot       PlanLocation `json:"depot"`
+	BudgetMs    int64        `json:"time_budget_ms"`
+	Iterations  int          `json:"iterations"`
+	TotalMeters int          `json:"total_meters"`
+	TotalStops  int          `json:"total_stops"`
+	Routes      []PlanRoute  `json:"routes"`
+	Unassigned  []PlanPoint  `json:"unassigned"`
+}
+
+// planPoint — точка для планирования.
+type planPoint struct {
+	PlanPoint
+	lat, lon     float64
+	restrictions PointRestrictions
+}
+
+// PlanCity строит черновик маршрутов на день: действующие точки
+// раздаются машинам с учётом вместимости (контейнеры точки), смены и окон
+// обслуживания так, чтобы суммарный путь был короче, а маршруты — ровнее.
+// Поиск идёт не дольше req.Budget. Время в пути считается как в обзоре
+// диспетчера: по дорожному графу, если он загружен.
+func PlanCity(ctx context.Context, req PlanRequest) (*PlanDraft, error) {
+	points, err := planPoints(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	names, err := driverNames(ctx, req.Vehicles)
+	if err != nil {
+		return nil, err
+	}
+
+	problem := &planning.Problem{
+		Depot:       req.Depot,
+		ServiceTime: etaServiceTime,
+		SpeedKmh:    etaSpeedKmh,
+		Distance: func(a, b planning.Point) float64 {
+			m, _ := travel(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+			return m
+		},
+	}
+	byID := make(map[int]*planPoint, len(points))
+	for i := range points {
+		p := &points[i]
+		byID[p.PointID] = p
+		stop := planning.Stop{
+			ID: p.PointID, Point: planning.Point{Latitude: p.lat, Longitude: p.lon}, Demand: p.Containers,
+		}
+		if from, to, ok := p.restrictions.Window(req.Date); ok {
+			stop.Open, stop.Close = from, to
+		}
+		problem.Stops = append(problem.Stops, stop)
+	}
+	for _, v := range req.Vehicles {
+		problem.Vehicles = append(problem.Vehicles, planning.Vehicle{
+			ID: v.DriverID, Capacity: v.Capacity, ShiftStart: v.ShiftStart, ShiftEnd: v.ShiftEnd,
+		})
+	}
+
+	if routing.Available() {
+		if err := roadMatrices(ctx, problem); err != nil {
+			return nil, err
+		}
+	}
+
+	sol := planning.Solve(ctx, problem, req.Budget)
+
+	draft := &PlanDraft{
+		Date:        req.Date.Format("2006-01-02"),
+		Status:      PlanStatusDraft,
+		GeneratedAt: time.Now(),
+		Depot:       PlanLocation{Latitude: req.Depot.Latitude, Longitude: req.Depot.Longitude},
+		BudgetMs:    req.Budget.Milliseconds(),
+		Iterations:  sol.Iterations,
+		TotalMeters: int(math.Round(sol.Meters)),
+		Routes:      make([]PlanRoute, len(sol.Routes)),
+		Unassigned:  []PlanPoint{},
+	}
+	for i, r := range sol.Routes {
+		v := req.Vehicles[i]
+		route := PlanRoute{
+			DriverID: v.DriverID, DriverName: names[v.DriverID], Capacity: v.Capacity, Load: r.Load,
+			Meters: int(math.Round(r.Meters)), DurationMinutes: int(math.Round(r.Duration.Minutes())),
+			ShiftStart: v.ShiftStart, ShiftEnd: v.ShiftEnd, ReturnAt: r.ReturnAt,
+			Stops: make([]PlanStop, len(r.Stops)),
+		}
+		for k, s := range r.Stops {
+			p := byID[s.StopID]
+			route.Stops[k] = PlanStop{
+				Order: k + 1, PointID: p.PointID, Name: p.Name, Address: p.Address,
+				Latitude: p.lat, Longitude: p.lon, Containers: p.Containers,
+				Arrival: s.Arrival.Truncate(time.Minute), LegMeters: int(math.Round(s.Meters)),
+			}
+		}
+		draft.Routes[i] = route
+		draft.TotalStops += len(r.Stops)
+	}
+	for _, id := range sol.Unassigned {
+		draft.Unassigned = append(draft.Unassigned, byID[id].PlanPoint)
+	}
+	return draft, nil
+}
+
+// roadMatrices считает для задачи пути по дорожному графу. Пары, между
+// которыми пути нет (точка далеко от дорог или в отрезанной части
+// графа), оцениваются по прямой, как без графа.
+func roadMatrices(ctx context.Context, problem *planning.Problem) error {
+	pts := make([]routing.Point, len(problem.Stops)+1)
+	pts[0] = routing.Point{Latitude: problem.Depot.Latitude, Longitude: problem.Depot.Longitude}
+	for i, st := range problem.Stops {
+		pts[i+1] = routing.Point{Latitude: st.Point.Latitude, Longitude: st.Point.Longitude}
+	}
+	m, err := routing.DistanceMatrix(ctx, pts)
+	if err != nil {
+		return err
+	}
+	for i := range pts {
+		for j := range pts {
+			if i != j && math.IsInf(m.Meters[i][j], 1) {
+				meters := straightMeters(pts[i].Latitude, pts[i].Longitude, pts[j].Latitude, pts[j].Longitude)
+				m.Meters[i][j], m.Seconds[i][j] = meters, straightTime(meters).Seconds()
+			}
+		}
+	}
+	problem.Meters, problem.Seconds = m.Meters, m.Seconds
+	return nil
+}
+
+// planPoints выбирает действующие точки для плана. Расписания вывоза по
+// дням нет, поэтому в план входят все действующие точки, подходящие под
+// фильтры, кроме тех, где в этот день недели вывоз запрещён.
+func planPoints(ctx context.Context, req PlanRequest) ([]planPoint, error) {
+	args := []interface{}{isoWeekday(req.Date)}
+	where := []string{"archived_at IS NULL", "NOT ($1 = ANY(excluded_weekdays))"}
+	if len(req.PointIDs) > 0 {
+		args = append(args, req.PointIDs)
+		where = append(where, fmt.Sprintf("id = ANY($%d)", len(args)))
+	}
+	if req.DistrictID != nil {
+		args = append(args, *req.DistrictID)
+		where = append(where, fmt.Sprintf("district_id = $%d", len(args)))
+	}
+	if req.City != "" {
+		args = append(args, req.City)
+		where = append(where, fmt.Sprintf("city = $%d", len(args)))
+	}
+
+	query := `
+		SELECT id, name, COALESCE(address, ''), latitude, longitude, container_count, ` + pointRestrictionColumns("") + `
+		FROM collection_points
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY id`
+
+	rows, err := database.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []planPoint
+	for rows.Next() {
+		var p planPoint
+		if err := rows.Scan(append([]interface{}{&p.PointID, &p.Name, &p.Address, &p.lat, &p.lon, &p.Containers}, p.restrictions.scanTargets()...)...); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// driverNames возвращает имена водителей машин.
+func driverNames(ctx context.Context, vehicles []PlanVehicle) (map[int]string, error) {
+	ids := make([]int, len(vehicles))
+	for i, v := range vehicles {
+		ids[i] = v.DriverID
+	}
+	rows, err := database.Pool.Query(ctx, `SELECT id, name FROM drivers WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[int]string, len(ids))
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
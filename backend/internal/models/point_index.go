@@ -0,0 +1,111 @@
+package models
+
+import (
+	"context"
+	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/geo"
+	"garbage_trucks/backend/internal/spatial"
+	"math"
+)
+
+// Ячейка сетки ~1 км по широте
+const pointIndexCellSize = 0.01
+
+// pointIndex — пространственный индекс всех точек сбора. Обновляется при
+// изменении точек в этом процессе и периодически перечитывается из базы,
+// чтобы учесть изменения, сделанные другими экземплярами.
+var pointIndex = spatial.NewGridIndex(pointIndexCellSize)
+
+// LoadPointIndex перечитывает координаты всех действующих точек в индекс.
+func LoadPointIndex(ctx context.Context) error {
+	rows, err := database.Pool.Query(ctx, `SELECT id, latitude, longitude FROM collection_points WHERE archived_at IS NULL`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var items []spatial.Item
+	for rows.Next() {
+		var it spatial.Item
+		if err := rows.Scan(&it.ID, &it.Latitude, &it.Longitude); err != nil {
+			return err
+		}
+		items = append(items, it)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	pointIndex.Replace(items)
+	return nil
+}
+
+// FindPointsInBBox возвращает не больше limit точек внутри прямоугольника,
+// ближайшие к его центру первыми.
+func FindPointsInBBox(ctx context.Context, b geo.BBox, limit int) ([]CollectionPoint, error) {
+	centerLat := (b.MinLat + b.MaxLat) / 2
+	centerLon := (b.MinLon + b.MaxLon) / 2
+	items := pointIndex.InBBox(b, centerLat, centerLon)
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return loadIndexedPoints(ctx, items)
+}
+
+// FindPointsNearby возвращает не больше limit точек в радиусе radius метров
+// от заданной, ближайшие первыми.
+func FindPointsNearby(ctx context.Context, lat, lon, radius float64, limit int) ([]CollectionPoint, error) {
+	return loadIndexedPoints(ctx, pointIndex.Nearby(lat, lon, radius, limit))
+}
+
+// loadIndexedPoints дочитывает из базы данные точек, найденных индексом,
+// сохраняя порядок по расстоянию. Точки, удалённые или архивированные
+// с момента последней загрузки индекса, пропускаются.
+func loadIndexedPoints(ctx context.Context, items []spatial.Item) ([]CollectionPoint, error) {
+	if len(items) == 0 {
+		return []CollectionPoint{}, nil
+	}
+
+	ids := make([]int, len(items))
+	for i, it := range items {
+		ids[i] = it.ID
+	}
+
+	rows, err := database.Pool.Query(ctx, `
+		SELECT 
+			cp.id, cp.name, cp.address, cp.latitude, cp.longitude, cp.city, cp.container_count, cp.district_id, cp.version,
+			`+pointDriversColumn+`,
+			`+pointRestrictionColumns("cp.")+`
+		FROM collection_points cp
+		WHERE cp.id = ANY($1) AND cp.archived_at IS NULL
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int]CollectionPoint, len(ids))
+	for rows.Next() {
+		var p CollectionPoint
+		if err := rows.Scan(append([]interface{}{&p.ID, &p.Name, &p.Address, &p.Latitude, &p.Longitude, &p.City, &p.ContainerCount, &p.DistrictID, &p.Version, &p.Drivers}, p.scanTargets()...)...); err != nil {
+			return nil, err
+		}
+		byID[p.ID] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	points := make([]CollectionPoint, 0, len(items))
+	for _, it := range items {
+		p, ok := byID[it.ID]
+		if !ok {
+			continue
+		}
+		distance := math.Round(it.Distance)
+		p.Distance = &distance
+		points = append(points, p)
+	}
+
+	return points, nil
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+)
+
+// Максимальные длины полей доступа к точке — размеры колонок
+const (
+	PointAccessNotesMaxLen = 500
+	PointGateCodeMaxLen    = 50
+)
+
+// Формат начала и конца окна обслуживания
+const ServiceWindowLayout = "15:04"
+
+// PointRestrictions — ограничения обслуживания точки: окно времени,
+// дни недели без вывоза (ISO: 1 — понедельник, 7 — воскресенье) и
+// сведения для водителя о подъезде.
+//
+// При изменении точки nil означает «не менять», пустая строка или
+// пустой список — «убрать ограничение».
+type PointRestrictions struct {
+	WindowStart      *string `json:"window_start,omitempty"` // HH:MM
+	WindowEnd        *string `json:"window_end,omitempty"`
+	ExcludedWeekdays []int   `json:"excluded_weekdays,omitempty"`
+	AccessNotes      *string `json:"access_notes,omitempty"`
+	GateCode         *string `json:"gate_code,omitempty"`
+}
+
+// pointRestrictionColumns — колонки ограничений точки с префиксом
+// таблицы (например "cp."), в порядке scanTargets. Вместо кода ворот
+// выбирается NULL: он есть только в карточке точки и в маршрутном листе
+// водителя — PDF и JSON для приложения (pointRestrictionColumnsWithGateCode),
+// но не в списках.
+func pointRestrictionColumns(prefix string) string {
+	return restrictionColumns(prefix, `NULL::varchar`)
+}
+
+// pointRestrictionColumnsWithGateCode — то же, что pointRestrictionColumns,
+// вместе с кодом ворот.
+func pointRestrictionColumnsWithGateCode(prefix string) string {
+	return restrictionColumns(prefix, prefix+`gate_code`)
+}
+
+func restrictionColumns(prefix, gateCode string) string {
+	return `to_char(` + prefix + `window_start, 'HH24:MI'), to_char(` + prefix + `window_end, 'HH24:MI'), ` +
+		prefix + `excluded_weekdays, ` + prefix + `access_notes, ` + gateCode
+}
+
+func (pr *PointRestrictions) scanTargets() []interface{} {
+	return []interface{}{&pr.WindowStart, &pr.WindowEnd, &pr.ExcludedWeekdays, &pr.AccessNotes, &pr.GateCode}
+}
+
+// isoWeekday — день недели по ISO: 1 — понедельник, 7 — воскресенье.
+func isoWeekday(day time.Time) int {
+	if wd := day.Weekday(); wd != time.Sunday {
+		return int(wd)
+	}
+	return 7
+}
+
+// ServedOn сообщает, можно ли обслуживать точку в этот день недели.
+func (pr PointRestrictions) ServedOn(day time.Time) bool {
+	wd := isoWeekday(day)
+	for _, d := range pr.ExcludedWeekdays {
+		if d == wd {
+			return false
+		}
+	}
+	return true
+}
+
+// Window возвращает окно обслуживания в указанный день; ok == false —
+// окна нет, обслуживать можно в любое время.
+func (pr PointRestrictions) Window(day time.Time) (from, to time.Time, ok bool) {
+	if pr.WindowStart == nil || pr.WindowEnd == nil {
+		return time.Time{}, time.Time{}, false
+	}
+	start, err1 := time.Parse(ServiceWindowLayout, *pr.WindowStart)
+	end, err2 := time.Parse(ServiceWindowLayout, *pr.WindowEnd)
+	if err1 != nil || err2 != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return atClock(midnight, start), atClock(midnight, end), true
+}
+
+// atClock — момент дня midnight со временем суток из t.
+func atClock(midnight, t time.Time) time.Time {
+	return midnight.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+}
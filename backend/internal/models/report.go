@@ -0,0 +1,191 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"garbage_trucks/backend/internal/database"
+	"strings"
+	"time"
+)
+
+// CompletionReportRow — агрегированная строка отчёта о выполнении маршрутов.
+// Поля Date, DriverID, DriverName, City и District заполняются только если
+// соответствующее измерение указано в группировке.
+type CompletionReportRow struct {
+	Date            string   `json:"date,omitempty"`
+	DriverID        *int     `json:"driver_id,omitempty"`
+	DriverName      string   `json:"driver_name,omitempty"`
+	City            string   `json:"city,omitempty"`
+	District        string   `json:"district,omitempty"`
+	Total           int      `json:"total"`
+	Completed       int      `json:"completed"`
+	Skipped         int      `json:"skipped"`
+	Problem         int      `json:"problem"`
+	Pending         int      `json:"pending"`
+	InProgress      int      `json:"in_progress"`
+	CompletedPct    float64  `json:"completed_pct"`
+	SkippedPct      float64  `json:"skipped_pct"`
+	ProblemPct      float64  `json:"problem_pct"`
+	AvgDelayMinutes *float64 `json:"avg_delay_minutes,omitempty"`
+}
+
+// CompletionReportFilter — параметры построения отчёта.
+// From включительно, To исключительно.
+type CompletionReportFilter struct {
+	From       time.Time
+	To         time.Time
+	DriverID   *int
+	City       string
+	DistrictID *int
+	GroupBy    []string
+}
+
+// Допустимые измерения группировки отчёта
+const (
+	ReportGroupDate     = "date"
+	ReportGroupDriver   = "driver"
+	ReportGroupCity     = "city"
+	ReportGroupDistrict = "district"
+)
+
+var reportGroupColumns = map[string][]string{
+	ReportGroupDate:     {"r.scheduled_at::date"},
+	ReportGroupDriver:   {"r.driver_id", "d.name"},
+	ReportGroupCity:     {"COALESCE(cp.city, '')"},
+	ReportGroupDistrict: {"COALESCE(dist.name, '')"},
+}
+
+// IsValidReportGroup проверяет, что измерение группировки поддерживается.
+func IsValidReportGroup(group string) bool {
+	_, ok := reportGroupColumns[group]
+	return ok
+}
+
+func GetCompletionReport(ctx context.Context, f CompletionReportFilter) ([]CompletionReportRow, error) {
+	var groupCols []string
+	has := map[string]bool{}
+	for _, g := range f.GroupBy {
+		cols, ok := reportGroupColumns[g]
+		if !ok {
+			return nil, fmt.Errorf("неизвестная группировка: %s", g)
+		}
+		if has[g] {
+			continue
+		}
+		has[g] = true
+		groupCols = append(groupCols, cols...)
+	}
+
+	selectCols := []string{
+		"to_char(MIN(r.scheduled_at::date), 'YYYY-MM-DD')",
+		"MIN(r.driver_id)",
+		"MIN(d.name)",
+		"MIN(COALESCE(cp.city, ''))",
+		"MIN(COALESCE(dist.name, ''))",
+	}
+
+	args := []interface{}{f.From, f.To}
+	where := []string{"r.scheduled_at >= $1", "r.scheduled_at < $2"}
+	if f.DriverID != nil {
+		args = append(args, *f.DriverID)
+		where = append(where, fmt.Sprintf("r.driver_id = $%d", len(args)))
+	}
+	if f.City != "" {
+		args = append(args, f.City)
+		where = append(where, fmt.Sprintf("cp.city = $%d", len(args)))
+	}
+	if f.DistrictID != nil {
+		args = append(args, *f.DistrictID)
+		where = append(where, fmt.Sprintf("cp.district_id = $%d", len(args)))
+	}
+
+	query := `
+		SELECT
+			` + strings.Join(selectCols, ", ") + `,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE r.status = 'completed'),
+			COUNT(*) FILTER (WHERE r.status = 'skipped'),
+			COUNT(*) FILTER (WHERE r.status = 'problem'),
+			COUNT(*) FILTER (WHERE r.status = 'pending'),
+			COUNT(*) FILTER (WHERE r.status = 'in_progress'),
+			ROUND(AVG(EXTRACT(EPOCH FROM COALESCE(r.visited_at, r.completed_at) - r.scheduled_at) / 60)
+				FILTER (WHERE COALESCE(r.visited_at, r.completed_at) IS NOT NULL), 1)::float8
+		FROM routes r
+		JOIN drivers d ON r.driver_id = d.id
+		JOIN collection_points cp ON r.point_id = cp.id
+		LEFT JOIN districts dist ON cp.district_id = dist.id
+		WHERE ` + strings.Join(where, " AND ")
+	if len(groupCols) > 0 {
+		query += `
+		GROUP BY ` + strings.Join(groupCols, ", ") + `
+		ORDER BY ` + strings.Join(groupCols, ", ")
+	}
+
+	var report []CompletionReportRow
+	err := database.Retry(ctx, func() error {
+		var err error
+		report, err = scanCompletionReport(ctx, query, args, has)
+		return err
+	})
+	return report, err
+}
+
+// scanCompletionReport выполняет запрос отчёта на реплике (если она есть)
+// и заполняет в строках только поля выбранных группировок has.
+func scanCompletionReport(ctx context.Context, query string, args []interface{}, has map[string]bool) ([]CompletionReportRow, error) {
+	rows, err := database.Reporting().Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []CompletionReportRow
+	for rows.Next() {
+		var row CompletionReportRow
+		var date, driverName, city, district *string
+		var driverID *int
+		if err := rows.Scan(
+			&date, &driverID, &driverName, &city, &district,
+			&row.Total, &row.Completed, &row.Skipped, &row.Problem, &row.Pending, &row.InProgress,
+			&row.AvgDelayMinutes,
+		); err != nil {
+			return nil, err
+		}
+
+		// Пустая выборка без группировки даёт одну строку с нулями
+		if row.Total == 0 {
+			continue
+		}
+
+		if has[ReportGroupDate] && date != nil {
+			row.Date = *date
+		}
+		if has[ReportGroupDriver] {
+			row.DriverID = driverID
+			if driverName != nil {
+				row.DriverName = *driverName
+			}
+		}
+		if has[ReportGroupCity] && city != nil {
+			row.City = *city
+		}
+		if has[ReportGroupDistrict] && district != nil {
+			row.District = *district
+		}
+
+		row.CompletedPct = percent(row.Completed, row.Total)
+		row.SkippedPct = percent(row.Skipped, row.Total)
+		row.ProblemPct = percent(row.Problem, row.Total)
+
+		report = append(report, row)
+	}
+
+	return report, rows.Err()
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(float64(part)*10000/float64(total)+0.5)) / 100
+}
@@ -0,0 +1,616 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"garbage_trucks/backend/internal/database"
+	"garbage_trucks/backend/internal/geo"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Перераспределение остановок сошедшего с линии водителя. Оставшиеся
+// (pending) остановки раздаются водителям, которые в этот день на смене:
+// каждая вставляется в маршрут водителя туда, где добавит меньше всего
+// пути. Путь считается от текущего положения водителя по его оставшимся
+// остановкам; к добавленным метрам прибавляется штраф за уже оставшиеся
+// у водителя контейнеры, чтобы не перегрузить одного, даже если он ближе.
+// Окна обслуживания точек на выбор водителя не влияют: время остановки
+// назначается не раньше начала окна, а назначения под угрозой отмечаются.
+
+// Штраф в метрах за каждый контейнер, оставшийся у водителя
+const rerouteLoadPenaltyMeters = 300.0
+
+var (
+	// ErrRerouteStale — остановка уже не ожидает объезда у этого водителя:
+	// её начали, выполнили или передали другому после построения плана.
+	ErrRerouteStale = errors.New("route is no longer a pending stop of the driver")
+	// ErrRerouteNoDrivers — в этот день нет других водителей на смене.
+	ErrRerouteNoDrivers = errors.New("no drivers on shift to take the stops")
+	// ErrRerouteTarget — остановку передают водителю, которого нет среди
+	// водителей на смене.
+	ErrRerouteTarget = errors.New("driver is not on shift")
+)
+
+// RerouteAssignment — остановка и водитель, которому она передаётся.
+type RerouteAssignment struct {
+	RouteID      int       `json:"route_id"`
+	Version      int       `json:"version"`
+	PointID      int       `json:"point_id"`
+	PointName    string    `json:"point_name"`
+	DriverID     int       `json:"driver_id"`
+	DriverName   string    `json:"driver_name"`
+	AfterRouteID *int      `json:"after_route_id"` // nil — первой из оставшихся
+	OrderNumber  int       `json:"order_number"`
+	ScheduledAt  time.Time `json:"scheduled_at"`
+	AddedMeters  int       `json:"added_meters"`
+	Risk         string    `json:"risk,omitempty"` // StopRiskWindow или StopRiskExcludedDay
+}
+
+// RerouteDriver — изменение нагрузки водителя, принимающего остановки.
+type RerouteDriver struct {
+	DriverID        int    `json:"driver_id"`
+	DriverName      string `json:"driver_name"`
+	StopsBefore     int    `json:"stops_before"` // оставшиеся остановки
+	StopsAdded      int    `json:"stops_added"`
+	ContainersAfter int    `json:"containers_after"` // оставшиеся контейнеры с учётом добавленных
+	AddedMeters     int    `json:"added_meters"`
+	// Откуда считался путь: gps, stop (начатая или последняя посещённая
+	// остановка) или пусто — водитель ещё не выехал
+	StartSource string `json:"start_source,omitempty"`
+}
+
+// ReroutePlan — предложение или применённое перераспределение.
+type ReroutePlan struct {
+	DriverID    int                 `json:"driver_id"`
+	Date        string              `json:"date"`
+	Applied     bool                `json:"applied"`
+	AddedMeters int                 `json:"added_meters"`
+	Assignments []RerouteAssignment `json:"assignments"`
+	Drivers     []RerouteDriver     `json:"drivers"`
+}
+
+// RerouteMove — остановка, которую диспетчер передаёт водителю.
+type RerouteMove struct {
+	RouteID  int
+	Version  int
+	DriverID int
+}
+
+// rerouteStop — остановка дня в расчёте.
+type rerouteStop struct {
+	routeID      int
+	version      int
+	pointID      int
+	pointName    string
+	lat, lon     float64
+	containers   int
+	orderNumber  int
+	scheduledAt  time.Time
+	status       string
+	visitedAt    *time.Time
+	moved        bool
+	restrictions PointRestrictions
+}
+
+// rerouteTour — водитель на смене: остановки дня и оставшийся путь.
+type rerouteTour struct {
+	driverID    int
+	driverName  string
+	day         []*rerouteStop // все остановки дня по order_number
+	remaining   []*rerouteStop // оставшиеся по порядку, с добавленными
+	hasStart    bool
+	startLat    float64
+	startLon    float64
+	startSource string
+	load        int // оставшиеся контейнеры
+	stopsBefore int
+	maxOrder    int // наибольший order_number дня до перераспределения
+	addedMeters float64
+}
+
+func (t *rerouteTour) node(i int) (lat, lon float64, ok bool) {
+	if i < 0 {
+		return t.startLat, t.startLon, t.hasStart
+	}
+	if i >= len(t.remaining) {
+		return 0, 0, false
+	}
+	return t.remaining[i].lat, t.remaining[i].lon, true
+}
+
+// insertion находит место вставки остановки с наименьшим приростом пути:
+// pos — индекс в remaining, meters — добавленные метры. Путь открытый —
+// возвращаться на базу не нужно.
+func (t *rerouteTour) insertion(s *rerouteStop) (pos int, meters float64) {
+	best := math.Inf(1)
+	for i := 0; i <= len(t.remaining); i++ {
+		pLat, pLon, hasPrev := t.node(i - 1)
+		nLat, nLon, hasNext := t.node(i)
+		var added float64
+		if hasPrev {
+			added += geo.Distance(pLat, pLon, s.lat, s.lon)
+		}
+		if hasNext {
+			added += geo.Distance(s.lat, s.lon, nLat, nLon)
+		}
+		if hasPrev && hasNext {
+			added -= geo.Distance(pLat, pLon, nLat, nLon)
+		}
+		if added < best {
+			best, pos = added, i
+		}
+	}
+	return pos, best
+}
+
+func (t *rerouteTour) insert(s *rerouteStop, pos int, meters float64) {
+	t.remaining = append(t.remaining, nil)
+	copy(t.remaining[pos+1:], t.remaining[pos:])
+	t.remaining[pos] = s
+	t.load += s.containers
+	t.addedMeters += meters
+}
+
+// rerouteCost — цена вставки с учётом нагрузки водителя.
+func rerouteCost(t *rerouteTour, meters float64) float64 {
+	return meters + rerouteLoadPenaltyMeters*float64(t.load)
+}
+
+// rerouteState — остановки сошедшего водителя и водители на смене.
+type rerouteState struct {
+	pending []*rerouteStop
+	tours   []*rerouteTour
+}
+
+// rerouteDayStops — остановки дня водителя брошенного маршрута и всех
+// действующих водителей, у которых в этот день остались остановки.
+// Точки, архивированные до начала дня, не учитываются.
+var rerouteDayStops = `
+	SELECT r.driver_id, d.name, r.id, r.version, r.point_id, cp.name, cp.latitude, cp.longitude,
+		cp.container_count, r.order_number, r.scheduled_at, r.status, COALESCE(r.visited_at, r.completed_at),
+		` + pointRestrictionColumns("cp.") + `
+	FROM routes r
+	JOIN drivers d ON d.id = r.driver_id AND d.archived_at IS NULL
+	JOIN collection_points cp ON cp.id = r.point_id
+	WHERE r.scheduled_at >= $2 AND r.scheduled_at < $3
+		AND (cp.archived_at IS NULL OR cp.archived_at >= $2)
+		AND (r.driver_id = $1 OR r.driver_id IN (
+			SELECT driver_id FROM routes
+			WHERE scheduled_at >= $2 AND scheduled_at < $3 AND status IN ('pending', 'in_progress')
+		))
+	ORDER BY r.driver_id, r.order_number, r.id
+`
+
+// loadRerouteState читает остановки дня [from, to). С lock строки
+// остановок блокируются до конца транзакции. Для сегодняшнего дня путь
+// водителя начинается с его последнего известного положения.
+func loadRerouteState(ctx context.Context, q database.Querier, driverID int, from, to, now time.Time, lock bool) (*rerouteState, error) {
+	query := rerouteDayStops
+	if lock {
+		query += ` FOR UPDATE OF r`
+	}
+	rows, err := q.Query(ctx, query, driverID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	st := &rerouteState{}
+	byDriver := map[int]*rerouteTour{}
+	for rows.Next() {
+		var s rerouteStop
+		var dID int
+		var dName string
+		err := rows.Scan(append([]interface{}{&dID, &dName, &s.routeID, &s.version, &s.pointID, &s.pointName, &s.lat, &s.lon,
+			&s.containers, &s.orderNumber, &s.scheduledAt, &s.status, &s.visitedAt}, s.restrictions.scanTargets()...)...)
+		if err != nil {
+			return nil, err
+		}
+		if dID == driverID {
+			if s.status == RouteStatusPending {
+				st.pending = append(st.pending, &s)
+			}
+			continue
+		}
+		t := byDriver[dID]
+		if t == nil {
+			t = &rerouteTour{driverID: dID, driverName: dName}
+			byDriver[dID] = t
+			st.tours = append(st.tours, t)
+		}
+		t.day = append(t.day, &s)
+		t.maxOrder = s.orderNumber
+		if s.status == RouteStatusPending || s.status == RouteStatusInProgress {
+			t.load += s.containers
+		}
+		if s.status == RouteStatusPending {
+			t.remaining = append(t.remaining, &s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, t := range st.tours {
+		t.stopsBefore = len(t.remaining)
+	}
+	if !now.Before(from) && now.Before(to) {
+		if err := setRerouteStarts(ctx, q, st.tours, from, to, now); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// setRerouteStarts задаёт начало пути водителей: свежая GPS-отметка,
+// начатая остановка или последняя посещённая — как в обзоре диспетчера.
+func setRerouteStarts(ctx context.Context, q database.Querier, tours []*rerouteTour, from, to, now time.Time) error {
+	ids := make([]int, len(tours))
+	for i, t := range tours {
+		ids[i] = t.driverID
+	}
+	rows, err := q.Query(ctx, `
+		SELECT DISTINCT ON (driver_id) driver_id, latitude, longitude, recorded_at
+		FROM driver_positions
+		WHERE driver_id = ANY($1) AND recorded_at >= $2 AND recorded_at < $3
+		ORDER BY driver_id, recorded_at DESC
+	`, ids, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	gps := map[int]LastPosition{}
+	for rows.Next() {
+		var id int
+		var p LastPosition
+		if err := rows.Scan(&id, &p.Latitude, &p.Longitude, &p.RecordedAt); err != nil {
+			return err
+		}
+		gps[id] = p
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, t := range tours {
+		if p, ok := gps[t.driverID]; ok && now.Sub(p.RecordedAt) <= positionMaxAge {
+			t.hasStart, t.startLat, t.startLon, t.startSource = true, p.Latitude, p.Longitude, PositionSourceGPS
+			continue
+		}
+		var last *rerouteStop
+		for _, s := range t.day {
+			if s.status == RouteStatusInProgress {
+				last = s
+				break
+			}
+			if s.visitedAt != nil && (last == nil || s.visitedAt.After(*last.visitedAt)) {
+				last = s
+			}
+		}
+		if last != nil {
+			t.hasStart, t.startLat, t.startLon, t.startSource = true, last.lat, last.lon, PositionSourceStop
+		}
+	}
+	return nil
+}
+
+// ProposeReroute предлагает, кому из водителей на смене передать
+// оставшиеся остановки водителя за день. Остановки раздаются по одной:
+// первой — та, для которой разница между лучшим и вторым по цене
+// водителем больше всего (иначе её потом пришлось бы везти далеко).
+// Если других водителей на смене нет, возвращается ErrRerouteNoDrivers.
+func ProposeReroute(ctx context.Context, driverID int, day, now time.Time) (*ReroutePlan, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+
+	st, err := loadRerouteState(ctx, database.Pool, driverID, from, to, now, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(st.pending) > 0 && len(st.tours) == 0 {
+		return nil, ErrRerouteNoDrivers
+	}
+
+	left := st.pending
+	for len(left) > 0 {
+		bestStop, bestRegret := -1, math.Inf(-1)
+		var bestTour *rerouteTour
+		var bestPos int
+		var bestMeters float64
+		for i, s := range left {
+			first, second := math.Inf(1), math.Inf(1)
+			var tour *rerouteTour
+			var pos int
+			var meters float64
+			for _, t := range st.tours {
+				p, m := t.insertion(s)
+				c := rerouteCost(t, m)
+				switch {
+				case c < first:
+					first, second = c, first
+					tour, pos, meters = t, p, m
+				case c < second:
+					second = c
+				}
+			}
+			regret := second - first
+			if math.IsInf(second, 1) {
+				regret = first // единственный водитель
+			}
+			if regret > bestRegret {
+				bestStop, bestRegret = i, regret
+				bestTour, bestPos, bestMeters = tour, pos, meters
+			}
+		}
+		s := left[bestStop]
+		s.moved = true
+		bestTour.insert(s, bestPos, bestMeters)
+		left = append(left[:bestStop], left[bestStop+1:]...)
+	}
+
+	return st.plan(driverID, from, now), nil
+}
+
+// ApplyReroute передаёт остановки водителям одной транзакцией. Каждая
+// остановка должна всё ещё ожидать объезда у водителя driverID в версии
+// из предложения, иначе ErrRerouteStale или ErrVersionMismatch; водитель
+// должен быть на смене, иначе ErrRerouteTarget. Остановка встаёт в его
+// маршрут туда, где добавит меньше пути; порядок остальных остановок
+// дня сохраняется.
+func ApplyReroute(ctx context.Context, driverID int, day, now time.Time, moves []RerouteMove) (*ReroutePlan, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+
+	var plan *ReroutePlan
+	err := database.InTx(ctx, func(tx pgx.Tx) error {
+		st, err := loadRerouteState(ctx, tx, driverID, from, to, now, true)
+		if err != nil {
+			return err
+		}
+
+		pending := make(map[int]*rerouteStop, len(st.pending))
+		for _, s := range st.pending {
+			pending[s.routeID] = s
+		}
+		tours := make(map[int]*rerouteTour, len(st.tours))
+		for _, t := range st.tours {
+			tours[t.driverID] = t
+		}
+
+		for _, m := range moves {
+			s := pending[m.RouteID]
+			if s == nil || s.moved {
+				return ErrRerouteStale
+			}
+			if s.version != m.Version {
+				return ErrVersionMismatch
+			}
+			t := tours[m.DriverID]
+			if t == nil {
+				return ErrRerouteTarget
+			}
+			s.moved = true
+			pos, meters := t.insertion(s)
+			t.insert(s, pos, meters)
+		}
+
+		plan = st.plan(driverID, from, now)
+		plan.Applied = true
+		return st.save(ctx, tx, plan)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// plan раскладывает добавленные остановки по дню водителей: новые
+// order_number и плановое время. Остановки дня нумеруются подряд с
+// первого номера дня; добавленная встаёт перед той оставшейся, перед
+// которой её вставили.
+func (st *rerouteState) plan(driverID int, from, now time.Time) *ReroutePlan {
+	plan := &ReroutePlan{
+		DriverID:    driverID,
+		Date:        from.Format("2006-01-02"),
+		Assignments: []RerouteAssignment{},
+		Drivers:     []RerouteDriver{},
+	}
+
+	for _, t := range st.tours {
+		added := 0
+		for _, s := range t.remaining {
+			if s.moved {
+				added++
+			}
+		}
+		if added == 0 {
+			continue
+		}
+
+		t.scheduleMoved(from, now)
+		base := t.day[0].orderNumber
+		for i, s := range t.ordered() {
+			s.orderNumber = base + i
+		}
+
+		var prev *rerouteStop
+		for _, s := range t.remaining {
+			if s.moved {
+				a := RerouteAssignment{
+					RouteID: s.routeID, Version: s.version, PointID: s.pointID, PointName: s.pointName,
+					DriverID: t.driverID, DriverName: t.driverName,
+					OrderNumber: s.orderNumber, ScheduledAt: s.scheduledAt,
+					Risk: stopRisk(s.restrictions, from, s.scheduledAt),
+				}
+				if prev != nil {
+					a.AfterRouteID = &prev.routeID
+				}
+				a.AddedMeters = int(math.Round(t.moveMeters(s)))
+				plan.Assignments = append(plan.Assignments, a)
+			}
+			prev = s
+		}
+
+		plan.Drivers = append(plan.Drivers, RerouteDriver{
+			DriverID: t.driverID, DriverName: t.driverName,
+			StopsBefore: t.stopsBefore, StopsAdded: added,
+			ContainersAfter: t.load, AddedMeters: int(math.Round(t.addedMeters)),
+			StartSource: t.startSource,
+		})
+		plan.AddedMeters += int(math.Round(t.addedMeters))
+	}
+
+	sort.Slice(plan.Assignments, func(i, j int) bool {
+		return plan.Assignments[i].RouteID < plan.Assignments[j].RouteID
+	})
+	return plan
+}
+
+// moveMeters — сколько пути добавляет остановка на своём месте в
+// итоговом маршруте.
+func (t *rerouteTour) moveMeters(s *rerouteStop) float64 {
+	for i, r := range t.remaining {
+		if r != s {
+			continue
+		}
+		pLat, pLon, hasPrev := t.node(i - 1)
+		nLat, nLon, hasNext := t.node(i + 1)
+		var m float64
+		if hasPrev {
+			m += geo.Distance(pLat, pLon, s.lat, s.lon)
+		}
+		if hasNext {
+			m += geo.Distance(s.lat, s.lon, nLat, nLon)
+		}
+		if hasPrev && hasNext {
+			m -= geo.Distance(pLat, pLon, nLat, nLon)
+		}
+		return m
+	}
+	return 0
+}
+
+// ordered возвращает остановки дня в новом порядке: добавленные стоят
+// перед следующей за ними оставшейся остановкой, в конце — после всех.
+func (t *rerouteTour) ordered() []*rerouteStop {
+	before := map[*rerouteStop][]*rerouteStop{}
+	var buf []*rerouteStop
+	for _, s := range t.remaining {
+		if s.moved {
+			buf = append(buf, s)
+			continue
+		}
+		before[s] = buf
+		buf = nil
+	}
+
+	out := make([]*rerouteStop, 0, len(t.day)+len(buf))
+	for _, s := range t.day {
+		out = append(out, before[s]...)
+		out = append(out, s)
+	}
+	return append(out, buf...)
+}
+
+// scheduleMoved назначает добавленным остановкам плановое время: после
+// предыдущей остановки с дорогой и обслуживанием, от текущего положения
+// или, если водитель ещё не выехал, перед следующей; сегодня — не раньше
+// now.
+// Время остальных остановок не меняется: отставание видно в обзоре.
+func (t *rerouteTour) scheduleMoved(from, now time.Time) {
+	for i, s := range t.remaining {
+		if !s.moved {
+			continue
+		}
+		switch {
+		case i > 0:
+			p := t.remaining[i-1]
+			_, d := travel(p.lat, p.lon, s.lat, s.lon)
+			s.scheduledAt = p.scheduledAt.Add(etaServiceTime + d)
+		case t.hasStart:
+			_, d := travel(t.startLat, t.startLon, s.lat, s.lon)
+			s.scheduledAt = now.Add(d)
+		default:
+			s.scheduledAt = from.Add(8 * time.Hour)
+			for _, n := range t.remaining[i+1:] {
+				if !n.moved {
+					_, d := travel(s.lat, s.lon, n.lat, n.lon)
+					s.scheduledAt = n.scheduledAt.Add(-etaServiceTime - d)
+					break
+				}
+			}
+		}
+		// Сегодня раньше текущего момента не успеть
+		if now.After(s.scheduledAt) && now.Before(from.AddDate(0, 0, 1)) {
+			s.scheduledAt = now
+		}
+		// До начала окна точку не обслужить
+		if open, _, ok := s.restrictions.Window(from); ok && s.scheduledAt.Before(open) {
+			s.scheduledAt = open
+		}
+		s.scheduledAt = s.scheduledAt.Truncate(time.Minute)
+	}
+}
+
+// save записывает перераспределение: добавленные остановки переходят
+// к новым водителям, номера остановок дня обновляются, а более поздние
+// остановки водителя сдвигаются, чтобы номера не пересеклись.
+func (st *rerouteState) save(ctx context.Context, tx pgx.Tx, plan *ReroutePlan) error {
+	for _, t := range st.tours {
+		if t.stopsBefore == len(t.remaining) {
+			continue
+		}
+
+		ordered := t.ordered()
+		if shift := ordered[len(ordered)-1].orderNumber - t.maxOrder; shift > 0 {
+			_, err := tx.Exec(ctx, `
+				UPDATE routes SET order_number = order_number + $2
+				WHERE driver_id = $1 AND order_number > $3 AND id <> ALL($4)
+			`, t.driverID, shift, t.maxOrder, routeIDs(ordered))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, s := range ordered {
+			if s.moved {
+				_, err := tx.Exec(ctx, `
+					UPDATE routes SET driver_id = $2, order_number = $3, scheduled_at = $4
+					WHERE id = $1
+				`, s.routeID, t.driverID, s.orderNumber, s.scheduledAt)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			_, err := tx.Exec(ctx, `
+				UPDATE routes SET order_number = $2 WHERE id = $1 AND order_number <> $2
+			`, s.routeID, s.orderNumber)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Версии изменились триггером — возвращаем актуальные
+	for i := range plan.Assignments {
+		a := &plan.Assignments[i]
+		if err := tx.QueryRow(ctx, `SELECT version FROM routes WHERE id = $1`, a.RouteID).Scan(&a.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func routeIDs(stops []*rerouteStop) []int {
+	ids := make([]int, len(stops))
+	for i, s := range stops {
+		ids[i] = s.routeID
+	}
+	return ids
+}
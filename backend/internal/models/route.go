@@ -3,10 +3,36 @@
 import (
 	"context"
 	"garbage_trucks/backend/internal/database"
-	"log"
+	"garbage_trucks/backend/internal/listquery"
 	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Статусы остановки маршрута
+const (
+	RouteStatusPending    = "pending"
+	RouteStatusInProgress = "in_progress"
+	RouteStatusCompleted  = "completed"
+	RouteStatusSkipped    = "skipped"
+	RouteStatusProblem    = "problem"
 )
 
+// RouteStatuses — все допустимые статусы остановки.
+var RouteStatuses = []string{
+	RouteStatusPending, RouteStatusInProgress, RouteStatusCompleted, RouteStatusSkipped, RouteStatusProblem,
+}
+
+// IsValidRouteStatus проверяет, что статус остановки допустим.
+func IsValidRouteStatus(status string) bool {
+	for _, s := range RouteStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
 type Route struct {
 	ID          int              `json:"id"`
 	DriverID    int              `json:"driver_id"`
@@ -16,20 +42,91 @@ type Route struct {
 	Status      string           `json:"status"`
 	CompletedAt *time.Time       `json:"completed_at,omitempty"`
 	Comment     *string          `json:"comment,omitempty"`
+	Version     int              `json:"version"`
 	Point       *CollectionPoint `json:"point"`
 }
 
-func GetRoutesByDriverID(ctx context.Context, driverID int) ([]Route, error) {
-	rows, err := database.Pool.Query(ctx, `
-        SELECT 
+// GetRoutesByDriverIDOnDate возвращает остановки водителя, запланированные
+// на указанный день, в порядке объезда. Точки, архивированные до начала
+// дня, пропускаются; в листах за прошлые дни они остаются. Это данные
+// маршрутного листа (PDF и приложение водителя), поэтому у точек есть
+// код ворот.
+func GetRoutesByDriverIDOnDate(ctx context.Context, driverID int, day time.Time) ([]Route, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return queryDriverRoutes(ctx, `
+        WHERE r.driver_id = $1 AND r.scheduled_at >= $2 AND r.scheduled_at < $3
+            AND (cp.archived_at IS NULL OR cp.archived_at >= $2)
+    `, driverID, start, start.AddDate(0, 0, 1))
+}
+
+// routeWithPointColumns — колонки маршрута вместе с данными точки,
+// в порядке, ожидаемом scanRouteWithPoint.
+var routeWithPointColumns = `
+            r.id, r.driver_id, r.point_id, r.order_number,
+            r.scheduled_at, r.status, r.completed_at, r.comment, r.version,
+            cp.name, cp.address, cp.latitude, cp.longitude, cp.city, cp.container_count, ` + pointRestrictionColumns("cp.")
+
+// routeSheetColumns — то же, что routeWithPointColumns, с кодом ворот.
+var routeSheetColumns = `
             r.id, r.driver_id, r.point_id, r.order_number,
-            r.scheduled_at, r.status, r.completed_at, r.comment,
-            cp.name, cp.address, cp.latitude, cp.longitude, cp.city
+            r.scheduled_at, r.status, r.completed_at, r.comment, r.version,
+            cp.name, cp.address, cp.latitude, cp.longitude, cp.city, cp.container_count, ` + pointRestrictionColumnsWithGateCode("cp.")
+
+func scanRouteWithPoint(row pgx.Row) (Route, error) {
+	var r Route
+	var completedAt *time.Time
+	var comment *string
+	var cpName, cpAddress, cpCity string
+	var cpLat, cpLon float64
+	var cpContainers int
+	var restrictions PointRestrictions
+
+	err := row.Scan(append([]interface{}{
+		&r.ID, &r.DriverID, &r.PointID, &r.OrderNumber,
+		&r.ScheduledAt, &r.Status, &completedAt, &comment, &r.Version,
+		&cpName, &cpAddress, &cpLat, &cpLon, &cpCity, &cpContainers,
+	}, restrictions.scanTargets()...)...)
+	if err != nil {
+		return r, err
+	}
+
+	r.CompletedAt = completedAt
+	r.Comment = comment
+	r.Point = &CollectionPoint{
+		ID:                r.PointID,
+		Name:              cpName,
+		Address:           cpAddress,
+		Latitude:          cpLat,
+		Longitude:         cpLon,
+		City:              cpCity,
+		ContainerCount:    cpContainers,
+		PointRestrictions: restrictions,
+	}
+	return r, nil
+}
+
+// GetRouteByID возвращает остановку с данными точки.
+func GetRouteByID(ctx context.Context, id int) (*Route, error) {
+	r, err := scanRouteWithPoint(database.Pool.QueryRow(ctx, `
+        SELECT `+routeWithPointColumns+`
         FROM routes r
         JOIN collection_points cp ON r.point_id = cp.id
-        WHERE r.driver_id = $1
+        WHERE r.id = $1
+    `, id))
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func queryDriverRoutes(ctx context.Context, where string, args ...interface{}) ([]Route, error) {
+	rows, err := database.Pool.Query(ctx, `
+        SELECT `+routeSheetColumns+`
+        FROM routes r
+        JOIN collection_points cp ON r.point_id = cp.id
+        `+where+`
         ORDER BY r.order_number
-    `, driverID)
+    `, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -37,68 +134,134 @@ func GetRoutesByDriverID(ctx context.Context, driverID int) ([]Route, error) {
 
 	var routes []Route
 	for rows.Next() {
-		var r Route
-		var completedAt *time.Time
-		var comment *string
-		var cpName, cpAddress, cpCity string
-		var cpLat, cpLon float64
-
-		err := rows.Scan(
-			&r.ID, &r.DriverID, &r.PointID, &r.OrderNumber,
-			&r.ScheduledAt, &r.Status, &completedAt, &comment,
-			&cpName, &cpAddress, &cpLat, &cpLon, &cpCity,
-		)
+		r, err := scanRouteWithPoint(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		r.CompletedAt = completedAt
-		r.Comment = comment
-		r.Point = &CollectionPoint{
-			ID:        r.PointID,
-			Name:      cpName,
-			Address:   cpAddress,
-			Latitude:  cpLat,
-			Longitude: cpLon,
-			City:      cpCity,
-		}
-
 		routes = append(routes, r)
 	}
 
 	return routes, rows.Err()
 }
 
-func UpdateRouteStatus(ctx context.Context, routeID int, status string) error {
-query := `
-    UPDATE routes 
-    SET status = $1, 
-        completed_at = CASE WHEN $1 = 'completed' THEN CURRENT_TIMESTAMP ELSE completed_at END,
-        visited_at = CASE WHEN $1 IN ('in_progress', 'completed', 'problem') THEN COALESCE(visited_at, CURRENT_TIMESTAMP) ELSE visited_at END
-    WHERE id = $2
-`
-	log.Printf("UpdateRouteStatus: routeID=%d, status=%s, query=%s", routeID, status, query)
+// RouteFilter — фильтры списка маршрутов.
+type RouteFilter struct {
+	DriverID *int
+	PointID  *int
+	Statuses []string
+	From     *time.Time // scheduled_at >= From
+	To       *time.Time // scheduled_at < To
+	Archived string     // по архивности водителя или точки: ArchivedExclude, ArchivedInclude или ArchivedOnly
+}
 
-	result, err := database.Pool.Exec(ctx, query, status, routeID)
-	if err != nil {
-		log.Printf("UpdateRouteStatus Exec error: %v", err)
-		return err
+// routesFrom — таблицы списка маршрутов: остановка архивная, если
+// архивированы её точка или водитель.
+const routesFrom = `
+        FROM routes r
+        JOIN collection_points cp ON r.point_id = cp.id
+        JOIN drivers rd ON r.driver_id = rd.id`
+
+var RouteListSpec = listquery.Spec{
+	IDColumn: "r.id",
+	Sorts: map[string]listquery.SortField{
+		"id":           {Column: "r.id", Type: "int"},
+		"order_number": {Column: "r.order_number", Type: "int"},
+		"scheduled_at": {Column: "r.scheduled_at", Type: "timestamptz"},
+		"status":       {Column: "r.status", Type: "text"},
+	},
+	DefaultSort: "order_number",
+	MaxLimit:    1000,
+}
+
+func ListRoutes(ctx context.Context, f RouteFilter, p listquery.Params) (*listquery.Page, error) {
+	b := &listquery.Builder{}
+	whereArchived(b, "COALESCE(cp.archived_at, rd.archived_at)", f.Archived)
+	if f.DriverID != nil {
+		b.Where("r.driver_id = ?", *f.DriverID)
+	}
+	if f.PointID != nil {
+		b.Where("r.point_id = ?", *f.PointID)
+	}
+	if len(f.Statuses) > 0 {
+		b.Where("r.status = ANY(?)", f.Statuses)
+	}
+	if f.From != nil {
+		b.Where("r.scheduled_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		b.Where("r.scheduled_at < ?", *f.To)
 	}
 
-	log.Printf("UpdateRouteStatus result: RowsAffected=%d", result.RowsAffected())
+	var total int
+	if err := database.Pool.QueryRow(ctx, `SELECT COUNT(*)`+routesFrom+b.WhereSQL(), b.Args()...).Scan(&total); err != nil {
+		return nil, err
+	}
 
-	if result.RowsAffected() == 0 {
-		log.Printf("UpdateRouteStatus: No rows affected for routeID=%d", routeID)
+	p.ApplyCursor(b)
+	rows, err := database.Pool.Query(ctx, `
+        SELECT `+routeWithPointColumns+routesFrom+b.WhereSQL()+p.OrderSQL(), b.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	routes := []Route{}
+	for rows.Next() {
+		r, err := scanRouteWithPoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	n, next := p.Trim(len(routes), func(i int) (interface{}, int) {
+		switch p.Sort {
+		case "order_number":
+			return routes[i].OrderNumber, routes[i].ID
+		case "scheduled_at":
+			return routes[i].ScheduledAt, routes[i].ID
+		case "status":
+			return routes[i].Status, routes[i].ID
+		}
+		return routes[i].ID, routes[i].ID
+	})
+
+	return &listquery.Page{Items: routes[:n], NextCursor: next, Total: total}, nil
+}
+
+// UpdateRouteStatus меняет статус остановки и возвращает её новую версию.
+// Если передана ifVersion, а версия остановки другая, возвращается
+// ErrVersionMismatch.
+func UpdateRouteStatus(ctx context.Context, routeID int, status string, ifVersion *int) (int, error) {
+query := `
+    UPDATE routes 
+    SET status = $1, 
+        completed_at = CASE WHEN $3 = 'completed' THEN CURRENT_TIMESTAMP ELSE completed_at END,
+        visited_at = CASE WHEN $3 IN ('in_progress', 'completed', 'problem') THEN COALESCE(visited_at, CURRENT_TIMESTAMP) ELSE visited_at END
+    WHERE id = $2
+    RETURNING version
+`
+	var version int
+	err := database.InTx(ctx, func(tx pgx.Tx) error {
+		if err := checkVersion(ctx, tx, "routes", routeID, ifVersion); err != nil {
+			return err
+		}
+		// Статус передаётся дважды: в SET он приводится к VARCHAR, а в
+		// сравнениях CASE вывелся бы как text, и Postgres отказал бы
+		// с 42P08 (разные типы одного параметра)
+		return tx.QueryRow(ctx, query, status, routeID, status).Scan(&version)
+	})
+	return version, err
 }
 
 func GetRoutesByPointID(ctx context.Context, pointID int) ([]Route, error) {
 	rows, err := database.Pool.Query(ctx, `
         SELECT 
             r.id, r.driver_id, r.point_id, r.order_number,
-            r.scheduled_at, r.status, r.completed_at, r.comment
+            r.scheduled_at, r.status, r.completed_at, r.comment, r.version
         FROM routes r
         WHERE r.point_id = $1
         ORDER BY r.driver_id
@@ -113,7 +276,7 @@ func GetRoutesByPointID(ctx context.Context, pointID int) ([]Route, error) {
 		var r Route
 		err := rows.Scan(
 			&r.ID, &r.DriverID, &r.PointID, &r.OrderNumber,
-			&r.ScheduledAt, &r.Status, &r.CompletedAt, &r.Comment,
+			&r.ScheduledAt, &r.Status, &r.CompletedAt, &r.Comment, &r.Version,
 		)
 		if err != nil {
 			return nil, err
@@ -123,3 +286,43 @@ func GetRoutesByPointID(ctx context.Context, pointID int) ([]Route, error) {
 
 	return routes, rows.Err()
 }
+
+// DayStats — сводка остановок за сегодня для мониторинга.
+type DayStats struct {
+	Pending        int // остановки, к которым ещё не приступали
+	InProgress     int
+	Completed      int
+	DriversOnShift int // водители, начавшие маршрут и ещё не закончившие его
+}
+
+// GetTodayStats считает остановки текущего дня (по часовому поясу базы).
+// Водитель на смене — тот, у кого сегодня есть начатые остановки и ещё
+// остались незавершённые. Архивные водители не учитываются.
+func GetTodayStats(ctx context.Context) (*DayStats, error) {
+	var s DayStats
+	err := database.Pool.QueryRow(ctx, `
+		WITH today AS (
+			SELECT r.driver_id, r.status, r.visited_at
+			FROM routes r
+			JOIN drivers d ON d.id = r.driver_id AND d.archived_at IS NULL
+			WHERE r.scheduled_at >= CURRENT_DATE AND r.scheduled_at < CURRENT_DATE + 1
+		)
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'pending'),
+			COUNT(*) FILTER (WHERE status = 'in_progress'),
+			COUNT(*) FILTER (WHERE status = 'completed'),
+			(
+				SELECT COUNT(*) FROM (
+					SELECT driver_id FROM today
+					GROUP BY driver_id
+					HAVING bool_or(visited_at IS NOT NULL OR status <> 'pending')
+						AND bool_or(status IN ('pending', 'in_progress'))
+				) s
+			)
+		FROM today
+	`).Scan(&s.Pending, &s.InProgress, &s.Completed, &s.DriversOnShift)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
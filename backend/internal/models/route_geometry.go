@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"garbage_trucks/backend/internal/routing"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Форматы линии маршрута
+const (
+	GeometryFormatPolyline = "polyline" // Google Encoded Polyline, точность 5 знаков
+	GeometryFormatGeoJSON  = "geojson"  // GeoJSON LineString
+)
+
+// GeometryFormats — все допустимые форматы линии.
+var GeometryFormats = []string{GeometryFormatPolyline, GeometryFormatGeoJSON}
+
+// RouteGeometry — линия объезда остановок водителя за день по дорогам
+// и участки между соседними остановками. Линия — в одном поле по формату;
+// если остановок меньше двух, линии нет.
+type RouteGeometry struct {
+	DriverID        int                `json:"driver_id"`
+	Date            string             `json:"date"`
+	Format          string             `json:"format"`
+	Polyline        string             `json:"polyline,omitempty"`
+	Geometry        *GeoJSONLineString `json:"geometry,omitempty"`
+	Meters          int                `json:"meters"`
+	DurationSeconds int                `json:"duration_seconds"`
+	// Все участки построены по дорогам; false — дорожный граф не загружен
+	// или хотя бы один участок проложен по прямой
+	RoadNetwork bool       `json:"road_network"`
+	Legs        []RouteLeg `json:"legs"`
+}
+
+// RouteLeg — участок между соседними остановками.
+type RouteLeg struct {
+	FromRouteID     int `json:"from_route_id"`
+	ToRouteID       int `json:"to_route_id"`
+	FromPointID     int `json:"from_point_id"`
+	ToPointID       int `json:"to_point_id"`
+	Meters          int `json:"meters"`
+	DurationSeconds int `json:"duration_seconds"`
+	// false — пути по дорогам не нашлось (точка далеко от дорог или графа
+	// нет), участок оценён по прямой
+	Road bool `json:"road"`
+}
+
+// GeoJSONLineString — линия GeoJSON, координаты [долгота, широта].
+type GeoJSONLineString struct {
+	Type        string       `json:"type"`
+	Coordinates [][2]float64 `json:"coordinates"`
+}
+
+// Сколько линий водителей по дням помнит кэш; при переполнении он
+// очищается
+const geometryCacheSize = 1000
+
+// geometryStop — остановка в том виде, от которого зависит линия:
+// порядок, точка и её координаты.
+type geometryStop struct {
+	routeID  int
+	pointID  int
+	lat, lon float64
+}
+
+type geometryKey struct {
+	driverID int
+	day      string
+}
+
+type cachedGeometry struct {
+	stops  []geometryStop
+	line   []routing.Point
+	legs   []RouteLeg
+	meters float64
+	dur    time.Duration
+	road   bool
+}
+
+// Кэш линий: поиск путей по графу дороже запроса остановок, а карта
+// водителя перерисовывается часто. Линия строится заново, только когда
+// изменились остановки, их порядок или координаты точек.
+var geometryCache = struct {
+	sync.Mutex
+	entries map[geometryKey]*cachedGeometry
+}{entries: map[geometryKey]*cachedGeometry{}}
+
+// GetRouteGeometry строит линию объезда остановок водителя на день
+// в порядке order_number (как GetRoutesByDriverIDOnDate) в формате format.
+// Участки, для которых нет пути по дорогам, проводятся по прямой с
+// оценкой длины и времени, как в обзоре диспетчера.
+func GetRouteGeometry(ctx context.Context, driverID int, day time.Time, format string) (*RouteGeometry, error) {
+	routes, err := GetRoutesByDriverIDOnDate(ctx, driverID, day)
+	if err != nil {
+		return nil, err
+	}
+	stops := make([]geometryStop, len(routes))
+	for i, r := range routes {
+		stops[i] = geometryStop{routeID: r.ID, pointID: r.PointID, lat: r.Point.Latitude, lon: r.Point.Longitude}
+	}
+
+	key := geometryKey{driverID: driverID, day: day.Format("2006-01-02")}
+	geometryCache.Lock()
+	cached := geometryCache.entries[key]
+	geometryCache.Unlock()
+	if cached == nil || !slices.Equal(cached.stops, stops) {
+		cached = buildGeometry(stops)
+		geometryCache.Lock()
+		if len(geometryCache.entries) >= geometryCacheSize {
+			geometryCache.entries = map[geometryKey]*cachedGeometry{}
+		}
+		geometryCache.entries[key] = cached
+		geometryCache.Unlock()
+	}
+
+	g := &RouteGeometry{
+		DriverID:        driverID,
+		Date:            key.day,
+		Format:          format,
+		Meters:          int(math.Round(cached.meters)),
+		DurationSeconds: int(math.Round(cached.dur.Seconds())),
+		RoadNetwork:     cached.road,
+		Legs:            cached.legs,
+	}
+	if len(cached.line) >= 2 {
+		switch format {
+		case GeometryFormatGeoJSON:
+			g.Geometry = &GeoJSONLineString{Type: "LineString", Coordinates: make([][2]float64, len(cached.line))}
+			for i, p := range cached.line {
+				g.Geometry.Coordinates[i] = [2]float64{p.Longitude, p.Latitude}
+			}
+		default:
+			g.Polyline = routing.EncodePolyline(cached.line)
+		}
+	}
+	return g, nil
+}
+
+// buildGeometry прокладывает пути между соседними остановками. Линия
+// проходит через сами точки: путь по графу начинается и заканчивается
+// на ближайшей к точке дороге.
+func buildGeometry(stops []geometryStop) *cachedGeometry {
+	c := &cachedGeometry{stops: stops, legs: []RouteLeg{}, road: routing.Available()}
+	for i, s := range stops {
+		to := routing.Point{Latitude: s.lat, Longitude: s.lon}
+		if i == 0 {
+			c.line = append(c.line, to)
+			continue
+		}
+		prev := stops[i-1]
+		from := routing.Point{Latitude: prev.lat, Longitude: prev.lon}
+
+		leg := RouteLeg{FromRouteID: prev.routeID, ToRouteID: s.routeID, FromPointID: prev.pointID, ToPointID: s.pointID}
+		var meters float64
+		var d time.Duration
+		if path, err := routing.Route(from, to); err == nil {
+			meters, d, leg.Road = path.Meters, path.Duration, true
+			c.line = append(c.line, path.Geometry...)
+		} else {
+			meters = straightMeters(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+			d = straightTime(meters)
+			c.road = false
+		}
+		c.line = append(c.line, to)
+
+		leg.Meters, leg.DurationSeconds = int(math.Round(meters)), int(math.Round(d.Seconds()))
+		c.legs = append(c.legs, leg)
+		c.meters += meters
+		c.dur += d
+	}
+	return c
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"errors"
+	"garbage_trucks/backend/internal/database/dbtest"
+	"testing"
+	"time"
+)
+
+func TestUpdateRouteStatus(t *testing.T) {
+	ctx := dbtest.Open(t)
+	driverID := dbtest.Exec(t, ctx, `INSERT INTO drivers (name) VALUES ('Водитель') RETURNING id`)
+	pointID := dbtest.Exec(t, ctx, `INSERT INTO collection_points (name, latitude, longitude) VALUES ('Точка', 54.62, 39.74) RETURNING id`)
+	routeID := dbtest.Exec(t, ctx, `
+		INSERT INTO routes (driver_id, point_id, order_number, scheduled_at)
+		VALUES ($1, $2, 1, $3) RETURNING id`, driverID, pointID, time.Now())
+
+	route, err := GetRouteByID(ctx, routeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := UpdateRouteStatus(ctx, routeID, RouteStatusCompleted, &route.Version)
+	if err != nil {
+		t.Fatalf("UpdateRouteStatus: %v", err)
+	}
+	if version <= route.Version {
+		t.Errorf("версия %d не выросла после %d", version, route.Version)
+	}
+
+	updated, err := GetRouteByID(ctx, routeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status != RouteStatusCompleted || updated.CompletedAt == nil {
+		t.Errorf("статус %q, completed_at %v; ждали completed с отметкой времени", updated.Status, updated.CompletedAt)
+	}
+
+	// Устаревшая версия из If-Match
+	if _, err := UpdateRouteStatus(ctx, routeID, RouteStatusPending, &route.Version); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("со старой версией: %v, ждали ErrVersionMismatch", err)
+	}
+	// Без If-Match
+	if _, err := UpdateRouteStatus(ctx, routeID, RouteStatusProblem, nil); err != nil {
+		t.Errorf("без версии: %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Водители, точки и маршруты хранят версию строки (колонка version),
+// которую увеличивает триггер при каждом изменении. Клиент получает её
+// в ETag и передаёт в If-Match; изменение устаревшей версии отклоняется.
+
+// ErrVersionMismatch — запись изменилась после того, как клиент её прочитал.
+var ErrVersionMismatch = errors.New("record version mismatch")
+
+// checkVersion блокирует запись до конца транзакции и сверяет её версию
+// с ожидаемой. expected == nil — клиент версию не передал, проверка
+// пропускается.
+func checkVersion(ctx context.Context, tx pgx.Tx, table string, id int, expected *int) error {
+	var version int
+	err := tx.QueryRow(ctx, `SELECT version FROM `+table+` WHERE id = $1 FOR UPDATE`, id).Scan(&version)
+	if err != nil {
+		return err
+	}
+	if expected != nil && version != *expected {
+		return ErrVersionMismatch
+	}
+	return nil
+}
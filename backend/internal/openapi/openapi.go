@@ -0,0 +1,332 @@
+// Package openapi хранит спецификацию API (openapi.json), отдаёт её
+// клиентам и проверяет по ней входящие запросы.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"garbage_trucks/backend/internal/apierror"
+	"garbage_trucks/backend/internal/i18n"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gorilla/mux"
+)
+
+//go:embed openapi.json
+var specJSON []byte
+
+var doc *openapi3.T
+
+// Load разбирает и проверяет встроенную спецификацию. Вызывается при
+// старте: ошибка в документе — ошибка сборки, а не запроса.
+func Load() error {
+	loader := openapi3.NewLoader()
+	d, err := loader.LoadFromData(specJSON)
+	if err != nil {
+		return fmt.Errorf("openapi.json: %w", err)
+	}
+	if err := d.Validate(context.Background()); err != nil {
+		return fmt.Errorf("openapi.json: %w", err)
+	}
+	doc = d
+	return nil
+}
+
+// Handler отдаёт спецификацию как есть.
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(specJSON)
+}
+
+// Validate проверяет параметры и тело запроса по спецификации. Работает
+// как middleware mux: операция ищется по шаблону пути сработавшего
+// маршрута. Параметры запроса, которых нет в описании операции, — 400;
+// лишние поля тела отклоняются схемами с additionalProperties: false.
+// Маршруты без описания пропускаются — их отсутствие в документе
+// выявляет CheckRoutes при старте.
+func Validate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := findRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: mux.Vars(r),
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:         true,
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			apierror.Write(w, r, requestError(i18n.FromContext(r.Context()), err))
+			return
+		}
+		if details := unknownParams(i18n.FromContext(r.Context()), route, r); len(details) > 0 {
+			apierror.Write(w, r, apierror.BadRequest("request.invalid_params").WithDetails(details))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func findRoute(r *http.Request) *routers.Route {
+	if doc == nil {
+		return nil
+	}
+	current := mux.CurrentRoute(r)
+	if current == nil {
+		return nil
+	}
+	path, err := current.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+	item := doc.Paths.Find(path)
+	if item == nil {
+		return nil
+	}
+	op := item.GetOperation(r.Method)
+	if op == nil {
+		return nil
+	}
+	return &routers.Route{Spec: doc, Path: path, PathItem: item, Method: r.Method, Operation: op}
+}
+
+// unknownParams возвращает ошибки для параметров строки запроса, которых
+// нет ни в операции, ни в общем описании пути.
+func unknownParams(lang i18n.Lang, route *routers.Route, r *http.Request) []apierror.FieldError {
+	known := map[string]bool{}
+	for _, params := range []openapi3.Parameters{route.PathItem.Parameters, route.Operation.Parameters} {
+		for _, p := range params {
+			if p.Value != nil && p.Value.In == openapi3.ParameterInQuery {
+				known[p.Value.Name] = true
+			}
+		}
+	}
+
+	var details []apierror.FieldError
+	for name := range r.URL.Query() {
+		if !known[name] {
+			details = append(details, apierror.FieldError{
+				Field: name, In: "query", Code: apierror.FieldUnknown, Message: i18n.T(lang, "param.unknown"),
+			})
+		}
+	}
+	sort.Slice(details, func(i, j int) bool { return details[i].Field < details[j].Field })
+	return details
+}
+
+// requestError переводит ошибки kin-openapi в ошибку API: неверные
+// параметры — 400, тело, не соответствующее схеме, — 422. Подробности —
+// список apierror.FieldError в том же виде, что и у проверок в обработчиках.
+func requestError(lang i18n.Lang, err error) *apierror.Error {
+	// Тело не дочитано: его ограничивает middleware.BodyLimit
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apierror.TooLarge("request.too_large", tooLarge.Limit)
+	}
+
+	var errs []error
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		errs = multi
+	} else {
+		errs = []error{err}
+	}
+
+	var details []apierror.FieldError
+	bodyOnly := true
+	for _, e := range errs {
+		var reqErr *openapi3filter.RequestError
+		if !errors.As(e, &reqErr) {
+			return apierror.FromError(http.StatusBadRequest, apierror.CodeBadRequest, e)
+		}
+
+		switch {
+		case reqErr.Parameter != nil:
+			bodyOnly = false
+			fe := apierror.FieldError{Field: reqErr.Parameter.Name, In: reqErr.Parameter.In}
+			var se *openapi3.SchemaError
+			if errors.As(reqErr.Err, &se) {
+				fe.Code, fe.Message = describeSchemaError(lang, se)
+			} else if errors.Is(reqErr.Err, openapi3filter.ErrInvalidRequired) {
+				fe.Code, fe.Message = apierror.FieldRequired, i18n.T(lang, "field.required")
+			} else {
+				fe.Code, fe.Message = apierror.FieldInvalid, reason(reqErr)
+			}
+			details = append(details, fe)
+
+		case reqErr.RequestBody != nil:
+			var schemaErrs []*openapi3.SchemaError
+			collectSchemaErrors(reqErr.Err, &schemaErrs)
+			if len(schemaErrs) == 0 {
+				// Тело не разобрано как JSON или отсутствует
+				return apierror.BadRequest("error.invalid_body")
+			}
+			for _, se := range schemaErrs {
+				fe := apierror.FieldError{Field: fieldPath(se), In: "body"}
+				if name, ok := unsupportedProperty(se); ok {
+					if fe.Field != "" {
+						name = fe.Field + "." + name
+					}
+					fe.Field, fe.Code, fe.Message = name, apierror.FieldUnknown, i18n.T(lang, "field.unknown")
+				} else {
+					fe.Code, fe.Message = describeSchemaError(lang, se)
+				}
+				details = append(details, fe)
+			}
+
+		default:
+			bodyOnly = false
+			details = append(details, apierror.FieldError{In: "request", Code: apierror.FieldInvalid, Message: reason(reqErr)})
+		}
+	}
+
+	sort.SliceStable(details, func(i, j int) bool { return details[i].Field < details[j].Field })
+	if bodyOnly {
+		return apierror.Validation("validation.failed").WithDetails(details)
+	}
+	return apierror.BadRequest("request.invalid_params").WithDetails(details)
+}
+
+// unsupportedProperty достаёт имя лишнего поля объекта со схемой
+// additionalProperties: false. kin-openapi указывает в ошибке путь к самому
+// объекту, а имя поля — только в тексте.
+func unsupportedProperty(se *openapi3.SchemaError) (string, bool) {
+	if se.SchemaField != "properties" {
+		return "", false
+	}
+	var name string
+	if _, err := fmt.Sscanf(se.Reason, "property %q is unsupported", &name); err != nil {
+		return "", false
+	}
+	return name, true
+}
+
+// fieldPath переводит JSON Pointer в запись вида driver_ids[0].name.
+func fieldPath(se *openapi3.SchemaError) string {
+	var b strings.Builder
+	for _, part := range se.JSONPointer() {
+		if _, err := strconv.Atoi(part); err == nil {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// describeSchemaError подбирает код и локализованное сообщение по
+// нарушенному ключевому слову схемы; для прочих случаев остаётся текст
+// kin-openapi.
+func describeSchemaError(lang i18n.Lang, se *openapi3.SchemaError) (string, string) {
+	switch se.SchemaField {
+	case "required":
+		return apierror.FieldRequired, i18n.T(lang, "field.required")
+	case "maxLength":
+		if se.Schema != nil && se.Schema.MaxLength != nil {
+			return apierror.FieldTooLong, i18n.T(lang, "field.too_long", int(*se.Schema.MaxLength))
+		}
+		return apierror.FieldTooLong, se.Reason
+	case "minLength":
+		return apierror.FieldRequired, i18n.T(lang, "field.required")
+	case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum":
+		return apierror.FieldOutOfRange, se.Reason
+	case "uniqueItems":
+		return apierror.FieldDuplicate, i18n.T(lang, "field.duplicate_items")
+	case "enum":
+		if se.Schema != nil {
+			values := make([]string, len(se.Schema.Enum))
+			for i, v := range se.Schema.Enum {
+				values[i] = fmt.Sprint(v)
+			}
+			return apierror.FieldInvalid, i18n.T(lang, "field.one_of", strings.Join(values, ", "))
+		}
+	}
+	return apierror.FieldInvalid, se.Reason
+}
+
+func collectSchemaErrors(err error, out *[]*openapi3.SchemaError) {
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		for _, e := range multi {
+			collectSchemaErrors(e, out)
+		}
+		return
+	}
+	var se *openapi3.SchemaError
+	if errors.As(err, &se) {
+		*out = append(*out, se)
+	}
+}
+
+func reason(reqErr *openapi3filter.RequestError) string {
+	if reqErr.Err != nil {
+		return reqErr.Err.Error()
+	}
+	return reqErr.Reason
+}
+
+// CheckRoutes сверяет маршруты mux со спецификацией: каждый
+// зарегистрированный путь и метод должен быть описан, и каждая описанная
+// операция должна быть зарегистрирована. OPTIONS не учитывается.
+func CheckRoutes(r *mux.Router) error {
+	if doc == nil {
+		return errors.New("спецификация не загружена")
+	}
+
+	registered := map[string]bool{}
+	var problems []string
+	r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			problems = append(problems, path+": методы не указаны")
+			return nil
+		}
+		for _, m := range methods {
+			if m == http.MethodOptions {
+				continue
+			}
+			registered[m+" "+path] = true
+			item := doc.Paths.Find(path)
+			if item == nil || item.GetOperation(m) == nil {
+				problems = append(problems, m+" "+path+": нет в openapi.json")
+			}
+		}
+		return nil
+	})
+
+	for path, item := range doc.Paths.Map() {
+		for m := range item.Operations() {
+			if !registered[m+" "+path] {
+				problems = append(problems, m+" "+path+": описан, но не зарегистрирован")
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New("расхождение маршрутов и openapi.json:\n" + strings.Join(problems, "\n"))
+	}
+	return nil
+}
@@ -0,0 +1,580 @@
+// Package planning — планирование маршрутов всего города: задача
+// маршрутизации нескольких машин с ограничением вместимости (CVRP) и
+// длительности смены. Решается в памяти процесса за заданное время:
+// начальное решение строится разбиением точек на секторы вокруг базы,
+// затем улучшается локальным поиском с отжигом. У точек может быть окно
+// обслуживания: раньше начала машина ждёт, позже конца приезжать нельзя.
+package planning
+
+import (
+	"context"
+	"garbage_trucks/backend/internal/config"
+	"garbage_trucks/backend/internal/geo"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+var (
+	// База из DEPOT_LOCATION; nil — её нужно передать в запросе
+	defaultDepot *Point
+	// Наибольшее время на поиск плана
+	maxBudget = 10 * time.Second
+)
+
+// Init запоминает базу и ограничение времени планирования.
+func Init(cfg *config.Config) {
+	if cfg.Depot != nil {
+		defaultDepot = &Point{Latitude: cfg.Depot.Latitude, Longitude: cfg.Depot.Longitude}
+	}
+	maxBudget = cfg.PlannerTimeBudget
+}
+
+// DefaultDepot возвращает базу из конфигурации.
+func DefaultDepot() (Point, bool) {
+	if defaultDepot == nil {
+		return Point{}, false
+	}
+	return *defaultDepot, true
+}
+
+// MaxBudget — наибольшее время на поиск плана.
+func MaxBudget() time.Duration {
+	return maxBudget
+}
+
+// Point — координаты в градусах WGS84.
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Stop — точка, которую нужно объехать; Demand — сколько вместимости
+// машины она занимает (например, контейнеров). Open и Close — окно, в
+// которое нужно начать обслуживание; нулевые — без ограничения.
+type Stop struct {
+	ID     int
+	Point  Point
+	Demand int
+	Open   time.Time
+	Close  time.Time
+}
+
+// Vehicle — машина с водителем: вместимость и смена, за которую она
+// должна выехать с базы, объехать свои точки и вернуться.
+type Vehicle struct {
+	ID         int
+	Capacity   int
+	ShiftStart time.Time
+	ShiftEnd   time.Time
+}
+
+// Problem — задача планирования.
+type Problem struct {
+	Depot       Point
+	Stops       []Stop
+	Vehicles    []Vehicle
+	ServiceTime time.Duration // на каждой точке
+	SpeedKmh    float64       // средняя скорость
+	// Distance — расстояние в метрах между точками; nil — по прямой
+	Distance func(a, b Point) float64
+	// Готовые матрицы расстояний в метрах и времени в пути в секундах,
+	// например по дорожному графу: узел 0 — база, узел i — Stops[i-1].
+	// Если заданы, Distance и SpeedKmh для пути не используются
+	Meters  [][]float64
+	Seconds [][]float64
+}
+
+// PlannedStop — точка в маршруте машины. Arrival — начало обслуживания,
+// с учётом ожидания открытия окна.
+type PlannedStop struct {
+	StopID  int
+	Arrival time.Time
+	Meters  float64 // от предыдущей точки или базы
+}
+
+// Route — маршрут машины: с базы по точкам и обратно.
+type Route struct {
+	VehicleID int
+	Stops     []PlannedStop
+	Load      int
+	Meters    float64 // с возвратом на базу
+	Duration  time.Duration
+	ReturnAt  time.Time
+}
+
+// Solution — лучший найденный план.
+type Solution struct {
+	Routes     []Route // по машине на каждую из Problem.Vehicles, в том же порядке
+	Unassigned []int   // точки, которые не влезли ни в одну машину
+	Meters     float64
+	Iterations int
+}
+
+// Вес выравнивания нагрузки: к метрам прибавляется сумма квадратов
+// длительностей маршрутов в часах с этим множителем. При одинаковом пути
+// выгоднее ровные маршруты: перенос часа с 7-часового маршрута на
+// 5-часовой экономит 2 км.
+const balanceWeight = 1000.0
+
+// Штраф за точку вне плана — больше любого реального маршрута
+const unassignedPenalty = 1e7
+
+// solver — состояние поиска. Узел 0 — база, точка i — узел i+1.
+type solver struct {
+	p       *Problem
+	dist    [][]float64
+	dur     [][]float64 // время в пути между узлами в секундах
+	routes  [][]int     // индексы точек по машинам
+	costs   []float64
+	free    []int // точки вне плана
+	total   float64
+	rnd     *rand.Rand
+	shift   []float64 // длительность смены в секундах
+	service float64
+	// Окна точек в секундах от начала смены каждой машины: opens[v][i],
+	// closes[v][i]; nil — ни у одной точки окна нет
+	opens, closes [][]float64
+	// Температура отжига: ухудшение на столько метров принимается
+	// с вероятностью 1/e
+	temperature float64
+}
+
+// Solve ищет план, пока не истечёт budget или контекст. Результат
+// детерминирован при одинаковых входных данных и числе итераций.
+func Solve(ctx context.Context, p *Problem, budget time.Duration) *Solution {
+	deadline := time.Now().Add(budget)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	s := newSolver(p)
+	s.sweep()
+	s.total = s.cost()
+
+	best := s.snapshot()
+	bestCost := s.total
+	// Начальная температура — небольшая доля стоимости начального
+	// решения, к концу бюджета опускается почти до нуля
+	t0 := math.Max(s.routeMeters()*0.002, 1)
+	start := time.Now()
+	iterations := 0
+	for ; len(s.routes) > 0 && len(p.Stops) > 0; iterations++ {
+		if iterations%256 == 0 {
+			now := time.Now()
+			if !now.Before(deadline) || ctx.Err() != nil {
+				break
+			}
+			left := float64(deadline.Sub(now)) / float64(deadline.Sub(start))
+			s.temperature = t0 * left * left
+		}
+		s.step()
+		if s.total < bestCost-1e-6 {
+			best, bestCost = s.snapshot(), s.total
+		}
+	}
+
+	s.restore(best)
+	return s.solution(iterations)
+}
+
+func newSolver(p *Problem) *solver {
+	distance := p.Distance
+	if distance == nil {
+		distance = func(a, b Point) float64 {
+			return geo.Distance(a.Latitude, a.Longitude, b.Latitude, b.Longitude)
+		}
+	}
+
+	nodes := make([]Point, len(p.Stops)+1)
+	nodes[0] = p.Depot
+	for i, st := range p.Stops {
+		nodes[i+1] = st.Point
+	}
+	dist, dur := p.Meters, p.Seconds
+	if dist == nil {
+		dist = make([][]float64, len(nodes))
+		for i := range nodes {
+			dist[i] = make([]float64, len(nodes))
+			for j := range nodes {
+				if i != j {
+					dist[i][j] = distance(nodes[i], nodes[j])
+				}
+			}
+		}
+	}
+	if dur == nil {
+		speed := p.SpeedKmh * 1000 / 3600
+		dur = make([][]float64, len(nodes))
+		for i := range nodes {
+			dur[i] = make([]float64, len(nodes))
+			for j := range nodes {
+				dur[i][j] = dist[i][j] / speed
+			}
+		}
+	}
+
+	s := &solver{
+		p:       p,
+		dist:    dist,
+		dur:     dur,
+		routes:  make([][]int, len(p.Vehicles)),
+		costs:   make([]float64, len(p.Vehicles)),
+		rnd:     rand.New(rand.NewSource(1)),
+		shift:   make([]float64, len(p.Vehicles)),
+		service: p.ServiceTime.Seconds(),
+	}
+	for i, v := range p.Vehicles {
+		s.shift[i] = v.ShiftEnd.Sub(v.ShiftStart).Seconds()
+	}
+	for _, st := range p.Stops {
+		if !st.Close.IsZero() {
+			s.windows()
+			break
+		}
+	}
+	return s
+}
+
+// windows переводит окна точек в секунды от начала смены каждой машины.
+func (s *solver) windows() {
+	s.opens = make([][]float64, len(s.p.Vehicles))
+	s.closes = make([][]float64, len(s.p.Vehicles))
+	for v, veh := range s.p.Vehicles {
+		s.opens[v] = make([]float64, len(s.p.Stops))
+		s.closes[v] = make([]float64, len(s.p.Stops))
+		for i, st := range s.p.Stops {
+			s.opens[v][i], s.closes[v][i] = math.Inf(-1), math.Inf(1)
+			if !st.Close.IsZero() {
+				s.opens[v][i] = st.Open.Sub(veh.ShiftStart).Seconds()
+				s.closes[v][i] = st.Close.Sub(veh.ShiftStart).Seconds()
+			}
+		}
+	}
+}
+
+// meters — длина маршрута с базы и обратно.
+func (s *solver) meters(route []int) float64 {
+	if len(route) == 0 {
+		return 0
+	}
+	m := s.dist[0][route[0]+1] + s.dist[route[len(route)-1]+1][0]
+	for i := 1; i < len(route); i++ {
+		m += s.dist[route[i-1]+1][route[i]+1]
+	}
+	return m
+}
+
+// seconds — длительность маршрута машины v с ожиданием открытия окон;
+// false — к какой-то точке машина приезжает после конца её окна.
+func (s *solver) seconds(v int, route []int) (float64, bool) {
+	at, prev := 0.0, 0
+	for _, i := range route {
+		at += s.dur[prev][i+1]
+		if s.opens != nil {
+			at = math.Max(at, s.opens[v][i])
+			if at > s.closes[v][i] {
+				return 0, false
+			}
+		}
+		at += s.service
+		prev = i + 1
+	}
+	if len(route) > 0 {
+		at += s.dur[prev][0]
+	}
+	return at, true
+}
+
+func (s *solver) load(route []int) int {
+	l := 0
+	for _, i := range route {
+		l += s.p.Stops[i].Demand
+	}
+	return l
+}
+
+// routeCost — стоимость маршрута машины v или +Inf, если он не влезает
+// по вместимости, не успевает за смену или опаздывает к окну точки.
+func (s *solver) routeCost(v int, route []int) float64 {
+	if s.load(route) > s.p.Vehicles[v].Capacity {
+		return math.Inf(1)
+	}
+	m := s.meters(route)
+	sec, ok := s.seconds(v, route)
+	if !ok || sec > s.shift[v] {
+		return math.Inf(1)
+	}
+	h := sec / 3600
+	return m + balanceWeight*h*h
+}
+
+func (s *solver) cost() float64 {
+	total := unassignedPenalty * float64(len(s.free))
+	for v, r := range s.routes {
+		s.costs[v] = s.routeCost(v, r)
+		total += s.costs[v]
+	}
+	return total
+}
+
+func (s *solver) routeMeters() float64 {
+	m := 0.0
+	for _, r := range s.routes {
+		m += s.meters(r)
+	}
+	return m
+}
+
+// sweep строит начальное решение: точки сортируются по углу вокруг базы
+// и раздаются машинам подряд, каждой — примерно поровну от общего
+// объёма, но не больше её вместимости и смены. Получаются компактные
+// секторы, как при ручной нарезке территорий. Не поместившиеся точки
+// пробуются вставить куда угодно, остальные остаются вне плана.
+func (s *solver) sweep() {
+	order := make([]int, len(s.p.Stops))
+	angle := make([]float64, len(s.p.Stops))
+	demand := 0
+	for i, st := range s.p.Stops {
+		order[i] = i
+		angle[i] = math.Atan2(st.Point.Latitude-s.p.Depot.Latitude, st.Point.Longitude-s.p.Depot.Longitude)
+		demand += st.Demand
+	}
+	sort.SliceStable(order, func(a, b int) bool { return angle[order[a]] < angle[order[b]] })
+
+	if len(s.p.Vehicles) == 0 {
+		s.free = order
+		return
+	}
+
+	capacity := 0
+	for _, v := range s.p.Vehicles {
+		capacity += v.Capacity
+	}
+	v, share := 0, 0
+	var rest []int
+	for _, i := range order {
+		for v < len(s.p.Vehicles) {
+			target := float64(demand) * float64(s.p.Vehicles[v].Capacity) / math.Max(float64(capacity), 1)
+			candidate := append(append([]int(nil), s.routes[v]...), i)
+			if float64(share) < target && !math.IsInf(s.routeCost(v, candidate), 1) {
+				break
+			}
+			v, share = v+1, 0
+		}
+		if v == len(s.p.Vehicles) {
+			rest = append(rest, i)
+			continue
+		}
+		s.routes[v] = append(s.routes[v], i)
+		share += s.p.Stops[i].Demand
+	}
+
+	for v := range s.routes {
+		s.routes[v] = s.twoOpt(v, s.routes[v])
+	}
+	for _, i := range rest {
+		if !s.insertCheapest(i) {
+			s.free = append(s.free, i)
+		}
+	}
+}
+
+// twoOpt улучшает порядок маршрута машины v разворотами отрезков, пока
+// это сокращает путь. Вместимость от разворота не меняется, поэтому
+// допустимость проверяется, только если у точек есть окна.
+func (s *solver) twoOpt(v int, route []int) []int {
+	node := func(k int) int {
+		if k < 0 || k >= len(route) {
+			return 0
+		}
+		return route[k] + 1
+	}
+	for improved := true; improved; {
+		improved = false
+		for i := 0; i < len(route)-1; i++ {
+			for j := i + 1; j < len(route); j++ {
+				a, b, c, d := node(i-1), node(i), node(j), node(j+1)
+				if s.dist[a][c]+s.dist[b][d] >= s.dist[a][b]+s.dist[c][d]-1e-6 {
+					continue
+				}
+				candidate := reversed(route, i, j)
+				if s.opens != nil && math.IsInf(s.routeCost(v, candidate), 1) {
+					continue
+				}
+				route, improved = candidate, true
+			}
+		}
+	}
+	return route
+}
+
+// insertCheapest вставляет точку туда, где она меньше всего увеличит
+// стоимость плана; false — ни одна машина её не вмещает.
+func (s *solver) insertCheapest(i int) bool {
+	bestV, bestPos, bestDelta := -1, 0, math.Inf(1)
+	for v, r := range s.routes {
+		before := s.routeCost(v, r)
+		for pos := 0; pos <= len(r); pos++ {
+			delta := s.routeCost(v, inserted(r, pos, i)) - before
+			if delta < bestDelta {
+				bestV, bestPos, bestDelta = v, pos, delta
+			}
+		}
+	}
+	if bestV < 0 || math.IsInf(bestDelta, 1) {
+		return false
+	}
+	s.routes[bestV] = inserted(s.routes[bestV], bestPos, i)
+	return true
+}
+
+// step делает один случайный ход: перенос точки, обмен точек между
+// машинами, разворот отрезка маршрута или вставку точки вне плана.
+// Ход принимается, если улучшает план, а ухудшающий — с вероятностью,
+// убывающей с температурой.
+func (s *solver) step() {
+	if len(s.free) > 0 && s.rnd.Intn(8) == 0 {
+		k := s.rnd.Intn(len(s.free))
+		if s.insertCheapest(s.free[k]) {
+			s.free = append(s.free[:k], s.free[k+1:]...)
+			s.total = s.cost()
+		}
+		return
+	}
+
+	a := s.rnd.Intn(len(s.routes))
+	if len(s.routes[a]) == 0 {
+		return
+	}
+	b := s.rnd.Intn(len(s.routes))
+	ra, rb := s.routes[a], s.routes[b]
+
+	var na, nb []int
+	switch s.rnd.Intn(3) {
+	case 0: // перенос
+		i := s.rnd.Intn(len(ra))
+		stop := ra[i]
+		na = removed(ra, i)
+		if a == b {
+			nb = inserted(na, s.rnd.Intn(len(na)+1), stop)
+			na = nb
+		} else {
+			nb = inserted(rb, s.rnd.Intn(len(rb)+1), stop)
+		}
+	case 1: // обмен
+		if a == b || len(rb) == 0 {
+			return
+		}
+		i, j := s.rnd.Intn(len(ra)), s.rnd.Intn(len(rb))
+		na, nb = append([]int(nil), ra...), append([]int(nil), rb...)
+		na[i], nb[j] = rb[j], ra[i]
+	default: // разворот отрезка
+		if len(ra) < 3 {
+			return
+		}
+		i := s.rnd.Intn(len(ra) - 1)
+		j := i + 1 + s.rnd.Intn(len(ra)-i-1)
+		na, b, nb = reversed(ra, i, j), a, nil
+	}
+
+	ca := s.routeCost(a, na)
+	delta := ca - s.costs[a]
+	var cb float64
+	if b != a {
+		cb = s.routeCost(b, nb)
+		delta += cb - s.costs[b]
+	}
+	if math.IsInf(delta, 1) || math.IsNaN(delta) {
+		return
+	}
+	if delta > 0 && (s.temperature <= 0 || s.rnd.Float64() >= math.Exp(-delta/s.temperature)) {
+		return
+	}
+
+	s.routes[a], s.costs[a] = na, ca
+	if b != a {
+		s.routes[b], s.costs[b] = nb, cb
+	}
+	s.total += delta
+}
+
+type snapshot struct {
+	routes [][]int
+	free   []int
+}
+
+func (s *solver) snapshot() snapshot {
+	sn := snapshot{routes: make([][]int, len(s.routes)), free: append([]int(nil), s.free...)}
+	for v, r := range s.routes {
+		sn.routes[v] = append([]int(nil), r...)
+	}
+	return sn
+}
+
+func (s *solver) restore(sn snapshot) {
+	s.routes, s.free = sn.routes, sn.free
+	s.total = s.cost()
+}
+
+// solution переводит маршруты в план со временем прибытия на точки.
+func (s *solver) solution(iterations int) *Solution {
+	sol := &Solution{Routes: make([]Route, len(s.routes)), Iterations: iterations}
+	for v, r := range s.routes {
+		veh := s.p.Vehicles[v]
+		route := Route{VehicleID: veh.ID, Stops: make([]PlannedStop, len(r)), Load: s.load(r)}
+		at, prev := veh.ShiftStart, 0
+		for k, i := range r {
+			m := s.dist[prev][i+1]
+			at = at.Add(s.travel(prev, i+1))
+			if open := s.p.Stops[i].Open; at.Before(open) {
+				at = open
+			}
+			route.Stops[k] = PlannedStop{StopID: s.p.Stops[i].ID, Arrival: at, Meters: m}
+			at = at.Add(s.p.ServiceTime)
+			prev = i + 1
+			route.Meters += m
+		}
+		if len(r) > 0 {
+			back := s.dist[prev][0]
+			at = at.Add(s.travel(prev, 0))
+			route.Meters += back
+		}
+		route.ReturnAt = at
+		route.Duration = at.Sub(veh.ShiftStart)
+		sol.Routes[v] = route
+		sol.Meters += route.Meters
+	}
+	for _, i := range s.free {
+		sol.Unassigned = append(sol.Unassigned, s.p.Stops[i].ID)
+	}
+	sort.Ints(sol.Unassigned)
+	return sol
+}
+
+func (s *solver) travel(from, to int) time.Duration {
+	return time.Duration(s.dur[from][to] * float64(time.Second))
+}
+
+func inserted(r []int, pos, v int) []int {
+	out := make([]int, 0, len(r)+1)
+	out = append(out, r[:pos]...)
+	out = append(out, v)
+	return append(out, r[pos:]...)
+}
+
+func removed(r []int, pos int) []int {
+	out := make([]int, 0, len(r)-1)
+	out = append(out, r[:pos]...)
+	return append(out, r[pos+1:]...)
+}
+
+// reversed возвращает копию маршрута с развёрнутым отрезком [i, j].
+func reversed(r []int, i, j int) []int {
+	out := append([]int(nil), r...)
+	for ; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+// Package requestid хранит идентификатор запроса в контексте.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Header — заголовок, в котором идентификатор передаётся и возвращается.
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// New генерирует случайный идентификатор из 16 hex-символов.
+func New() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// WithID возвращает контекст с идентификатором запроса.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext возвращает идентификатор запроса или пустую строку.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
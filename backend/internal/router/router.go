@@ -3,41 +3,100 @@ package router
 import (
 	"net/http"
 	"github.com/gorilla/mux"
+	"garbage_trucks/backend/internal/config"
 	"garbage_trucks/backend/internal/handlers"
+	"garbage_trucks/backend/internal/metrics"
 	"garbage_trucks/backend/internal/middleware"
+	"garbage_trucks/backend/internal/openapi"
 )
 
 func NewRouter() *mux.Router {
 	r := mux.NewRouter()
 
 	// Middleware
-	r.Use(middleware.CORS)
+	r.Use(middleware.RequestID)
+	r.Use(middleware.AccessLog)
+	r.Use(middleware.Metrics)
+	r.Use(middleware.Timeout)
+	r.Use(middleware.Actor)
+	r.Use(middleware.Language)
+	cors := middleware.CORS(r)
+	r.Use(cors)
+	// Ограничение тела до проверки: openapi.Validate читает его целиком
+	r.Use(middleware.BodyLimit(middleware.DefaultMaxBodySize, map[string]int64{
+		"/api/districts/import": handlers.MaxGeoJSONSize,
+	}))
+	// Проверка параметров и тела по openapi.json
+	r.Use(openapi.Validate)
+
+	// Ошибки маршрутизации тоже отдаём в JSON; middleware mux к ним
+	// не применяется, поэтому оборачиваем явно. Preflight-запросы OPTIONS
+	// попадают в MethodNotAllowedHandler и завершаются в CORS middleware,
+	// которое берёт список методов пути из этого же роутера
+	r.NotFoundHandler = middleware.RequestID(middleware.AccessLog(middleware.Metrics(middleware.Language(cors(http.HandlerFunc(handlers.NotFoundHandler))))))
+	r.MethodNotAllowedHandler = middleware.RequestID(middleware.AccessLog(middleware.Metrics(middleware.Language(cors(http.HandlerFunc(handlers.MethodNotAllowedHandler))))))
 
 	// API Routes
 	// Health check
 	r.HandleFunc("/api/health", handlers.HealthHandler).Methods("GET")
+	r.HandleFunc("/api/openapi.json", openapi.Handler).Methods("GET")
+
+	// Проверки для оркестратора и метрики Prometheus
+	r.HandleFunc("/healthz", handlers.LivenessHandler).Methods("GET")
+	r.HandleFunc("/readyz", handlers.ReadinessHandler).Methods("GET")
+	r.Handle("/metrics", middleware.Feature(config.FeatureMetrics, metrics.Handler())).Methods("GET")
 	
 	// Drivers
 	r.HandleFunc("/api/drivers", handlers.GetDriversHandler).Methods("GET")
 	r.HandleFunc("/api/drivers", handlers.CreateDriverHandler).Methods("POST")
+	r.HandleFunc("/api/drivers/{id}", handlers.GetDriverHandler).Methods("GET")
 	r.HandleFunc("/api/drivers/{id}", handlers.UpdateDriverHandler).Methods("PUT")
 	r.HandleFunc("/api/drivers/{id}", handlers.DeleteDriverHandler).Methods("DELETE")
+	r.HandleFunc("/api/drivers/{id}/restore", handlers.RestoreDriverHandler).Methods("POST")
+	r.HandleFunc("/api/drivers/{id}/position", handlers.RecordDriverPositionHandler).Methods("POST")
+	r.HandleFunc("/api/drivers/{id}/reroute", handlers.ProposeRerouteHandler).Methods("GET")
+	r.HandleFunc("/api/drivers/{id}/reroute", handlers.ApplyRerouteHandler).Methods("POST")
+	r.HandleFunc("/api/drivers/{id}/routes", handlers.GetDriverRoutesOnDateHandler).Methods("GET")
+	r.Handle("/api/drivers/{id}/routesheet.pdf", middleware.Feature(config.FeatureRouteSheets, http.HandlerFunc(handlers.GetRouteSheetHandler))).Methods("GET")
 	
 	// Points
 	r.HandleFunc("/api/points", handlers.GetPointsHandler).Methods("GET")
 	r.HandleFunc("/api/points", handlers.CreatePointHandler).Methods("POST")
+	r.HandleFunc("/api/points/nearby", handlers.GetNearbyPointsHandler).Methods("GET")
+	r.HandleFunc("/api/points/{id}", handlers.GetPointHandler).Methods("GET")
 	r.HandleFunc("/api/points/{id}", handlers.UpdatePointHandler).Methods("PUT")
 	r.HandleFunc("/api/points/{id}", handlers.DeletePointHandler).Methods("DELETE")
+	r.HandleFunc("/api/points/{id}/restore", handlers.RestorePointHandler).Methods("POST")
 	
 	// Routes
 	r.HandleFunc("/api/routes", handlers.GetRoutesHandler).Methods("GET")
-	r.HandleFunc("/api/routes/status", handlers.UpdateRouteStatusHandler).Methods("POST", "PATCH")
-	
-	// ВАЖНО: Явно обрабатываем OPTIONS для всех маршрутов
-	r.Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Пустой обработчик, CORS middleware добавит заголовки
-		w.WriteHeader(http.StatusOK)
-	})
+	r.HandleFunc("/api/routes/status", handlers.UpdateRouteStatusHandler).Methods("POST")
+	r.HandleFunc("/api/routes/geometry", handlers.GetRouteGeometryHandler).Methods("GET")
+	r.HandleFunc("/api/routes/{id}", handlers.GetRouteHandler).Methods("GET")
+
+	// Districts: импорт и удаление — по токену администратора
+	r.HandleFunc("/api/districts", handlers.GetDistrictsHandler).Methods("GET")
+	r.Handle("/api/districts/import", middleware.Admin(http.HandlerFunc(handlers.ImportDistrictsHandler))).Methods("POST")
+	r.Handle("/api/districts/{id}", middleware.Admin(http.HandlerFunc(handlers.DeleteDistrictHandler))).Methods("DELETE")
+	r.HandleFunc("/api/districts/{id}/points", handlers.GetDistrictPointsHandler).Methods("GET")
+
+	// Geocoding
+	r.HandleFunc("/api/geocode", handlers.GeocodeHandler).Methods("GET")
+	r.HandleFunc("/api/geocode/reverse", handlers.ReverseGeocodeHandler).Methods("GET")
+
+	// Reports
+	r.HandleFunc("/api/reports/completion", handlers.GetCompletionReportHandler).Methods("GET")
+
+	// Обзор диспетчера
+	r.HandleFunc("/api/dispatch/overview", handlers.GetDispatchOverviewHandler).Methods("GET")
+	r.HandleFunc("/api/dispatch/plan", handlers.PlanCityHandler).Methods("POST")
+
+	// Audit
+	r.HandleFunc("/api/audit", handlers.GetAuditHandler).Methods("GET")
+
+	// Admin: окончательное удаление архивных записей по токену администратора
+	r.Handle("/api/admin/drivers/{id}", middleware.Admin(http.HandlerFunc(handlers.PurgeDriverHandler))).Methods("DELETE")
+	r.Handle("/api/admin/points/{id}", middleware.Admin(http.HandlerFunc(handlers.PurgePointHandler))).Methods("DELETE")
 
 	return r
 }
\ No newline at end of file
@@ -0,0 +1,142 @@
+package router
+
+import (
+	"encoding/json"
+	"garbage_trucks/backend/internal/middleware"
+	"garbage_trucks/backend/internal/openapi"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func loadSpec(t *testing.T) {
+	t.Helper()
+	if err := openapi.Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Каждый зарегистрированный маршрут описан в openapi.json, и каждая
+// описанная операция зарегистрирована.
+func TestRoutesDocumented(t *testing.T) {
+	loadSpec(t)
+	if err := openapi.CheckRoutes(NewRouter()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Запросы с тем, чего нет в спецификации, отклоняются до обработчика
+// (которому понадобилась бы база).
+func TestValidateRejectsUndocumented(t *testing.T) {
+	loadSpec(t)
+	r := NewRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		field  string
+		in     string
+	}{
+		{"лишний параметр", "GET", "/api/drivers?limit=10&colour=red", "", http.StatusBadRequest, "colour", "query"},
+		{"лишнее поле тела", "POST", "/api/drivers", `{"name": "Водитель", "salary": 100}`, http.StatusUnprocessableEntity, "salary", "body"},
+		{"лишнее вложенное поле", "POST", "/api/dispatch/plan",
+			`{"vehicles": [{"driver_id": 1, "capacity": 10, "shift_start": "08:00", "shift_end": "17:00", "colour": "red"}]}`,
+			http.StatusUnprocessableEntity, "vehicles[0].colour", "body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("статус %d, ждали %d: %s", w.Code, tt.status, w.Body)
+			}
+			var resp struct {
+				Details []struct {
+					Field string `json:"field"`
+					In    string `json:"in"`
+					Code  string `json:"code"`
+				} `json:"details"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if len(resp.Details) != 1 || resp.Details[0].Field != tt.field || resp.Details[0].In != tt.in || resp.Details[0].Code != "unknown" {
+				t.Errorf("details %+v, ждали поле %s (%s) с кодом unknown", resp.Details, tt.field, tt.in)
+			}
+		})
+	}
+}
+
+// Документированный запрос проходит проверку.
+func TestValidateAcceptsDocumented(t *testing.T) {
+	loadSpec(t)
+	w := httptest.NewRecorder()
+	NewRouter().ServeHTTP(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("статус %d: %s", w.Code, w.Body)
+	}
+}
+
+// Импорт и удаление районов доступны только администратору: без
+// ADMIN_TOKEN запрос отклоняется до обработчика.
+func TestDistrictWritesRequireAdmin(t *testing.T) {
+	loadSpec(t)
+	r := NewRouter()
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("POST", "/api/districts/import", strings.NewReader(`{"type": "FeatureCollection", "features": []}`)),
+		httptest.NewRequest("DELETE", "/api/districts/1", nil),
+	} {
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s %s: статус %d, ждали %d: %s", req.Method, req.URL, w.Code, http.StatusForbidden, w.Body)
+		}
+	}
+}
+
+// Тело больше ограничения отклоняется с 413 до проверки по спецификации,
+// в том числе без Content-Length; у импорта районов ограничение больше.
+func TestBodyLimit(t *testing.T) {
+	loadSpec(t)
+	r := NewRouter()
+
+	oversized := `{"name": "` + strings.Repeat("x", middleware.DefaultMaxBodySize) + `"}`
+	geoJSON := `{"type": "FeatureCollection", "features": [], "pad": "` + strings.Repeat("x", 2*middleware.DefaultMaxBodySize) + `"}`
+	tests := []struct {
+		name          string
+		target        string
+		body          string
+		unknownLength bool
+		status        int
+	}{
+		{"с Content-Length", "/api/drivers", oversized, false, http.StatusRequestEntityTooLarge},
+		{"без Content-Length", "/api/drivers", oversized, true, http.StatusRequestEntityTooLarge},
+		// Проходит ограничение и проверку, отклоняется уже Admin
+		{"импорт районов", "/api/districts/import", geoJSON, true, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Errorf("статус %d, ждали %d: %.200s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
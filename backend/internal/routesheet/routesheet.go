@@ -0,0 +1,304 @@
+// Package routesheet формирует печатный маршрутный лист водителя в PDF.
+package routesheet
+
+import (
+	"fmt"
+	"garbage_trucks/backend/internal/config"
+	"garbage_trucks/backend/internal/i18n"
+	"garbage_trucks/backend/internal/models"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+const fontFamily = "DejaVu"
+
+var (
+	fontPath string
+	fontData []byte
+)
+
+// Init загружает TTF-шрифт с кириллицей. Отсутствие файла не мешает
+// запуску сервера, но маршрутные листы формироваться не будут. При
+// выключенном FEATURE_ROUTESHEETS шрифт не загружается.
+func Init(cfg *config.Config) {
+	fontPath = cfg.RouteSheetFont
+	if !cfg.Features.Enabled(config.FeatureRouteSheets) {
+		return
+	}
+	data, err := os.ReadFile(fontPath)
+	if err != nil {
+		slog.Warn("Шрифт для маршрутных листов недоступен", "path", fontPath, "err", err)
+		return
+	}
+	fontData = data
+}
+
+// Размеры страницы A4 и полей, мм
+const (
+	pageWidth    = 210.0
+	pageHeight   = 297.0
+	margin       = 12.0
+	rowHeight    = 8.0
+	accessHeight = 6.0 // строка с окном, кодом ворот и заметками под остановкой
+	headerHeight = 9.0
+	mapHeight    = 110.0
+)
+
+// column — колонка таблицы; title — ключ заголовка в каталоге i18n.
+type column struct {
+	title string
+	width float64
+	align string
+}
+
+var columns = []column{
+	{"routesheet.col.number", 10, "C"},
+	{"routesheet.col.time", 16, "C"},
+	{"routesheet.col.point", 48, "L"},
+	{"routesheet.col.address", 68, "L"},
+	{"routesheet.col.containers", 14, "C"},
+	{"routesheet.col.check", 30, "C"},
+}
+
+// Render рисует маршрутный лист на языке lang: шапку с водителем и датой,
+// обзорную схему остановок и таблицу с колонкой для отметок.
+func Render(w io.Writer, lang i18n.Lang, driver *models.Driver, day time.Time, routes []models.Route) error {
+	if fontData == nil {
+		return fmt.Errorf("шрифт %q не загружен", fontPath)
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(false, margin)
+	pdf.AddUTF8FontFromBytes(fontFamily, "", fontData)
+	title := i18n.T(lang, "routesheet.title")
+	date := day.Format(i18n.T(lang, "routesheet.date_format"))
+	pdf.SetTitle(title+" — "+driver.Name+" — "+date, true)
+
+	pdf.AddPage()
+
+	pdf.SetFont(fontFamily, "", 16)
+	pdf.CellFormat(0, 9, title, "", 1, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 11)
+	pdf.CellFormat(0, 6, i18n.T(lang, "routesheet.driver", driver.Name), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 6, i18n.T(lang, "routesheet.date", date), "", 1, "L", false, 0, "")
+
+	totalContainers := 0
+	for _, r := range routes {
+		if r.Point != nil {
+			totalContainers += r.Point.ContainerCount
+		}
+	}
+	pdf.CellFormat(0, 6, i18n.T(lang, "routesheet.summary", len(routes), totalContainers), "", 1, "L", false, 0, "")
+	pdf.Ln(3)
+
+	if len(routes) > 0 {
+		drawOverviewMap(pdf, routes, pdf.GetY())
+		pdf.SetY(pdf.GetY() + mapHeight + 4)
+	}
+
+	drawTableHeader(pdf, lang)
+	for _, r := range routes {
+		access := accessInfo(lang, r.Point)
+		height := rowHeight
+		if access != "" {
+			height += accessHeight
+		}
+		if pdf.GetY()+height > pageHeight-margin-10 {
+			pdf.AddPage()
+			drawTableHeader(pdf, lang)
+		}
+		drawRow(pdf, r)
+		if access != "" {
+			drawAccessRow(pdf, access)
+		}
+	}
+
+	pdf.Ln(8)
+	if pdf.GetY()+12 > pageHeight-margin {
+		pdf.AddPage()
+	}
+	pdf.SetFont(fontFamily, "", 10)
+	pdf.CellFormat(0, 6, i18n.T(lang, "routesheet.signatures"), "", 1, "L", false, 0, "")
+
+	if err := pdf.Error(); err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+func drawTableHeader(pdf *gofpdf.Fpdf, lang i18n.Lang) {
+	pdf.SetFont(fontFamily, "", 10)
+	pdf.SetFillColor(230, 230, 230)
+	for _, c := range columns {
+		pdf.CellFormat(c.width, headerHeight, i18n.T(lang, c.title), "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+func drawRow(pdf *gofpdf.Fpdf, r models.Route) {
+	name, address, containers := "", "", ""
+	if r.Point != nil {
+		name = r.Point.Name
+		address = r.Point.Address
+		containers = strconv.Itoa(r.Point.ContainerCount)
+	}
+
+	values := []string{
+		strconv.Itoa(r.OrderNumber),
+		r.ScheduledAt.Local().Format("15:04"),
+		name,
+		address,
+		containers,
+		"",
+	}
+
+	pdf.SetFont(fontFamily, "", 9)
+	x, y := pdf.GetXY()
+	for i, c := range columns {
+		pdf.CellFormat(c.width, rowHeight, fitText(pdf, values[i], c.width-2), "1", 0, c.align, false, 0, "")
+	}
+
+	// Квадрат для отметки о выполнении в последней колонке
+	checkX := x
+	for _, c := range columns[:len(columns)-1] {
+		checkX += c.width
+	}
+	box := 4.5
+	pdf.Rect(checkX+(columns[len(columns)-1].width-box)/2, y+(rowHeight-box)/2, box, box, "D")
+
+	pdf.Ln(-1)
+}
+
+// accessInfo — что водителю нужно знать о подъезде к точке: окно
+// обслуживания, код ворот и заметки. Пустая строка — ограничений нет.
+func accessInfo(lang i18n.Lang, p *models.CollectionPoint) string {
+	if p == nil {
+		return ""
+	}
+	var parts []string
+	if p.WindowStart != nil && p.WindowEnd != nil {
+		parts = append(parts, i18n.T(lang, "routesheet.window", *p.WindowStart, *p.WindowEnd))
+	}
+	if p.GateCode != nil && *p.GateCode != "" {
+		parts = append(parts, i18n.T(lang, "routesheet.gate_code", *p.GateCode))
+	}
+	if p.AccessNotes != nil && *p.AccessNotes != "" {
+		parts = append(parts, *p.AccessNotes)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// drawAccessRow рисует под остановкой строку во всю ширину таблицы со
+// сведениями о подъезде.
+func drawAccessRow(pdf *gofpdf.Fpdf, text string) {
+	width := 0.0
+	for _, c := range columns {
+		width += c.width
+	}
+	pdf.SetFont(fontFamily, "", 8)
+	pdf.CellFormat(width, accessHeight, fitText(pdf, text, width-4), "1", 1, "L", false, 0, "")
+}
+
+// fitText обрезает строку с многоточием, чтобы она поместилась в ячейку.
+func fitText(pdf *gofpdf.Fpdf, s string, width float64) string {
+	if pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := string(runes) + "…"
+		if pdf.GetStringWidth(candidate) <= width {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// drawOverviewMap рисует схему остановок в равнопромежуточной проекции:
+// точки соединены в порядке объезда и подписаны номерами.
+func drawOverviewMap(pdf *gofpdf.Fpdf, routes []models.Route, top float64) {
+	left := margin
+	width := pageWidth - 2*margin
+
+	pdf.SetDrawColor(120, 120, 120)
+	pdf.Rect(left, top, width, mapHeight, "D")
+
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for _, r := range routes {
+		if r.Point == nil {
+			continue
+		}
+		minLat = math.Min(minLat, r.Point.Latitude)
+		maxLat = math.Max(maxLat, r.Point.Latitude)
+		minLon = math.Min(minLon, r.Point.Longitude)
+		maxLon = math.Max(maxLon, r.Point.Longitude)
+	}
+	if math.IsInf(minLat, 0) {
+		pdf.SetDrawColor(0, 0, 0)
+		return
+	}
+
+	// Сжатие долготы на широте города, чтобы схема не была растянута
+	kx := math.Cos((minLat + maxLat) / 2 * math.Pi / 180)
+	spanX := math.Max((maxLon-minLon)*kx, 1e-6)
+	spanY := math.Max(maxLat-minLat, 1e-6)
+
+	pad := 8.0
+	scale := math.Min((width-2*pad)/spanX, (mapHeight-2*pad)/spanY)
+	offsetX := left + (width-spanX*scale)/2
+	offsetY := top + (mapHeight-spanY*scale)/2
+
+	project := func(p *models.CollectionPoint) (float64, float64) {
+		x := offsetX + (p.Longitude-minLon)*kx*scale
+		y := offsetY + (maxLat-p.Latitude)*scale
+		return x, y
+	}
+
+	pdf.SetDrawColor(60, 110, 200)
+	pdf.SetLineWidth(0.4)
+	var prevX, prevY float64
+	first := true
+	for _, r := range routes {
+		if r.Point == nil {
+			continue
+		}
+		x, y := project(r.Point)
+		if !first {
+			pdf.Line(prevX, prevY, x, y)
+		}
+		prevX, prevY, first = x, y, false
+	}
+
+	pdf.SetLineWidth(0.2)
+	pdf.SetFont(fontFamily, "", 7)
+	for i, r := range routes {
+		if r.Point == nil {
+			continue
+		}
+		x, y := project(r.Point)
+		switch {
+		case i == 0:
+			pdf.SetFillColor(40, 160, 70)
+		case i == len(routes)-1:
+			pdf.SetFillColor(210, 60, 50)
+		default:
+			pdf.SetFillColor(255, 255, 255)
+		}
+		pdf.SetDrawColor(40, 40, 40)
+		pdf.Circle(x, y, 1.6, "FD")
+		pdf.Text(x+2, y-1.5, strconv.Itoa(r.OrderNumber))
+	}
+
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetFillColor(255, 255, 255)
+}
@@ -0,0 +1,444 @@
+package routing
+
+import (
+	"fmt"
+	"garbage_trucks/backend/internal/geo"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Truck — габариты машины для запретов проезда по знакам, отмеченным
+// в OSM тегами maxheight и maxweight.
+type Truck struct {
+	Height float64 // м
+	Weight float64 // т, полная масса
+}
+
+// Скорости мусоровоза по типу дороги, км/ч: ниже разрешённых, с учётом
+// светофоров, поворотов и манёвров во дворах. Тег maxspeed может только
+// снизить скорость.
+var roadSpeeds = map[string]float64{
+	"motorway":       70,
+	"motorway_link":  40,
+	"trunk":          60,
+	"trunk_link":     35,
+	"primary":        45,
+	"primary_link":   30,
+	"secondary":      40,
+	"secondary_link": 30,
+	"tertiary":       35,
+	"tertiary_link":  25,
+	"unclassified":   30,
+	"road":           25,
+	"residential":    20,
+	"living_street":  10,
+	"service":        10,
+}
+
+// Значения тегов доступа, закрывающие проезд мусоровозу. private и
+// destination не закрывают: закрытые дворы — обычное место контейнерных
+// площадок.
+var accessDenied = map[string]bool{
+	"no":           true,
+	"agricultural": true,
+	"forestry":     true,
+	"emergency":    true,
+	"psv":          true,
+	"bus":          true,
+}
+
+// Теги доступа от частного к общему: решает первый заданный
+var accessKeys = []string{"hgv", "motor_vehicle", "vehicle", "access"}
+
+// roadAttrs — можно ли ехать по линии и в какую сторону, с какой
+// скоростью.
+type roadAttrs struct {
+	forward, backward bool
+	speedKmh          float64
+}
+
+// attrs разбирает теги линии для машины t; ok == false — линия не
+// дорога для неё.
+func (t Truck) attrs(tags map[string]string) (a roadAttrs, ok bool) {
+	highway := tags["highway"]
+	speed, isRoad := roadSpeeds[highway]
+	if !isRoad || tags["area"] == "yes" {
+		return a, false
+	}
+	for _, k := range accessKeys {
+		if v, set := tags[k]; set {
+			if accessDenied[v] {
+				return a, false
+			}
+			break
+		}
+	}
+	for _, k := range []string{"maxheight", "maxheight:physical"} {
+		if limit, set := parseMeters(tags[k]); set && limit < t.Height {
+			return a, false
+		}
+	}
+	for _, k := range []string{"maxweight", "maxweightrating", "maxweight:hgv"} {
+		if limit, set := parseTonnes(tags[k]); set && limit < t.Weight {
+			return a, false
+		}
+	}
+	if limit, set := parseSpeed(tags["maxspeed"]); set && limit < speed {
+		speed = limit
+	}
+	a.speedKmh = speed
+
+	a.forward, a.backward = true, true
+	oneway := tags["oneway"]
+	if v, set := tags["oneway:hgv"]; set {
+		oneway = v
+	}
+	switch oneway {
+	case "yes", "true", "1":
+		a.backward = false
+	case "-1", "reverse":
+		a.forward = false
+	case "reversible", "alternating":
+		return a, false
+	case "no", "false", "0":
+	default:
+		if j := tags["junction"]; j == "roundabout" || j == "circular" || highway == "motorway" || highway == "motorway_link" {
+			a.backward = false
+		}
+	}
+	return a, true
+}
+
+// parseMeters разбирает высоту: «3.5», «3,5 m», «12'6"». none и default —
+// ограничения нет.
+func parseMeters(v string) (float64, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if feet, inches, found := strings.Cut(v, "'"); found {
+		f, err1 := strconv.ParseFloat(strings.TrimSpace(feet), 64)
+		in, err2 := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(inches, `"`)), 64)
+		if err1 != nil || (inches != "" && err2 != nil) {
+			return 0, false
+		}
+		return f*0.3048 + in*0.0254, true
+	}
+	return parseNumber(strings.TrimSuffix(v, "m"))
+}
+
+// parseTonnes разбирает массу: «7.5», «7,5 t», «3500 kg», «10 st».
+func parseTonnes(v string) (float64, bool) {
+	v = strings.TrimSpace(v)
+	switch {
+	case strings.HasSuffix(v, "kg"):
+		n, ok := parseNumber(strings.TrimSuffix(v, "kg"))
+		return n / 1000, ok
+	case strings.HasSuffix(v, "lbs"):
+		n, ok := parseNumber(strings.TrimSuffix(v, "lbs"))
+		return n * 0.000453592, ok
+	case strings.HasSuffix(v, "st"):
+		n, ok := parseNumber(strings.TrimSuffix(v, "st"))
+		return n * 0.907185, ok
+	}
+	return parseNumber(strings.TrimSuffix(v, "t"))
+}
+
+// Скорости по умолчанию для зональных значений maxspeed в России
+var zoneSpeeds = map[string]float64{
+	"RU:urban":         60,
+	"RU:rural":         90,
+	"RU:living_street": 20,
+	"RU:motorway":      110,
+	"walk":             5,
+}
+
+// parseSpeed разбирает maxspeed в км/ч: «40», «30 mph», «RU:urban».
+func parseSpeed(v string) (float64, bool) {
+	v = strings.TrimSpace(v)
+	if s, ok := zoneSpeeds[v]; ok {
+		return s, true
+	}
+	if strings.HasSuffix(v, "mph") {
+		n, ok := parseNumber(strings.TrimSuffix(v, "mph"))
+		return n * 1.609344, ok
+	}
+	return parseNumber(strings.TrimSuffix(v, "km/h"))
+}
+
+func parseNumber(v string) (float64, bool) {
+	n, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(v), ",", "."), 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, false
+	}
+	return n, true
+}
+
+// arc — участок дороги между соседними перекрёстками (или концами
+// дороги) с формой линии shape[first:last+1].
+type arc struct {
+	from, to          int32
+	first, last       int32
+	meters, seconds   float64
+	forward, backward bool
+}
+
+// edge — проезд по участку arc в одну сторону; reverse — против
+// направления его формы.
+type edge struct {
+	to      int32
+	arc     int32
+	reverse bool
+}
+
+// Graph — дорожный граф: вершины — перекрёстки и концы дорог, рёбра —
+// участки между ними с формой линии для отрисовки пути. Граф не
+// меняется после построения и безопасен для одновременного чтения.
+type Graph struct {
+	vertexLat, vertexLon []float64
+	// Исходящие рёбра вершины v: edges[first[v]:first[v+1]]
+	first []int32
+	edges []edge
+	arcs  []arc
+	// Точки формы участков и расстояние от начала участка до каждой
+	shapeLat, shapeLon, shapeDist []float64
+	grid                          segmentGrid
+}
+
+// Vertices и Arcs — размер графа.
+func (g *Graph) Vertices() int { return len(g.vertexLat) }
+func (g *Graph) Arcs() int     { return len(g.arcs) }
+
+// keptWay — дорога, прошедшая фильтр по тегам.
+type keptWay struct {
+	refs []int64
+	roadAttrs
+}
+
+// LoadFile строит граф из выгрузки OSM в формате PBF для машины t.
+func LoadFile(path string, t Truck) (*Graph, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f, t)
+}
+
+// Load строит граф из выгрузки PBF в два прохода: сначала дороги, затем
+// координаты только их точек — так память не тратится на остальные
+// объекты карты. r должен поддерживать Seek, чтобы прочитать файл дважды.
+func Load(r io.ReadSeeker, t Truck) (*Graph, error) {
+	var ways []keptWay
+	// Сколько раз точка встречается в дорогах; концы дорог считаются
+	// дважды, чтобы стать вершинами
+	uses := make(map[int64]uint8)
+	err := readPBF(r, blockHandler{onWay: func(w *osmWay) {
+		a, ok := t.attrs(w.tags)
+		if !ok || len(w.refs) < 2 {
+			return
+		}
+		ways = append(ways, keptWay{refs: w.refs, roadAttrs: a})
+		for i, id := range w.refs {
+			if i == 0 || i == len(w.refs)-1 {
+				use(uses, id, 2)
+			} else {
+				use(uses, id, 1)
+			}
+		}
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("чтение дорог: %w", err)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	coords := make(map[int64][2]float64, len(uses))
+	err = readPBF(r, blockHandler{onNode: func(id int64, lat, lon float64) {
+		if _, ok := uses[id]; ok {
+			coords[id] = [2]float64{lat, lon}
+		}
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("чтение точек: %w", err)
+	}
+
+	g := build(ways, uses, coords)
+	if len(g.arcs) == 0 {
+		return nil, fmt.Errorf("в выгрузке нет дорог, доступных машине")
+	}
+	return g, nil
+}
+
+// use увеличивает счётчик точки, не переполняя его.
+func use(uses map[int64]uint8, id int64, n uint8) {
+	if c := uses[id]; c <= math.MaxUint8-n {
+		uses[id] = c + n
+	}
+}
+
+// build собирает граф. Точки без координат (дорога выходит за границу
+// выгрузки) разрезают дорогу, концы кусков становятся вершинами. В граф
+// попадает только самая большая связная часть: к обрывкам дорог на краю
+// выгрузки или в закрытых зонах всё равно не проехать.
+func build(ways []keptWay, uses map[int64]uint8, coords map[int64][2]float64) *Graph {
+	var runs []keptWay
+	for _, w := range ways {
+		start := -1
+		for i := 0; i <= len(w.refs); i++ {
+			if i < len(w.refs) {
+				if _, known := coords[w.refs[i]]; known {
+					if start < 0 {
+						start = i
+					}
+					continue
+				}
+			}
+			if start >= 0 && i-start >= 2 {
+				use(uses, w.refs[start], 2)
+				use(uses, w.refs[i-1], 2)
+				runs = append(runs, keptWay{refs: w.refs[start:i], roadAttrs: w.roadAttrs})
+			}
+			start = -1
+		}
+	}
+
+	g := &Graph{}
+	vertex := make(map[int64]int32)
+	vertexOf := func(id int64) int32 {
+		v, ok := vertex[id]
+		if !ok {
+			v = int32(len(g.vertexLat))
+			vertex[id] = v
+			c := coords[id]
+			g.vertexLat = append(g.vertexLat, c[0])
+			g.vertexLon = append(g.vertexLon, c[1])
+		}
+		return v
+	}
+
+	for _, w := range runs {
+		a := arc{from: vertexOf(w.refs[0]), first: int32(len(g.shapeLat)), forward: w.forward, backward: w.backward}
+		dist := 0.0
+		for i, id := range w.refs {
+			c := coords[id]
+			if i > 0 {
+				dist += geo.Distance(g.shapeLat[len(g.shapeLat)-1], g.shapeLon[len(g.shapeLon)-1], c[0], c[1])
+			}
+			g.shapeLat = append(g.shapeLat, c[0])
+			g.shapeLon = append(g.shapeLon, c[1])
+			g.shapeDist = append(g.shapeDist, dist)
+			if i == 0 || uses[id] < 2 {
+				continue
+			}
+			a.to, a.last = vertexOf(id), int32(len(g.shapeLat)-1)
+			a.meters = dist
+			a.seconds = dist / (w.speedKmh / 3.6)
+			g.arcs = append(g.arcs, a)
+			if i < len(w.refs)-1 {
+				// Следующий участок начинается с этой же точки
+				a = arc{from: a.to, first: int32(len(g.shapeLat)), forward: w.forward, backward: w.backward}
+				g.shapeLat = append(g.shapeLat, c[0])
+				g.shapeLon = append(g.shapeLon, c[1])
+				g.shapeDist = append(g.shapeDist, 0)
+				dist = 0
+			}
+		}
+	}
+
+	g.keepLargestComponent()
+	g.index()
+	return g
+}
+
+// keepLargestComponent оставляет самую большую связную (без учёта
+// направлений) часть графа и перенумеровывает вершины.
+func (g *Graph) keepLargestComponent() {
+	parent := make([]int32, len(g.vertexLat))
+	for i := range parent {
+		parent[i] = int32(i)
+	}
+	var find func(v int32) int32
+	find = func(v int32) int32 {
+		for parent[v] != v {
+			parent[v] = parent[parent[v]]
+			v = parent[v]
+		}
+		return v
+	}
+	for _, a := range g.arcs {
+		if ra, rb := find(a.from), find(a.to); ra != rb {
+			parent[ra] = rb
+		}
+	}
+	size := make(map[int32]int)
+	best, bestSize := int32(-1), 0
+	for v := range parent {
+		r := find(int32(v))
+		size[r]++
+		if size[r] > bestSize {
+			best, bestSize = r, size[r]
+		}
+	}
+
+	renumber := make([]int32, len(parent))
+	var lat, lon []float64
+	for v := range parent {
+		renumber[v] = -1
+		if find(int32(v)) == best {
+			renumber[v] = int32(len(lat))
+			lat = append(lat, g.vertexLat[v])
+			lon = append(lon, g.vertexLon[v])
+		}
+	}
+	arcs := g.arcs[:0]
+	for _, a := range g.arcs {
+		if renumber[a.from] < 0 {
+			continue
+		}
+		a.from, a.to = renumber[a.from], renumber[a.to]
+		arcs = append(arcs, a)
+	}
+	g.vertexLat, g.vertexLon, g.arcs = lat, lon, arcs
+	// Точки формы отброшенных участков остаются в массивах: их немного,
+	// а пересборка формы не стоит сложности
+}
+
+// index строит списки исходящих рёбер и сетку участков для привязки точек.
+func (g *Graph) index() {
+	g.first = make([]int32, len(g.vertexLat)+1)
+	for _, a := range g.arcs {
+		if a.forward {
+			g.first[a.from+1]++
+		}
+		if a.backward {
+			g.first[a.to+1]++
+		}
+	}
+	for v := 1; v < len(g.first); v++ {
+		g.first[v] += g.first[v-1]
+	}
+	g.edges = make([]edge, g.first[len(g.first)-1])
+	next := append([]int32(nil), g.first[:len(g.first)-1]...)
+	for i, a := range g.arcs {
+		if a.forward {
+			g.edges[next[a.from]] = edge{to: a.to, arc: int32(i)}
+			next[a.from]++
+		}
+		if a.backward {
+			g.edges[next[a.to]] = edge{to: a.from, arc: int32(i), reverse: true}
+			next[a.to]++
+		}
+	}
+
+	g.grid = newSegmentGrid()
+	for i, a := range g.arcs {
+		for k := a.first; k < a.last; k++ {
+			g.grid.add(int32(i), k, g.shapeLat[k], g.shapeLon[k], g.shapeLat[k+1], g.shapeLon[k+1])
+		}
+	}
+}
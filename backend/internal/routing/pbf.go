@@ -0,0 +1,404 @@
+package routing
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Чтение выгрузки OpenStreetMap в формате PBF: файл — последовательность
+// блоков (длина заголовка, BlobHeader, Blob), данные блока — сжатый zlib
+// PrimitiveBlock с точками и линиями. Разбираются только поля, нужные для
+// дорожного графа; отношения (в том числе запреты поворотов) пропускаются.
+
+// Ограничения размеров из спецификации формата
+const (
+	maxBlobHeaderSize = 64 * 1024
+	maxBlobSize       = 32 * 1024 * 1024
+)
+
+// Возможности формата, которые умеет читать пакет
+var supportedFeatures = map[string]bool{
+	"OsmSchema-V0.6": true,
+	"DenseNodes":     true,
+}
+
+// osmWay — линия OSM: теги и точки по порядку.
+type osmWay struct {
+	id   int64
+	tags map[string]string
+	refs []int64
+}
+
+// blockHandler получает из блока точки (если onNode задан) и линии
+// (если задан onWay).
+type blockHandler struct {
+	onNode func(id int64, lat, lon float64)
+	onWay  func(w *osmWay)
+}
+
+// readPBF читает файл блок за блоком и передаёт данные блоков h.
+func readPBF(r io.Reader, h blockHandler) error {
+	var size [4]byte
+	header := make([]byte, 0, maxBlobHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > maxBlobHeaderSize {
+			return fmt.Errorf("заголовок блока %d байт больше допустимого", n)
+		}
+		header = header[:n]
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		kind, dataSize, err := parseBlobHeader(header)
+		if err != nil {
+			return err
+		}
+		blob := make([]byte, dataSize)
+		if _, err := io.ReadFull(r, blob); err != nil {
+			return err
+		}
+
+		switch kind {
+		case "OSMHeader":
+			data, err := blobData(blob)
+			if err != nil {
+				return err
+			}
+			if err := checkHeaderBlock(data); err != nil {
+				return err
+			}
+		case "OSMData":
+			data, err := blobData(blob)
+			if err != nil {
+				return err
+			}
+			if err := parsePrimitiveBlock(data, h); err != nil {
+				return err
+			}
+		}
+		// Блоки неизвестных типов по спецификации пропускаются
+	}
+}
+
+func parseBlobHeader(b []byte) (kind string, dataSize int, err error) {
+	err = scanFields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			kind = string(v)
+		case num == 3 && typ == protowire.VarintType:
+			// int32 в схеме: отрицательное значение приходит огромным
+			// varint и не должно дойти до make
+			if x > maxBlobSize {
+				return fmt.Errorf("блок %d байт больше допустимого", x)
+			}
+			dataSize = int(x)
+		}
+		return nil
+	})
+	return kind, dataSize, err
+}
+
+// blobData распаковывает содержимое блока; поддерживаются несжатые
+// и сжатые zlib блоки, как их пишут osmium и Geofabrik.
+func blobData(b []byte) ([]byte, error) {
+	var raw, compressed []byte
+	rawSize := 0
+	unsupported := false
+	err := scanFields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch num {
+		case 1:
+			raw = v
+		case 2:
+			if x > maxBlobSize {
+				return fmt.Errorf("распакованный блок %d байт больше допустимого", x)
+			}
+			rawSize = int(x)
+		case 3:
+			compressed = v
+		case 4, 5, 6, 7:
+			unsupported = true
+		}
+		return nil
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case raw != nil:
+		return raw, nil
+	case compressed != nil:
+		zr, err := zlib.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		out := bytes.NewBuffer(make([]byte, 0, rawSize))
+		if _, err := io.Copy(out, io.LimitReader(zr, maxBlobSize+1)); err != nil {
+			return nil, err
+		}
+		if out.Len() > maxBlobSize {
+			return nil, errors.New("распакованный блок больше допустимого")
+		}
+		return out.Bytes(), nil
+	case unsupported:
+		return nil, errors.New("сжатие блока не поддерживается: нужен zlib")
+	}
+	return nil, errors.New("пустой блок")
+}
+
+// checkHeaderBlock проверяет, что файл не требует неподдерживаемых
+// возможностей формата (например, истории правок).
+func checkHeaderBlock(b []byte) error {
+	return scanFields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		if num == 4 && typ == protowire.BytesType && !supportedFeatures[string(v)] {
+			return fmt.Errorf("файл требует неподдерживаемую возможность %q", v)
+		}
+		return nil
+	})
+}
+
+// primitiveBlock — общие для групп блока таблица строк и смещения
+// координат.
+type primitiveBlock struct {
+	strings     [][]byte
+	granularity int64
+	latOffset   int64
+	lonOffset   int64
+}
+
+func (pb *primitiveBlock) coord(offset, v int64) float64 {
+	return 1e-9 * float64(offset+pb.granularity*v)
+}
+
+func (pb *primitiveBlock) str(i uint64) (string, error) {
+	if i >= uint64(len(pb.strings)) {
+		return "", fmt.Errorf("строка %d вне таблицы строк", i)
+	}
+	return string(pb.strings[i]), nil
+}
+
+func parsePrimitiveBlock(b []byte, h blockHandler) error {
+	pb := &primitiveBlock{granularity: 100}
+	var groups [][]byte
+	err := scanFields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch num {
+		case 1:
+			return scanFields(v, func(num protowire.Number, typ protowire.Type, s []byte, _ uint64) error {
+				if num == 1 {
+					pb.strings = append(pb.strings, s)
+				}
+				return nil
+			})
+		case 2:
+			groups = append(groups, v)
+		case 17:
+			pb.granularity = int64(x)
+		case 19:
+			pb.latOffset = int64(x)
+		case 20:
+			pb.lonOffset = int64(x)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Группы разбираются после блока: таблица строк и смещения могут
+	// идти в нём после групп
+	for _, g := range groups {
+		err := scanFields(g, func(num protowire.Number, typ protowire.Type, v []byte, _ uint64) error {
+			switch {
+			case num == 1 && h.onNode != nil:
+				return pb.node(v, h.onNode)
+			case num == 2 && h.onNode != nil:
+				return pb.denseNodes(v, h.onNode)
+			case num == 3 && h.onWay != nil:
+				return pb.way(v, h.onWay)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pb *primitiveBlock) node(b []byte, fn func(id int64, lat, lon float64)) error {
+	var id, lat, lon int64
+	err := scanFields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		switch num {
+		case 1:
+			id = protowire.DecodeZigZag(x)
+		case 8:
+			lat = protowire.DecodeZigZag(x)
+		case 9:
+			lon = protowire.DecodeZigZag(x)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fn(id, pb.coord(pb.latOffset, lat), pb.coord(pb.lonOffset, lon))
+	return nil
+}
+
+// denseNodes разбирает точки, упакованные дельтами: идентификаторы
+// и координаты хранятся разностями с предыдущей точкой.
+func (pb *primitiveBlock) denseNodes(b []byte, fn func(id int64, lat, lon float64)) error {
+	var ids, lats, lons []int64
+	err := scanFields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		var err error
+		switch num {
+		case 1:
+			ids, err = appendSint64(ids, typ, v, x)
+		case 8:
+			lats, err = appendSint64(lats, typ, v, x)
+		case 9:
+			lons, err = appendSint64(lons, typ, v, x)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(lats) != len(ids) || len(lons) != len(ids) {
+		return errors.New("DenseNodes: разная длина id и координат")
+	}
+	var id, lat, lon int64
+	for i := range ids {
+		id += ids[i]
+		lat += lats[i]
+		lon += lons[i]
+		fn(id, pb.coord(pb.latOffset, lat), pb.coord(pb.lonOffset, lon))
+	}
+	return nil
+}
+
+func (pb *primitiveBlock) way(b []byte, fn func(w *osmWay)) error {
+	w := &osmWay{}
+	var keys, vals []uint64
+	var refs []int64
+	err := scanFields(b, func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error {
+		var err error
+		switch num {
+		case 1:
+			w.id = int64(x)
+		case 2:
+			keys, err = appendUint64(keys, typ, v, x)
+		case 3:
+			vals, err = appendUint64(vals, typ, v, x)
+		case 8:
+			refs, err = appendSint64(refs, typ, v, x)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(keys) != len(vals) {
+		return fmt.Errorf("линия %d: разное число ключей и значений тегов", w.id)
+	}
+
+	w.tags = make(map[string]string, len(keys))
+	for i := range keys {
+		k, err := pb.str(keys[i])
+		if err != nil {
+			return err
+		}
+		v, err := pb.str(vals[i])
+		if err != nil {
+			return err
+		}
+		w.tags[k] = v
+	}
+	w.refs = make([]int64, len(refs))
+	var ref int64
+	for i, d := range refs {
+		ref += d
+		w.refs[i] = ref
+	}
+	fn(w)
+	return nil
+}
+
+// scanFields обходит поля сообщения protobuf: для varint передаётся
+// значение x, для bytes — содержимое v. Поля fixed32/fixed64
+// пропускаются.
+func scanFields(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, x uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var v []byte
+		var x uint64
+		switch typ {
+		case protowire.VarintType:
+			x, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if typ == protowire.VarintType || typ == protowire.BytesType {
+			if err := fn(num, typ, v, x); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// appendUint64 добавляет значения поля repeated uint: упакованного или
+// по одному.
+func appendUint64(out []uint64, typ protowire.Type, v []byte, x uint64) ([]uint64, error) {
+	if typ == protowire.VarintType {
+		return append(out, x), nil
+	}
+	for len(v) > 0 {
+		x, n := protowire.ConsumeVarint(v)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		out = append(out, x)
+		v = v[n:]
+	}
+	return out, nil
+}
+
+// appendSint64 — то же для repeated sint64 (zigzag).
+func appendSint64(out []int64, typ protowire.Type, v []byte, x uint64) ([]int64, error) {
+	if typ == protowire.VarintType {
+		return append(out, protowire.DecodeZigZag(x)), nil
+	}
+	for len(v) > 0 {
+		x, n := protowire.ConsumeVarint(v)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		out = append(out, protowire.DecodeZigZag(x))
+		v = v[n:]
+	}
+	return out, nil
+}
@@ -0,0 +1,34 @@
+package routing
+
+import (
+	"math"
+	"strings"
+)
+
+// EncodePolyline кодирует линию в формате Google Encoded Polyline
+// с точностью 5 знаков (около метра) — его понимают Leaflet, Mapbox
+// и Яндекс.Карты через плагины.
+func EncodePolyline(line []Point) string {
+	var b strings.Builder
+	var prevLat, prevLon int64
+	for _, p := range line {
+		lat := int64(math.Round(p.Latitude * 1e5))
+		lon := int64(math.Round(p.Longitude * 1e5))
+		encodePolylineValue(&b, lat-prevLat)
+		encodePolylineValue(&b, lon-prevLon)
+		prevLat, prevLon = lat, lon
+	}
+	return b.String()
+}
+
+func encodePolylineValue(b *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte(0x20|u&0x1f) + 63)
+		u >>= 5
+	}
+	b.WriteByte(byte(u) + 63)
+}
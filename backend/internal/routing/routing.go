@@ -0,0 +1,103 @@
+// Package routing — маршруты по дорогам из локальной выгрузки
+// OpenStreetMap (PBF) без внешних сервисов: граф дорог, доступных
+// мусоровозу (с учётом односторонних дорог, запретов проезда грузовикам,
+// ограничений высоты и массы), кратчайшие по времени пути и матрицы
+// расстояний и времени для планирования и прогноза прибытия. Запреты
+// поворотов не учитываются.
+package routing
+
+import (
+	"context"
+	"errors"
+	"garbage_trucks/backend/internal/config"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable — дорожный граф не загружен.
+var ErrUnavailable = errors.New("дорожный граф не загружен")
+
+// Граф из ROUTING_OSM_FILE; nil — расстояния считаются по прямой
+var graph *Graph
+
+// Сколько пар точек помнит кэш Travel; при переполнении он очищается
+const travelCacheSize = 100000
+
+// Кэш Travel: прогноз прибытия и перестроение маршрута спрашивают одни
+// и те же пары остановок на каждом запросе
+var travelCache = struct {
+	sync.Mutex
+	legs map[[2]Point]travelLeg
+}{legs: map[[2]Point]travelLeg{}}
+
+type travelLeg struct {
+	meters   float64
+	duration time.Duration
+	err      error
+}
+
+// Init строит дорожный граф из выгрузки, если она задана. Ошибка чтения
+// не мешает запуску: без графа расстояния считаются по прямой.
+func Init(cfg *config.Config) {
+	if cfg.RoutingOSMFile == "" {
+		return
+	}
+	started := time.Now()
+	g, err := LoadFile(cfg.RoutingOSMFile, Truck{Height: cfg.TruckHeight, Weight: cfg.TruckWeight})
+	if err != nil {
+		slog.Warn("Дорожный граф недоступен, расстояния считаются по прямой", "path", cfg.RoutingOSMFile, "err", err)
+		return
+	}
+	graph = g
+	slog.Info("Загружен дорожный граф", "path", cfg.RoutingOSMFile,
+		"vertices", g.Vertices(), "arcs", g.Arcs(), "duration", time.Since(started).Round(time.Millisecond))
+}
+
+// Available сообщает, загружен ли дорожный граф.
+func Available() bool {
+	return graph != nil
+}
+
+// Route ищет самый быстрый путь по загруженному графу.
+func Route(from, to Point) (*Path, error) {
+	if graph == nil {
+		return nil, ErrUnavailable
+	}
+	return graph.Route(from, to)
+}
+
+// Travel возвращает длину и время самого быстрого пути без линии пути;
+// результаты кэшируются.
+func Travel(from, to Point) (meters float64, d time.Duration, err error) {
+	if graph == nil {
+		return 0, 0, ErrUnavailable
+	}
+	key := [2]Point{from, to}
+	travelCache.Lock()
+	leg, ok := travelCache.legs[key]
+	travelCache.Unlock()
+	if !ok {
+		leg = travelLeg{}
+		if p, err := graph.Route(from, to); err != nil {
+			leg.err = err
+		} else {
+			leg.meters, leg.duration = p.Meters, p.Duration
+		}
+		travelCache.Lock()
+		if len(travelCache.legs) >= travelCacheSize {
+			travelCache.legs = map[[2]Point]travelLeg{}
+		}
+		travelCache.legs[key] = leg
+		travelCache.Unlock()
+	}
+	return leg.meters, leg.duration, leg.err
+}
+
+// DistanceMatrix считает матрицу путей по загруженному графу.
+func DistanceMatrix(ctx context.Context, points []Point) (*Matrix, error) {
+	if graph == nil {
+		return nil, ErrUnavailable
+	}
+	return graph.Matrix(ctx, points)
+}
@@ -0,0 +1,264 @@
+package routing
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Улицы тестовой выгрузки — сетка 3×3 с шагом 0,005° (≈556 м по широте,
+// ≈322 м по долготе), точка (r, c) — узел r*3+c+1:
+//
+//	1 — 2 — 3     ряды — residential, ряд 1 — maxweight=10
+//	|   |   |     столбец 0 — primary
+//	4 — 5 — 6     столбец 1 — maxheight=3
+//	|   |   ↓     столбец 2 — oneway=yes вниз (3 → 6 → 9)
+//	7 — 8 — 9
+var fixtureWays = []struct {
+	tags [][2]string
+	refs []int64
+}{
+	{[][2]string{{"highway", "residential"}}, []int64{1, 2, 3}},
+	{[][2]string{{"highway", "residential"}, {"maxweight", "10"}}, []int64{4, 5, 6}},
+	{[][2]string{{"highway", "residential"}}, []int64{7, 8, 9}},
+	{[][2]string{{"highway", "primary"}}, []int64{1, 4, 7}},
+	{[][2]string{{"highway", "residential"}, {"maxheight", "3"}}, []int64{2, 5, 8}},
+	{[][2]string{{"highway", "residential"}, {"oneway", "yes"}}, []int64{3, 6, 9}},
+	{[][2]string{{"building", "yes"}}, []int64{1, 5, 9}}, // не дорога
+}
+
+const (
+	stepLat = 556.0 // метров на 0,005° широты
+	stepLon = 322.0 // метров на 0,005° долготы на 54,6°
+)
+
+func at(r, c float64) Point {
+	return Point{Latitude: 54.60 + 0.005*r, Longitude: 39.70 + 0.005*c}
+}
+
+// fixturePBF собирает выгрузку: заголовок и один сжатый блок с DenseNodes
+// и линиями.
+func fixturePBF(t *testing.T) []byte {
+	t.Helper()
+	strs := []string{""}
+	index := map[string]uint64{}
+	str := func(s string) uint64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		index[s] = uint64(len(strs))
+		strs = append(strs, s)
+		return index[s]
+	}
+
+	var ids, lats, lons []int64
+	var prevID, prevLat, prevLon int64
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			p := at(float64(r), float64(c))
+			id, lat, lon := int64(r*3+c+1), int64(math.Round(p.Latitude*1e7)), int64(math.Round(p.Longitude*1e7))
+			ids, lats, lons = append(ids, id-prevID), append(lats, lat-prevLat), append(lons, lon-prevLon)
+			prevID, prevLat, prevLon = id, lat, lon
+		}
+	}
+	var dense []byte
+	dense = appendPacked(dense, 1, ids, true)
+	dense = appendPacked(dense, 8, lats, true)
+	dense = appendPacked(dense, 9, lons, true)
+
+	var group []byte
+	group = protowire.AppendTag(group, 2, protowire.BytesType)
+	group = protowire.AppendBytes(group, dense)
+	for i, w := range fixtureWays {
+		var keys, vals, refs []int64
+		for _, tag := range w.tags {
+			keys, vals = append(keys, int64(str(tag[0]))), append(vals, int64(str(tag[1])))
+		}
+		var prev int64
+		for _, ref := range w.refs {
+			refs, prev = append(refs, ref-prev), ref
+		}
+		var way []byte
+		way = protowire.AppendTag(way, 1, protowire.VarintType)
+		way = protowire.AppendVarint(way, uint64(i+1))
+		way = appendPacked(way, 2, keys, false)
+		way = appendPacked(way, 3, vals, false)
+		way = appendPacked(way, 8, refs, true)
+		group = protowire.AppendTag(group, 3, protowire.BytesType)
+		group = protowire.AppendBytes(group, way)
+	}
+
+	var table []byte
+	for _, s := range strs {
+		table = protowire.AppendTag(table, 1, protowire.BytesType)
+		table = protowire.AppendString(table, s)
+	}
+	var block []byte
+	block = protowire.AppendTag(block, 1, protowire.BytesType)
+	block = protowire.AppendBytes(block, table)
+	block = protowire.AppendTag(block, 2, protowire.BytesType)
+	block = protowire.AppendBytes(block, group)
+
+	var header []byte
+	for _, f := range []string{"OsmSchema-V0.6", "DenseNodes"} {
+		header = protowire.AppendTag(header, 4, protowire.BytesType)
+		header = protowire.AppendString(header, f)
+	}
+	return append(fileBlock("OSMHeader", compressedBlob(t, header)), fileBlock("OSMData", compressedBlob(t, block))...)
+}
+
+func appendPacked(b []byte, num protowire.Number, values []int64, zigzag bool) []byte {
+	var packed []byte
+	for _, v := range values {
+		if zigzag {
+			packed = protowire.AppendVarint(packed, protowire.EncodeZigZag(v))
+		} else {
+			packed = protowire.AppendVarint(packed, uint64(v))
+		}
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+func compressedBlob(t *testing.T, data []byte) []byte {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	zw.Close()
+	var blob []byte
+	blob = protowire.AppendTag(blob, 2, protowire.VarintType)
+	blob = protowire.AppendVarint(blob, uint64(len(data)))
+	blob = protowire.AppendTag(blob, 3, protowire.BytesType)
+	return protowire.AppendBytes(blob, z.Bytes())
+}
+
+// fileBlock — длина заголовка, BlobHeader и Blob.
+func fileBlock(kind string, blob []byte) []byte {
+	return rawFileBlock(kind, uint64(len(blob)), blob)
+}
+
+func rawFileBlock(kind string, dataSize uint64, blob []byte) []byte {
+	var header []byte
+	header = protowire.AppendTag(header, 1, protowire.BytesType)
+	header = protowire.AppendString(header, kind)
+	header = protowire.AppendTag(header, 3, protowire.VarintType)
+	header = protowire.AppendVarint(header, dataSize)
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(header)))
+	return append(append(out, header...), blob...)
+}
+
+func loadFixture(t *testing.T, truck Truck) *Graph {
+	t.Helper()
+	g, err := Load(bytes.NewReader(fixturePBF(t)), truck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func near(got, want float64) bool {
+	return math.Abs(got-want) < want*0.01
+}
+
+func TestRouteRestrictions(t *testing.T) {
+	heavy := loadFixture(t, Truck{Height: 3.7, Weight: 26})
+	light := loadFixture(t, Truck{Height: 2.5, Weight: 5})
+
+	tests := []struct {
+		name     string
+		g        *Graph
+		from, to Point
+		meters   float64
+	}{
+		{"по односторонней", heavy, at(0, 2), at(2, 2), 2 * stepLat},
+		{"против односторонней — в объезд", heavy, at(2, 2), at(0, 2), 2*stepLat + 4*stepLon},
+		{"maxheight — в объезд", heavy, at(0, 1), at(2, 1), 2*stepLat + 2*stepLon},
+		{"maxheight — низкой машине напрямую", light, at(0, 1), at(2, 1), 2 * stepLat},
+		{"maxweight — в объезд", heavy, at(1, 0), at(1, 2), stepLat + 2*stepLon + stepLat},
+		{"maxweight — лёгкой машине напрямую", light, at(1, 0), at(1, 2), 2 * stepLon},
+		{"по одной дороге", heavy, at(0, 0.2), at(0, 0.7), 0.5 * stepLon},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := tt.g.Route(tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !near(p.Meters, tt.meters) {
+				t.Errorf("%.0f м, ждали %.0f", p.Meters, tt.meters)
+			}
+			if p.Duration <= 0 || len(p.Geometry) < 2 {
+				t.Errorf("время %s, точек линии %d", p.Duration, len(p.Geometry))
+			}
+		})
+	}
+
+	if _, err := heavy.Route(at(10, 10), at(0, 0)); !errors.Is(err, ErrNoRoad) {
+		t.Errorf("точка без дорог рядом: %v, ждали ErrNoRoad", err)
+	}
+}
+
+func TestMatrixMatchesRoute(t *testing.T) {
+	g := loadFixture(t, Truck{Height: 3.7, Weight: 26})
+	points := []Point{at(0, 0), at(2, 2), at(0, 2), at(1.5, 0), at(10, 10)}
+
+	m, err := g.Matrix(context.Background(), points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, from := range points {
+		for j, to := range points {
+			switch {
+			case i == j:
+				if m.Meters[i][j] != 0 {
+					t.Errorf("[%d][%d] = %.0f, ждали 0", i, j, m.Meters[i][j])
+				}
+			case i == len(points)-1 || j == len(points)-1:
+				if !math.IsInf(m.Meters[i][j], 1) || !math.IsInf(m.Seconds[i][j], 1) {
+					t.Errorf("[%d][%d] до точки без дорог = %.0f, ждали +Inf", i, j, m.Meters[i][j])
+				}
+			default:
+				p, err := g.Route(from, to)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if math.Abs(p.Meters-m.Meters[i][j]) > 0.01 || math.Abs(p.Duration.Seconds()-m.Seconds[i][j]) > 0.01 {
+					t.Errorf("[%d][%d] = %.1f м %.1f с, Route — %.1f м %.1f с",
+						i, j, m.Meters[i][j], m.Seconds[i][j], p.Meters, p.Duration.Seconds())
+				}
+			}
+		}
+	}
+}
+
+// Размеры блоков — int32 в схеме: отрицательные и слишком большие значения
+// должны давать ошибку, а не панику при выделении памяти.
+func TestLoadRejectsBadSizes(t *testing.T) {
+	negative := uint64(math.MaxUint64) // -1, записанное как int32
+	var blob []byte
+	blob = protowire.AppendTag(blob, 2, protowire.VarintType)
+	blob = protowire.AppendVarint(blob, negative)
+	blob = protowire.AppendTag(blob, 3, protowire.BytesType)
+	blob = protowire.AppendBytes(blob, []byte{1, 2, 3})
+
+	files := map[string][]byte{
+		"отрицательный размер блока":          rawFileBlock("OSMData", negative, nil),
+		"слишком большой блок":                rawFileBlock("OSMData", maxBlobSize+1, nil),
+		"отрицательный размер распакованного": fileBlock("OSMData", blob),
+	}
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(bytes.NewReader(data), Truck{}); err == nil {
+				t.Error("выгрузка принята")
+			}
+		})
+	}
+}
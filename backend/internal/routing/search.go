@@ -0,0 +1,469 @@
+package routing
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNoRoad — рядом с точкой нет дороги, доступной машине.
+	ErrNoRoad = errors.New("рядом с точкой нет дороги")
+	// ErrNoRoute — между точками нет пути с учётом односторонних
+	// дорог и запретов.
+	ErrNoRoute = errors.New("между точками нет пути")
+)
+
+// Точка привязывается к дороге не дальше этого, метров: контейнерные
+// площадки стоят во дворах, но не в сотнях метров от проезда
+const snapMaxMeters = 300.0
+
+// Размер ячейки сетки участков в градусах: около километра по широте
+const gridCellSize = 0.01
+
+// Радиус Земли для местной плоской проекции при привязке, м
+const earthRadius = 6371008.8
+
+type gridCell struct {
+	row, col int32
+}
+
+// segmentRef — отрезок k формы участка arc.
+type segmentRef struct {
+	arc int32
+	k   int32
+}
+
+// segmentGrid — отрезки формы участков по ячейкам; отрезок попадает во
+// все ячейки своего охватывающего прямоугольника.
+type segmentGrid struct {
+	cells map[gridCell][]segmentRef
+}
+
+func newSegmentGrid() segmentGrid {
+	return segmentGrid{cells: make(map[gridCell][]segmentRef)}
+}
+
+func cellOf(lat, lon float64) gridCell {
+	return gridCell{row: int32(math.Floor(lat / gridCellSize)), col: int32(math.Floor(lon / gridCellSize))}
+}
+
+func (sg segmentGrid) add(a, k int32, lat1, lon1, lat2, lon2 float64) {
+	c1, c2 := cellOf(math.Min(lat1, lat2), math.Min(lon1, lon2)), cellOf(math.Max(lat1, lat2), math.Max(lon1, lon2))
+	for row := c1.row; row <= c2.row; row++ {
+		for col := c1.col; col <= c2.col; col++ {
+			c := gridCell{row, col}
+			sg.cells[c] = append(sg.cells[c], segmentRef{arc: a, k: k})
+		}
+	}
+}
+
+// Point — координаты в градусах WGS84.
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// snap — точка, привязанная к участку: offset метров от начала его формы.
+type snap struct {
+	arc      int32
+	offset   float64
+	at       Point // проекция на дорогу
+	distance float64
+}
+
+// snap привязывает точку к ближайшему участку дороги в пределах
+// snapMaxMeters. Ячейки сетки не меньше snapMaxMeters по обеим осям на
+// широтах до 70°, поэтому достаточно соседних ячеек.
+func (g *Graph) snap(p Point) (snap, error) {
+	best := snap{arc: -1, distance: math.Inf(1)}
+	kx := math.Cos(p.Latitude * math.Pi / 180)
+	seen := make(map[segmentRef]bool)
+	c := cellOf(p.Latitude, p.Longitude)
+	for row := c.row - 1; row <= c.row+1; row++ {
+		for col := c.col - 1; col <= c.col+1; col++ {
+			for _, ref := range g.grid.cells[gridCell{row, col}] {
+				if seen[ref] {
+					continue
+				}
+				seen[ref] = true
+				// Отрезок в метрах в плоской проекции с началом в p
+				x1 := (g.shapeLon[ref.k] - p.Longitude) * kx * earthRadius * math.Pi / 180
+				y1 := (g.shapeLat[ref.k] - p.Latitude) * earthRadius * math.Pi / 180
+				x2 := (g.shapeLon[ref.k+1] - p.Longitude) * kx * earthRadius * math.Pi / 180
+				y2 := (g.shapeLat[ref.k+1] - p.Latitude) * earthRadius * math.Pi / 180
+				dx, dy := x2-x1, y2-y1
+				t := 0.0
+				if l2 := dx*dx + dy*dy; l2 > 0 {
+					t = math.Max(0, math.Min(1, -(x1*dx+y1*dy)/l2))
+				}
+				px, py := x1+t*dx, y1+t*dy
+				if d := math.Hypot(px, py); d < best.distance {
+					seg := g.shapeDist[ref.k+1] - g.shapeDist[ref.k]
+					best = snap{
+						arc: ref.arc, distance: d,
+						offset: g.shapeDist[ref.k] + t*seg,
+						at: Point{
+							Latitude:  g.shapeLat[ref.k] + t*(g.shapeLat[ref.k+1]-g.shapeLat[ref.k]),
+							Longitude: g.shapeLon[ref.k] + t*(g.shapeLon[ref.k+1]-g.shapeLon[ref.k]),
+						},
+					}
+				}
+			}
+		}
+	}
+	if best.arc < 0 || best.distance > snapMaxMeters {
+		return best, ErrNoRoad
+	}
+	return best, nil
+}
+
+// fraction — доля участка от его начала до привязанной точки.
+func (g *Graph) fraction(s snap) float64 {
+	if m := g.arcs[s.arc].meters; m > 0 {
+		return s.offset / m
+	}
+	return 0
+}
+
+// search — состояние поиска кратчайших по времени путей от одной точки.
+type search struct {
+	g       *Graph
+	seconds []float64
+	meters  []float64
+	pred    []int32 // ребро, по которому пришли; -1 — из начальной точки
+	done    []bool
+	queue   searchQueue
+}
+
+func (g *Graph) newSearch() *search {
+	n := len(g.vertexLat)
+	s := &search{
+		g:       g,
+		seconds: make([]float64, n),
+		meters:  make([]float64, n),
+		pred:    make([]int32, n),
+		done:    make([]bool, n),
+	}
+	for v := range s.seconds {
+		s.seconds[v] = math.Inf(1)
+	}
+	return s
+}
+
+// start ставит в очередь концы участка, на который привязана точка,
+// в разрешённых направлениях.
+func (s *search) start(from snap) {
+	a := s.g.arcs[from.arc]
+	f := s.g.fraction(from)
+	if a.forward {
+		s.relax(a.to, (1-f)*a.seconds, a.meters-from.offset, -1)
+	}
+	if a.backward {
+		s.relax(a.from, f*a.seconds, from.offset, -1)
+	}
+}
+
+func (s *search) relax(v int32, seconds, meters float64, pred int32) {
+	if seconds < s.seconds[v] {
+		s.seconds[v], s.meters[v], s.pred[v] = seconds, meters, pred
+		heap.Push(&s.queue, queueItem{v: v, seconds: seconds})
+	}
+}
+
+// next закрепляет ближайшую вершину и просматривает её рёбра; -1 —
+// очередь пуста.
+func (s *search) next() int32 {
+	for s.queue.Len() > 0 {
+		it := heap.Pop(&s.queue).(queueItem)
+		if s.done[it.v] {
+			continue
+		}
+		s.done[it.v] = true
+		for e := s.g.first[it.v]; e < s.g.first[it.v+1]; e++ {
+			ed := s.g.edges[e]
+			a := &s.g.arcs[ed.arc]
+			s.relax(ed.to, it.seconds+a.seconds, s.meters[it.v]+a.meters, e)
+		}
+		return it.v
+	}
+	return -1
+}
+
+// arrival — лучший путь до привязанной точки to через уже закреплённые
+// вершины: entry — вершина въезда на её участок (-1 — путь по тому же
+// участку без вершин).
+func (s *search) arrival(from, to snap) (seconds, meters float64, entry int32) {
+	seconds, meters, entry = math.Inf(1), math.Inf(1), -1
+	a := s.g.arcs[to.arc]
+	f := s.g.fraction(to)
+	if a.forward && s.done[a.from] {
+		if t := s.seconds[a.from] + f*a.seconds; t < seconds {
+			seconds, meters, entry = t, s.meters[a.from]+to.offset, a.from
+		}
+	}
+	if a.backward && s.done[a.to] {
+		if t := s.seconds[a.to] + (1-f)*a.seconds; t < seconds {
+			seconds, meters, entry = t, s.meters[a.to]+a.meters-to.offset, a.to
+		}
+	}
+	if from.arc == to.arc {
+		d := to.offset - from.offset
+		if (d >= 0 && a.forward) || (d <= 0 && a.backward) {
+			if t := math.Abs(d) / math.Max(a.meters, 1e-9) * a.seconds; t <= seconds {
+				seconds, meters, entry = t, math.Abs(d), -1
+			}
+		}
+	}
+	return seconds, meters, entry
+}
+
+// settled сообщает, что лучше путь до to уже не станет: оба конца его
+// участка закреплены или очередь дальше найденного пути.
+func (s *search) settled(to snap, best float64) bool {
+	a := s.g.arcs[to.arc]
+	if s.done[a.from] && s.done[a.to] {
+		return true
+	}
+	return s.queue.Len() == 0 || s.queue[0].seconds >= best
+}
+
+// Path — путь по дорогам между двумя точками.
+type Path struct {
+	Meters   float64
+	Duration time.Duration
+	// Линия пути от привязки начальной точки к дороге до привязки конечной
+	Geometry []Point
+}
+
+// Route ищет самый быстрый путь между точками.
+func (g *Graph) Route(from, to Point) (*Path, error) {
+	sf, err := g.snap(from)
+	if err != nil {
+		return nil, err
+	}
+	st, err := g.snap(to)
+	if err != nil {
+		return nil, err
+	}
+
+	s := g.newSearch()
+	s.start(sf)
+	best, _, _ := s.arrival(sf, st)
+	for !s.settled(st, best) {
+		if s.next() < 0 {
+			break
+		}
+		best, _, _ = s.arrival(sf, st)
+	}
+	seconds, meters, entry := s.arrival(sf, st)
+	if math.IsInf(seconds, 1) {
+		return nil, ErrNoRoute
+	}
+	return &Path{
+		Meters:   meters,
+		Duration: time.Duration(seconds * float64(time.Second)),
+		Geometry: s.geometry(sf, st, entry),
+	}, nil
+}
+
+// geometry собирает линию пути: от начальной точки по её участку до
+// первой вершины, по рёбрам до вершины въезда и по участку конечной точки.
+func (s *search) geometry(from, to snap, entry int32) []Point {
+	g := s.g
+	if entry < 0 {
+		return g.arcSlice(from.arc, from.offset, to.offset)
+	}
+
+	var edges []int32
+	v := entry
+	for s.pred[v] >= 0 {
+		e := s.pred[v]
+		edges = append(edges, e)
+		a := g.arcs[g.edges[e].arc]
+		if g.edges[e].reverse {
+			v = a.to
+		} else {
+			v = a.from
+		}
+	}
+
+	// v — первая вершина пути: конец участка начальной точки
+	fa := g.arcs[from.arc]
+	var line []Point
+	if v == fa.to && fa.forward {
+		line = g.arcSlice(from.arc, from.offset, fa.meters)
+	} else {
+		line = g.arcSlice(from.arc, from.offset, 0)
+	}
+	for i := len(edges) - 1; i >= 0; i-- {
+		ed := g.edges[edges[i]]
+		a := g.arcs[ed.arc]
+		if ed.reverse {
+			line = appendLine(line, g.arcSlice(ed.arc, a.meters, 0))
+		} else {
+			line = appendLine(line, g.arcSlice(ed.arc, 0, a.meters))
+		}
+	}
+	ta := g.arcs[to.arc]
+	if entry == ta.from && ta.forward {
+		line = appendLine(line, g.arcSlice(to.arc, 0, to.offset))
+	} else {
+		line = appendLine(line, g.arcSlice(to.arc, ta.meters, to.offset))
+	}
+	return line
+}
+
+// arcSlice — часть формы участка между расстояниями from и to от его
+// начала, в порядке от from к to, с концами на самой линии.
+func (g *Graph) arcSlice(i int32, from, to float64) []Point {
+	a := g.arcs[i]
+	at := func(d float64) Point {
+		k := a.first
+		for k < a.last-1 && g.shapeDist[k+1] < d {
+			k++
+		}
+		seg := g.shapeDist[k+1] - g.shapeDist[k]
+		t := 0.0
+		if seg > 0 {
+			t = math.Max(0, math.Min(1, (d-g.shapeDist[k])/seg))
+		}
+		return Point{
+			Latitude:  g.shapeLat[k] + t*(g.shapeLat[k+1]-g.shapeLat[k]),
+			Longitude: g.shapeLon[k] + t*(g.shapeLon[k+1]-g.shapeLon[k]),
+		}
+	}
+
+	line := []Point{at(from)}
+	if from <= to {
+		for k := a.first; k <= a.last; k++ {
+			if d := g.shapeDist[k]; d > from && d < to {
+				line = append(line, Point{Latitude: g.shapeLat[k], Longitude: g.shapeLon[k]})
+			}
+		}
+	} else {
+		for k := a.last; k >= a.first; k-- {
+			if d := g.shapeDist[k]; d < from && d > to {
+				line = append(line, Point{Latitude: g.shapeLat[k], Longitude: g.shapeLon[k]})
+			}
+		}
+	}
+	return append(line, at(to))
+}
+
+// appendLine дописывает линию, не повторяя общую точку стыка.
+func appendLine(line, next []Point) []Point {
+	if len(line) > 0 && len(next) > 0 && line[len(line)-1] == next[0] {
+		next = next[1:]
+	}
+	return append(line, next...)
+}
+
+// Matrix — расстояния (м) и время в пути (с) между всеми парами точек:
+// Meters[i][j] — от точки i до точки j. +Inf — пути нет или точка
+// далеко от дорог.
+type Matrix struct {
+	Meters  [][]float64
+	Seconds [][]float64
+}
+
+// Matrix считает пути между всеми парами точек: по поиску из каждой
+// точки, параллельно на всех процессорах. Поиск из точки заканчивается,
+// когда закреплены участки всех остальных. Ошибка — только отмена ctx.
+func (g *Graph) Matrix(ctx context.Context, points []Point) (*Matrix, error) {
+	n := len(points)
+	m := &Matrix{Meters: make([][]float64, n), Seconds: make([][]float64, n)}
+	snaps := make([]snap, n)
+	ok := make([]bool, n)
+	// Концы участков всех точек: когда они закреплены, пути до точек
+	// известны
+	targets := make(map[int32]bool)
+	for i, p := range points {
+		m.Meters[i], m.Seconds[i] = make([]float64, n), make([]float64, n)
+		var err error
+		snaps[i], err = g.snap(p)
+		ok[i] = err == nil
+		if ok[i] {
+			a := g.arcs[snaps[i].arc]
+			targets[a.from], targets[a.to] = true, true
+		}
+	}
+
+	rows := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range rows {
+				g.matrixRow(m, snaps, ok, targets, i)
+			}
+		}()
+	}
+	var err error
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case rows <- i:
+		case <-ctx.Done():
+			err = ctx.Err()
+			break feed
+		}
+	}
+	close(rows)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (g *Graph) matrixRow(m *Matrix, snaps []snap, ok []bool, targets map[int32]bool, i int) {
+	for j := range snaps {
+		m.Meters[i][j], m.Seconds[i][j] = math.Inf(1), math.Inf(1)
+	}
+	m.Meters[i][i], m.Seconds[i][i] = 0, 0
+	if !ok[i] {
+		return
+	}
+
+	s := g.newSearch()
+	s.start(snaps[i])
+	for left := len(targets); left > 0; {
+		v := s.next()
+		if v < 0 {
+			break
+		}
+		if targets[v] {
+			left--
+		}
+	}
+	for j, sj := range snaps {
+		if ok[j] && j != i {
+			m.Seconds[i][j], m.Meters[i][j], _ = s.arrival(snaps[i], sj)
+		}
+	}
+}
+
+type queueItem struct {
+	v       int32
+	seconds float64
+}
+
+// searchQueue — двоичная куча вершин по времени пути.
+type searchQueue []queueItem
+
+func (q searchQueue) Len() int            { return len(q) }
+func (q searchQueue) Less(i, j int) bool  { return q[i].seconds < q[j].seconds }
+func (q searchQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *searchQueue) Push(x interface{}) { *q = append(*q, x.(queueItem)) }
+func (q *searchQueue) Pop() interface{} {
+	old := *q
+	it := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return it
+}
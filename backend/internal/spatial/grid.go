@@ -0,0 +1,197 @@
+// Package spatial — пространственный индекс точек в памяти процесса.
+package spatial
+
+import (
+	"garbage_trucks/backend/internal/geo"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Item — найденный объект с расстоянием до точки запроса в метрах.
+type Item struct {
+	ID        int
+	Latitude  float64
+	Longitude float64
+	Distance  float64
+}
+
+type cell struct {
+	row, col int
+}
+
+type entry struct {
+	lat, lon float64
+	cell     cell
+}
+
+// GridIndex — равномерная сетка по широте и долготе. Для городского
+// масштаба (тысячи и десятки тысяч точек) сетка с ячейкой около километра
+// даёт тот же выигрыш, что и R-дерево, но намного проще.
+type GridIndex struct {
+	mu       sync.RWMutex
+	cellSize float64
+	cells    map[cell]map[int]struct{}
+	entries  map[int]entry
+}
+
+// NewGridIndex создаёт индекс с размером ячейки в градусах.
+func NewGridIndex(cellSize float64) *GridIndex {
+	return &GridIndex{
+		cellSize: cellSize,
+		cells:    make(map[cell]map[int]struct{}),
+		entries:  make(map[int]entry),
+	}
+}
+
+func (g *GridIndex) cellOf(lat, lon float64) cell {
+	return cell{
+		row: int(math.Floor(lat / g.cellSize)),
+		col: int(math.Floor(lon / g.cellSize)),
+	}
+}
+
+// Insert добавляет или перемещает объект.
+func (g *GridIndex) Insert(id int, lat, lon float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.insertLocked(id, lat, lon)
+}
+
+func (g *GridIndex) insertLocked(id int, lat, lon float64) {
+	g.removeLocked(id)
+
+	c := g.cellOf(lat, lon)
+	bucket := g.cells[c]
+	if bucket == nil {
+		bucket = make(map[int]struct{})
+		g.cells[c] = bucket
+	}
+	bucket[id] = struct{}{}
+	g.entries[id] = entry{lat: lat, lon: lon, cell: c}
+}
+
+// Remove удаляет объект из индекса.
+func (g *GridIndex) Remove(id int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.removeLocked(id)
+}
+
+func (g *GridIndex) removeLocked(id int) {
+	e, ok := g.entries[id]
+	if !ok {
+		return
+	}
+	delete(g.entries, id)
+	if bucket := g.cells[e.cell]; bucket != nil {
+		delete(bucket, id)
+		if len(bucket) == 0 {
+			delete(g.cells, e.cell)
+		}
+	}
+}
+
+// Replace атомарно заменяет всё содержимое индекса.
+func (g *GridIndex) Replace(items []Item) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.cells = make(map[cell]map[int]struct{})
+	g.entries = make(map[int]entry, len(items))
+	for _, it := range items {
+		g.insertLocked(it.ID, it.Latitude, it.Longitude)
+	}
+}
+
+// Len возвращает число объектов в индексе.
+func (g *GridIndex) Len() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return len(g.entries)
+}
+
+// InBBox возвращает объекты внутри прямоугольника, отсортированные по
+// расстоянию от точки (centerLat, centerLon).
+func (g *GridIndex) InBBox(b geo.BBox, centerLat, centerLon float64) []Item {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var items []Item
+	g.scan(b, func(id int, e entry) {
+		if b.Contains(e.lat, e.lon) {
+			items = append(items, Item{
+				ID: id, Latitude: e.lat, Longitude: e.lon,
+				Distance: geo.Distance(centerLat, centerLon, e.lat, e.lon),
+			})
+		}
+	})
+
+	sortByDistance(items)
+	return items
+}
+
+// Nearby возвращает объекты в радиусе radius метров, ближайшие первыми.
+// limit <= 0 означает без ограничения.
+func (g *GridIndex) Nearby(lat, lon, radius float64, limit int) []Item {
+	// Прямоугольник, гарантированно покрывающий круг радиуса radius
+	dLat := radius / 111320.0
+	cosLat := math.Max(math.Cos(lat*math.Pi/180), 0.01)
+	dLon := radius / (111320.0 * cosLat)
+	b := geo.BBox{MinLon: lon - dLon, MinLat: lat - dLat, MaxLon: lon + dLon, MaxLat: lat + dLat}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var items []Item
+	g.scan(b, func(id int, e entry) {
+		d := geo.Distance(lat, lon, e.lat, e.lon)
+		if d <= radius {
+			items = append(items, Item{ID: id, Latitude: e.lat, Longitude: e.lon, Distance: d})
+		}
+	})
+
+	sortByDistance(items)
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// scan обходит объекты всех ячеек, пересекающих прямоугольник.
+// Если ячеек в прямоугольнике больше, чем непустых ячеек индекса,
+// дешевле перебрать непустые ячейки.
+func (g *GridIndex) scan(b geo.BBox, fn func(id int, e entry)) {
+	lo := g.cellOf(b.MinLat, b.MinLon)
+	hi := g.cellOf(b.MaxLat, b.MaxLon)
+
+	span := float64(hi.row-lo.row+1) * float64(hi.col-lo.col+1)
+	if span > float64(len(g.cells)) {
+		for c, bucket := range g.cells {
+			if c.row < lo.row || c.row > hi.row || c.col < lo.col || c.col > hi.col {
+				continue
+			}
+			for id := range bucket {
+				fn(id, g.entries[id])
+			}
+		}
+		return
+	}
+
+	for row := lo.row; row <= hi.row; row++ {
+		for col := lo.col; col <= hi.col; col++ {
+			for id := range g.cells[cell{row, col}] {
+				fn(id, g.entries[id])
+			}
+		}
+	}
+}
+
+func sortByDistance(items []Item) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Distance != items[j].Distance {
+			return items[i].Distance < items[j].Distance
+		}
+		return items[i].ID < items[j].ID
+	})
+}
@@ -11,6 +11,8 @@ interface Route {
     address: string;
     latitude: number;
     longitude: number;
+    access_notes?: string;
+    gate_code?: string;
   };
 }
 
@@ -59,6 +61,16 @@ export default function RouteCard({ route, onStatusChange }: RouteCardProps) {
           <p style={{ color: '#666', margin: '0', fontSize: '14px' }}>
             {route.point.address}
           </p>
+          {route.point.access_notes && (
+            <p style={{ color: '#666', margin: '5px 0 0 0', fontSize: '13px' }}>
+              {route.point.access_notes}
+            </p>
+          )}
+          {route.point.gate_code && (
+            <p style={{ margin: '5px 0 0 0', fontSize: '14px', fontWeight: 'bold' }}>
+              🔑 Код ворот: {route.point.gate_code}
+            </p>
+          )}
         </div>
         <div
           style={{
@@ -12,6 +12,8 @@ interface Route {
     address: string;
     latitude: number;
     longitude: number;
+    access_notes?: string;
+    gate_code?: string;
   };
 }
 
@@ -10,6 +10,8 @@ interface Route {
     address: string;
     latitude: number;
     longitude: number;
+    access_notes?: string;
+    gate_code?: string;
   };
 }
 
@@ -31,13 +33,14 @@ export const useRoutes = (driverId: string | string[] | undefined) => {
     if (!driverId) return;
 
     const apiUrl = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
-    fetch(`${apiUrl}/api/routes?driver_id=${driverId}`)
+    // Маршрутный лист на сегодня: остановки дня с кодами ворот
+    fetch(`${apiUrl}/api/drivers/${driverId}/routes`)
       .then((res) => {
         if (!res.ok) throw new Error(`HTTP error! status: ${res.status}`);
         return res.json();
       })
       .then((responseData) => {
-        setData(responseData);
+        setData({ driver: responseData.driver, routes: responseData.items || [] });
         setLoading(false);
       })
       .catch((err) => {
@@ -3,6 +3,7 @@
 import { useEffect, useState } from 'react';
 import Link from 'next/link';
 import { useRouter } from 'next/navigation';
+import { fetchAllPages } from './utils/fetchAllPages';
 
 interface Driver {
   id: number;
@@ -21,13 +22,9 @@ export default function Home() {
 
   useEffect(() => {
     const apiUrl = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
-    fetch(`${apiUrl}/api/drivers`)
-      .then(res => {
-        if (!res.ok) throw new Error(`HTTP error! status: ${res.status}`);
-        return res.json();
-      })
+    fetchAllPages<Driver>(`${apiUrl}/api/drivers`)
       .then(data => {
-        setDrivers(data || []);
+        setDrivers(data.items);
         setLoading(false);
       })
       .catch(err => {
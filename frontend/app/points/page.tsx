@@ -2,6 +2,7 @@
 
 import { useEffect, useState } from 'react';
 import { useRouter } from 'next/navigation';
+import { fetchAllPages } from '../utils/fetchAllPages';
 
 interface Point {
   id: number;
@@ -39,13 +40,9 @@ export default function PointsPage() {
     const apiUrl = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
     
     // Загружаем точки
-    fetch(`${apiUrl}/api/points`)
-      .then(res => {
-        if (!res.ok) throw new Error(`HTTP error! status: ${res.status}`);
-        return res.json();
-      })
+    fetchAllPages<Point>(`${apiUrl}/api/points`)
       .then(data => {
-        setPoints(data || []);
+        setPoints(data.items);
         setLoading(false);
       })
       .catch(err => {
@@ -54,9 +51,8 @@ export default function PointsPage() {
       });
 
     // Загружаем водителей
-    fetch(`${apiUrl}/api/drivers`)
-      .then(res => res.json())
-      .then(data => setDrivers(data || []))
+    fetchAllPages<Driver>(`${apiUrl}/api/drivers`)
+      .then(data => setDrivers(data.items))
       .catch(err => console.error('Error fetching drivers:', err));
   }, []);
 
@@ -103,11 +99,8 @@ export default function PointsPage() {
         if (!response.ok) throw new Error('Failed to update point');
 
         // Перезагружаем все точки
-        const pointsResponse = await fetch(`${apiUrl}/api/points`);
-        if (pointsResponse.ok) {
-          const updatedPoints = await pointsResponse.json();
-          setPoints(updatedPoints || []);
-        }
+        const updatedPoints = await fetchAllPages<Point>(`${apiUrl}/api/points`);
+        setPoints(updatedPoints.items);
       } else {
         // Добавление
         const response = await fetch(`${apiUrl}/api/points`, {
@@ -128,11 +121,8 @@ export default function PointsPage() {
         if (!response.ok) throw new Error('Failed to create point');
 
         // Перезагружаем все точки
-        const pointsResponse = await fetch(`${apiUrl}/api/points`);
-        if (pointsResponse.ok) {
-          const updatedPoints = await pointsResponse.json();
-          setPoints(updatedPoints || []);
-        }
+        const updatedPoints = await fetchAllPages<Point>(`${apiUrl}/api/points`);
+        setPoints(updatedPoints.items);
       }
       
       setShowModal(false);
@@ -154,17 +144,13 @@ export default function PointsPage() {
       city: point.city
     });
     
-    // Загружаем водителей для этой точки
+    // Загружаем водителей для этой точки: назначены те, у кого остановка
+    // ещё впереди, пройденные остаются только в истории
     try {
       const apiUrl = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
-      const response = await fetch(`${apiUrl}/api/routes?point_id=${point.id}`);
-      if (response.ok) {
-        const routes = await response.json();
-        const driverIds = (routes || []).map((r: any) => r.driver_id);
-        setSelectedDrivers(driverIds);
-      } else {
-        setSelectedDrivers([]);
-      }
+      const routes = await fetchAllPages<{ driver_id: number }>(`${apiUrl}/api/routes?point_id=${point.id}&status=pending,in_progress`);
+      const driverIds = routes.items.map(r => r.driver_id);
+      setSelectedDrivers(Array.from(new Set(driverIds)));
     } catch (err) {
       console.error('Error fetching point drivers:', err);
       setSelectedDrivers([]);
@@ -183,15 +169,15 @@ export default function PointsPage() {
     if (isNaN(latitude) || isNaN(longitude)) return;
 
     try {
+      const apiUrl = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
       const response = await fetch(
-        `https://geocode-maps.yandex.ru/1.x/?apikey=8deea7af-f681-4ac6-96b6-c6f2d7ac1ec7&geocode=${longitude},${latitude}&format=json&lang=ru_RU`
+        `${apiUrl}/api/geocode/reverse?lat=${latitude}&lon=${longitude}`
       );
+      if (!response.ok) return;
       const data = await response.json();
       
-      const geoObject = data.response?.GeoObjectCollection?.featureMember?.[0]?.GeoObject;
-      if (geoObject) {
-        const address = geoObject.metaDataProperty?.GeocoderMetaData?.text || '';
-        setNewPoint(prev => ({ ...prev, address }));
+      if (data.address) {
+        setNewPoint(prev => ({ ...prev, address: data.address }));
       }
     } catch (err) {
       console.error('Error fetching address:', err);
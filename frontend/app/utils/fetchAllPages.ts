@@ -0,0 +1,32 @@
+// Размер страницы: не больше наименьшего максимума списков API (водители — 500)
+const PAGE_SIZE = 500;
+
+export interface AllPages<T> {
+  items: T[];
+  [key: string]: any;
+}
+
+// Загружает все страницы списка API, следуя next_cursor. Возвращает
+// элементы всех страниц и остальные поля первой (например, карточку
+// водителя в /api/routes?driver_id=).
+export async function fetchAllPages<T>(url: string): Promise<AllPages<T>> {
+  const separator = url.includes('?') ? '&' : '?';
+  const items: T[] = [];
+  let first: any = null;
+  let cursor: string | null = null;
+
+  do {
+    let pageUrl = `${url}${separator}limit=${PAGE_SIZE}`;
+    if (cursor) {
+      pageUrl += `&cursor=${encodeURIComponent(cursor)}`;
+    }
+    const res = await fetch(pageUrl);
+    if (!res.ok) throw new Error(`HTTP error! status: ${res.status}`);
+    const page = await res.json();
+    if (!first) first = page;
+    items.push(...(page?.items || []));
+    cursor = page?.next_cursor || null;
+  } while (cursor);
+
+  return { ...first, items };
+}